@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/diff"
+	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// dryRunFieldManager is the field manager used for server-side apply
+// dry-runs.
+const dryRunFieldManager = "stalk"
+
+// diffManifests shows, for every object in the manifest file, how applying
+// it would change the live object. The result of the apply is determined
+// using a server-side dry-run, so that defaulting, admission webhooks and
+// field ownership are all taken into account, just like `kubectl diff`.
+func diffManifests(ctx context.Context, log logrus.FieldLogger, filename string, appOpts *options, differ *diff.Differ, out io.Writer) error {
+	objects, err := readManifests(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	config, err := kubeconfigLoader(appOpts.kubeconfig, appOpts.kubeContext).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	applyRateLimits(config, appOpts)
+
+	defaultNamespace, _, err := kubeconfigLoader(appOpts.kubeconfig, appOpts.kubeContext).Namespace()
+	if err != nil {
+		return fmt.Errorf("failed to determine default namespace: %w", err)
+	}
+
+	resolver, err := kubeutil.NewResolver(config, log)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes REST mapper: %w", err)
+	}
+
+	for _, local := range objects {
+		if err := diffManifest(ctx, log, resolver, local, defaultNamespace, differ, out); err != nil {
+			return fmt.Errorf("%s %s: %w", local.GetKind(), local.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+func diffManifest(ctx context.Context, log logrus.FieldLogger, resolver *kubeutil.Resolver, local *unstructured.Unstructured, defaultNamespace string, differ *diff.Differ, out io.Writer) error {
+	gvk := local.GroupVersionKind()
+	name := local.GetName()
+	if gvk.Kind == "" || name == "" {
+		return fmt.Errorf("manifests must specify at least apiVersion, kind and metadata.name")
+	}
+
+	namespaced, err := resolver.IsNamespaced(gvk)
+	if err != nil {
+		return err
+	}
+
+	if namespaced && local.GetNamespace() == "" {
+		local.SetNamespace(defaultNamespace)
+	}
+
+	client, err := resolver.NamespacedResourceInterfaceFor(gvk, local.GetNamespace())
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic interface: %w", err)
+	}
+
+	var live *unstructured.Unstructured
+	err = kubeutil.RetryOnThrottling(ctx, log, func() (err error) {
+		live, err = client.Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to retrieve live object: %w", err)
+		}
+
+		live = nil
+	}
+
+	var applied *unstructured.Unstructured
+	err = kubeutil.RetryOnThrottling(ctx, log, func() (err error) {
+		applied, err = client.Apply(ctx, name, local, metav1.ApplyOptions{
+			FieldManager: dryRunFieldManager,
+			DryRun:       []string{metav1.DryRunAll},
+			Force:        true,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("server-side dry-run failed: %w", err)
+	}
+
+	return differ.PrintDiff(out, live, applied, time.Now(), diff.TitleInfo{
+		Annotations: []string{"(dry-run)"},
+	})
+}
+
+// readManifests returns all objects in the (possibly multi-document) YAML
+// or JSON file.
+func readManifests(filename string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	objects := []*unstructured.Unstructured{}
+	decoder := yamlutil.NewYAMLOrJSONDecoder(f, 1024)
+
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+
+		// skip empty documents
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		objects = append(objects, obj)
+	}
+
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects found")
+	}
+
+	return objects, nil
+}
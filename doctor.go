@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+
+	"github.com/gookit/color"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
+)
+
+// doctorKinds are checked if no kinds are given to the doctor subcommand.
+var doctorKinds = []string{"pods", "deployments", "configmaps", "events"}
+
+// doctorTimeout limits how long the doctor waits for the API server, so
+// that unreachable clusters are reported instead of hanging.
+const doctorTimeout = 10 * time.Second
+
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+type checkResult struct {
+	status  string
+	name    string
+	message string
+	hint    string
+}
+
+// runDoctor checks the most common reasons why stalk does not show anything
+// and prints the result of each check, together with hints on how to fix
+// failures. It returns false if any check failed.
+func runDoctor(ctx context.Context, log logrus.FieldLogger, kinds []string, appOpts *options, out io.Writer) bool {
+	if len(kinds) == 0 {
+		kinds = doctorKinds
+	}
+
+	healthy := true
+	report := func(result checkResult) {
+		printCheck(out, result)
+
+		if result.status == checkFail {
+			healthy = false
+		}
+	}
+
+	report(checkTerminal(appOpts.forceColor))
+
+	config, result := checkKubeconfig(appOpts)
+	report(result)
+	if config == nil {
+		return false
+	}
+
+	result = checkConnectivity(config)
+	report(result)
+	if result.status == checkFail {
+		return false
+	}
+
+	resolver, err := kubeutil.NewResolver(config, log)
+	if err != nil {
+		report(checkResult{
+			status:  checkFail,
+			name:    "discovery",
+			message: fmt.Sprintf("failed to set up discovery: %v", err),
+			hint:    "make sure ~/.kube/cache is writable",
+		})
+
+		return false
+	}
+
+	authClient, err := authorizationv1client.NewForConfig(config)
+	if err != nil {
+		report(checkResult{
+			status:  checkFail,
+			name:    "permissions",
+			message: fmt.Sprintf("failed to create client: %v", err),
+		})
+
+		return false
+	}
+
+	for _, kind := range kinds {
+		mapping, result := checkDiscovery(resolver, kind)
+		report(result)
+
+		if mapping != nil {
+			for _, result := range checkPermissions(ctx, authClient, mapping, appOpts) {
+				report(result)
+			}
+		}
+	}
+
+	return healthy
+}
+
+func printCheck(out io.Writer, result checkResult) {
+	status := result.status
+	switch status {
+	case checkPass:
+		status = color.Green.Sprint(status)
+	case checkWarn:
+		status = color.Yellow.Sprint(status)
+	case checkFail:
+		status = color.Red.Sprint(status)
+	}
+
+	fmt.Fprintf(out, "[%s] %s: %s\n", status, result.name, result.message)
+
+	if result.hint != "" && result.status != checkPass {
+		fmt.Fprintf(out, "       hint: %s\n", result.hint)
+	}
+}
+
+func checkTerminal(forceColor bool) checkResult {
+	result := checkResult{
+		status: checkPass,
+		name:   "terminal",
+	}
+
+	isTerminal := term.IsTerminal(int(os.Stdout.Fd()))
+
+	switch {
+	case forceColor:
+		result.message = "colors are forced"
+
+	case !isTerminal:
+		result.message = "output is not a terminal, colors are disabled"
+
+	// disabled by --plain, --no-color or the NO_COLOR environment variable
+	case !color.Enable:
+		result.message = "colors are disabled"
+
+	case color.SupportColor():
+		result.message = "colors are supported"
+
+	default:
+		result.status = checkWarn
+		result.message = "terminal does not seem to support colors"
+		result.hint = "check the TERM environment variable, or use --plain for plain output"
+	}
+
+	return result
+}
+
+func checkKubeconfig(appOpts *options) (*rest.Config, checkResult) {
+	result := checkResult{
+		name: "kubeconfig",
+	}
+
+	config, err := kubeconfigLoader(appOpts.kubeconfig, appOpts.kubeContext).ClientConfig()
+	if err != nil {
+		result.status = checkFail
+		result.message = fmt.Sprintf("failed to load: %v", err)
+		result.hint = "pass --kubeconfig or set $KUBECONFIG"
+
+		return nil, result
+	}
+
+	applyRateLimits(config, appOpts)
+	config.Timeout = doctorTimeout
+
+	result.status = checkPass
+	result.message = fmt.Sprintf("using cluster at %s", config.Host)
+
+	return config, result
+}
+
+func checkConnectivity(config *rest.Config) checkResult {
+	result := checkResult{
+		name: "connectivity",
+	}
+
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err == nil {
+		var version fmt.Stringer
+		version, err = client.ServerVersion()
+
+		if err == nil {
+			result.status = checkPass
+			result.message = fmt.Sprintf("connected to Kubernetes %s", version)
+
+			return result
+		}
+	}
+
+	result.status = checkFail
+	result.message = fmt.Sprintf("cannot reach the API server: %v", err)
+	result.hint = "check that the cluster is running, that you are connected to its network and that your credentials are still valid"
+
+	return result
+}
+
+// checkDiscovery resolves the kind. If that only works after the discovery
+// cache was refreshed, the cache was stale.
+func checkDiscovery(resolver *kubeutil.Resolver, kind string) (*meta.RESTMapping, checkResult) {
+	result := checkResult{
+		name: fmt.Sprintf("discovery of %s", kind),
+	}
+
+	mapping, err := resolver.ResolveWithoutRetry(kind)
+	if err == nil {
+		result.status = checkPass
+		result.message = fmt.Sprintf("resolved to %s", mapping.Resource.String())
+
+		return mapping, result
+	}
+
+	resolver.InvalidateCache()
+
+	mapping, err = resolver.ResolveWithoutRetry(kind)
+	if err != nil {
+		result.status = checkFail
+		result.message = err.Error()
+		result.hint = "check the spelling of the kind, kubectl api-resources lists all available kinds"
+
+		return nil, result
+	}
+
+	result.status = checkWarn
+	result.message = fmt.Sprintf("resolved to %s, but only after refreshing the stale discovery cache", mapping.Resource.String())
+	result.hint = "this happens after CRDs were installed; stalk refreshes the cache on its own"
+
+	return mapping, result
+}
+
+// checkPermissions checks whether the resources can be listed and watched
+// in all namespaces that stalk would watch.
+func checkPermissions(ctx context.Context, client authorizationv1client.AuthorizationV1Interface, mapping *meta.RESTMapping, appOpts *options) []checkResult {
+	// in cluster scope, all namespaces are watched and filtered afterwards
+	namespaces := []string{metav1.NamespaceAll}
+	if appOpts.scope == scopeNamespace && mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespaces = appOpts.namespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{metav1.NamespaceDefault}
+		}
+	}
+
+	results := []checkResult{}
+
+	for _, namespace := range namespaces {
+		where := "in all namespaces"
+		if namespace != metav1.NamespaceAll {
+			where = fmt.Sprintf("in namespace %s", namespace)
+		}
+
+		result := checkResult{
+			status:  checkPass,
+			name:    fmt.Sprintf("permissions for %s", mapping.Resource.Resource),
+			message: fmt.Sprintf("allowed to list and watch %s", where),
+		}
+
+		for _, verb := range []string{"list", "watch"} {
+			allowed, err := canI(ctx, client, verb, mapping, namespace)
+			if err != nil {
+				result.status = checkFail
+				result.message = fmt.Sprintf("failed to check permissions: %v", err)
+				break
+			}
+
+			if !allowed {
+				result.status = checkFail
+				result.message = fmt.Sprintf("not allowed to %s %s", verb, where)
+
+				if namespace == metav1.NamespaceAll {
+					result.hint = "ask a cluster admin for permissions, or use --scope namespace with -n to only watch namespaces you have access to"
+				} else {
+					result.hint = "ask a cluster admin for permissions in this namespace"
+				}
+
+				break
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func canI(ctx context.Context, client authorizationv1client.AuthorizationV1Interface, verb string, mapping *meta.RESTMapping, namespace string) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     mapping.Resource.Group,
+				Version:   mapping.Resource.Version,
+				Resource:  mapping.Resource.Resource,
+			},
+		},
+	}
+
+	response, err := client.SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return response.Status.Allowed, nil
+}
@@ -2,62 +2,301 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"go.xrstf.de/stalk/pkg/diff"
+	"go.xrstf.de/stalk/pkg/engine"
 	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+	"go.xrstf.de/stalk/pkg/otlp"
+	"go.xrstf.de/stalk/pkg/progress"
+	"go.xrstf.de/stalk/pkg/publish"
+	"go.xrstf.de/stalk/pkg/report"
+	"go.xrstf.de/stalk/pkg/state"
 	"go.xrstf.de/stalk/pkg/watcher"
 
+	"github.com/gookit/color"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// maxConcurrentClusterSetups limits how many clusters are set up in
+// parallel when watching multiple kubeconfig contexts.
+const maxConcurrentClusterSetups = 4
+
+// publishTimeout is how long stalk waits for queued events to be published
+// when exiting.
+const publishTimeout = 10 * time.Second
+
+var (
+	persistentVolumeKind      = schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolume"}
+	persistentVolumeClaimKind = schema.GroupKind{Kind: "PersistentVolumeClaim"}
+)
+
+const (
+	// scopeCluster watches resources in all namespaces at once.
+	scopeCluster = "cluster"
+
+	// scopeNamespace watches resources in each given namespace separately.
+	scopeNamespace = "namespace"
 )
 
 type options struct {
 	kubeconfig        string
+	kubeContext       string
 	namespaces        []string
+	allNamespaces     bool
+	includeNamespaces []string
+	excludeNamespaces []string
 	labels            string
+	fieldSelector     string
 	hideManagedFields bool
+	specOnly          bool
+	generationOnly    bool
 	jsonPath          string
 	hidePaths         []string
 	showPaths         []string
+	semanticIgnore    []string
+	createHidePaths   []string
+	createShowPaths   []string
+	updateHidePaths   []string
+	updateShowPaths   []string
+	deleteHidePaths   []string
+	deleteShowPaths   []string
 	selector          labels.Selector
 	showEmpty         bool
 	disableWordDiff   bool
+	diffAlgorithm     string
+	eventTime         string
+	plain             bool
+	forceColor        bool
+	noColor           bool
+	pager             bool
+	output            string
+	diffWhitespace    string
+	detectMoves       bool
+	relativeTimes     bool
+	lineNumbers       bool
+	collapseArrays    bool
+	sectioned         bool
+	stripDefaults     bool
+	decodeHelm        bool
+	extraDefaults     []string
 	contextLines      int
+	autoContext       bool
+	compactTitle      bool
+	noHeaders         bool
+	quiet             bool
+	ticker            bool
+	quietField        string
+	sortBy            string
+	project           string
+	rollout           bool
+	finalizers        bool
+	correlationWindow time.Duration
+	watchFile         string
+	filename          string
+	groupByGeneration bool
+	excludeKinds      []string
+	snapshot          bool
+	onlyChanges       bool
+	last              int
+	contexts          []string
+	allContexts       bool
+	clusterTags       string
+	sortInitial       string
+	conversionWarns   bool
+	ownerChanges      bool
+	quantityChanges   bool
+	migrationKey      string
+	revisionAnnots    []string
+	watchRestarts     bool
+	eventTypes        []string
+	parsedEventTypes  []watch.EventType
+	wide              bool
+	scale             bool
+	uid               string
+	changedBy         string
+	report            string
+	summary           bool
+	publish           string
+	otelEndpoint      string
+	highlightRegex    string
+	number            bool
+	until             []string
+	untilAll          bool
+	onPathChange      []string
+	onPathChangeAll   bool
+	alertOnError      bool
+	unhealthyWhen     []string
+	healthChecks      watcher.HealthChecks
+	parsedPathChanges []*watcher.PathChange
+	parsedUntil       []*watcher.Condition
+	stopWatching      func()
+	progress          *progress.Indicator
+	sessionReport     *report.Report
+	saveState         string
+	outputDir         string
+	finalState        *state.Collector
+	scope             string
+	selectorFile      string
+	withPV            bool
+	anonymize         bool
+	groupKey          string
+	timeout           time.Duration
+	idleTimeout       time.Duration
+	exitOnError       bool
+	poll              bool
+	pollInterval      time.Duration
+	qps               float32
+	burst             int
 	verbose           bool
 }
 
 func main() {
-	rootCtx := context.Background()
+	// stop watching on the first interrupt, so that stalk can shut down
+	// cleanly; a second interrupt kills the process immediately
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
 
 	opt := options{
 		hideManagedFields: true,
 		showEmpty:         false,
 		disableWordDiff:   false,
 		contextLines:      3,
+		diffAlgorithm:     diff.AlgorithmCDiff,
+		eventTime:         diff.EventTimeReceived,
+		output:            diff.OutputDiff,
+		diffWhitespace:    diff.WhitespaceIgnore,
+		pollInterval:      10 * time.Second,
+		qps:               5,
+		burst:             10,
+		scope:             scopeCluster,
+		watchRestarts:     true,
 	}
 
 	pflag.StringVar(&opt.kubeconfig, "kubeconfig", opt.kubeconfig, "kubeconfig file to use (uses $KUBECONFIG by default)")
+	pflag.StringVar(&opt.kubeContext, "context", opt.kubeContext, "kubeconfig context to use (uses the current context by default)")
 	pflag.StringArrayVarP(&opt.namespaces, "namespace", "n", opt.namespaces, "Kubernetes namespace to watch resources in (supports glob expression) (can be given multiple times)")
+	pflag.BoolVarP(&opt.allNamespaces, "all-namespaces", "A", opt.allNamespaces, "watch resources in all namespaces (this is the default in cluster scope if no --namespace is given)")
+	pflag.StringSliceVar(&opt.includeNamespaces, "include-namespaces", opt.includeNamespaces, "only show objects in these namespaces (comma separated, supports glob expressions, can be given multiple times)")
+	pflag.StringSliceVar(&opt.excludeNamespaces, "exclude-namespaces", opt.excludeNamespaces, "hide objects in these namespaces, e.g. kube-system (comma separated, supports glob expressions, can be given multiple times)")
 	pflag.StringVarP(&opt.labels, "labels", "l", opt.labels, "Label-selector as an alternative to specifying resource names")
+	pflag.StringVar(&opt.fieldSelector, "field-selector", opt.fieldSelector, "field selector that is passed to the API server as-is (e.g. status.phase=Running, or any selectable field of a CRD)")
+	pflag.StringVar(&opt.selectorFile, "selector-file", opt.selectorFile, "YAML file with a label selector (matchLabels/matchExpressions) as an alternative to --labels")
 	pflag.BoolVar(&opt.hideManagedFields, "hide-managed", opt.hideManagedFields, "Do not show managed fields")
+	pflag.BoolVar(&opt.specOnly, "spec-only", opt.specOnly, "hide the status and managed fields, so that updates which only change them are not shown")
+	pflag.BoolVar(&opt.generationOnly, "changed-generation-only", opt.generationOnly, "only show updates that change the generation of an object (i.e. its spec), for kinds that have one")
 	pflag.StringVarP(&opt.jsonPath, "jsonpath", "j", opt.jsonPath, "JSON path expression to transform the output (applied before the --show paths)")
 	pflag.StringArrayVarP(&opt.showPaths, "show", "s", opt.showPaths, "path expression to include in output (can be given multiple times) (applied before the --hide paths)")
-	pflag.StringArrayVarP(&opt.hidePaths, "hide", "h", opt.hidePaths, "path expression to hide in output (can be given multiple times)")
-	pflag.BoolVarP(&opt.showEmpty, "show-empty", "e", opt.showEmpty, "do not hide changes which would produce no diff because of --hide/--show/--jsonpath")
+	pflag.StringArrayVarP(&opt.hidePaths, "hide", "h", opt.hidePaths, "path expression to hide in output, \"*\" matches all keys and \"[*]\" all array items (e.g. spec.containers[*].resources) (can be given multiple times)")
+	pflag.StringArrayVar(&opt.semanticIgnore, "semantic-ignore", opt.semanticIgnore, "path expression whose changes do not count as changes; the field is still shown, but only with its current value (can be given multiple times)")
+	pflag.StringArrayVar(&opt.createShowPaths, "create-show", opt.createShowPaths, "like --show, but only for created objects (replaces --show for them)")
+	pflag.StringArrayVar(&opt.createHidePaths, "create-hide", opt.createHidePaths, "like --hide, but only for created objects (replaces --hide for them)")
+	pflag.StringArrayVar(&opt.updateShowPaths, "update-show", opt.updateShowPaths, "like --show, but only for updated objects (replaces --show for them)")
+	pflag.StringArrayVar(&opt.updateHidePaths, "update-hide", opt.updateHidePaths, "like --hide, but only for updated objects (replaces --hide for them)")
+	pflag.StringArrayVar(&opt.deleteShowPaths, "delete-show", opt.deleteShowPaths, "like --show, but only for deleted objects (replaces --show for them)")
+	pflag.StringArrayVar(&opt.deleteHidePaths, "delete-hide", opt.deleteHidePaths, "like --hide, but only for deleted objects (replaces --hide for them)")
+	pflag.BoolVarP(&opt.showEmpty, "show-empty", "e", opt.showEmpty, "do not hide changes which would produce no diff because of --hide/--show/--jsonpath or which only changed the resourceVersion")
 	pflag.BoolVarP(&opt.disableWordDiff, "diff-by-line", "w", opt.disableWordDiff, "diff entire lines and do not highlight changes within words")
-	pflag.IntVarP(&opt.contextLines, "context-lines", "c", opt.contextLines, "number of context lines to show in diffs")
+	pflag.StringVar(&opt.diffAlgorithm, "diff-algorithm", opt.diffAlgorithm, "algorithm to use, \"cdiff\" (colored, for interactive use) or \"difflib\" (plain unified diff, for machines)")
+	pflag.StringVar(&opt.eventTime, "event-time", opt.eventTime, "time to show for events, \"received\" (when stalk received them) or \"object\" (the latest time recorded in the object, useful for replays and reconnects)")
+	pflag.BoolVar(&opt.pager, "pager", opt.pager, "pipe the output through $PAGER (or less) when writing to a terminal; quitting the pager stops stalk")
+	pflag.BoolVar(&opt.plain, "plain", opt.plain, "do not use colors or other terminal escape sequences at all, and render diffs with difflib (useful for CI logs)")
+	pflag.BoolVar(&opt.forceColor, "force-color", opt.forceColor, "use colors even if the output is not a terminal (e.g. when piping into less -R)")
+	pflag.BoolVar(&opt.noColor, "no-color", opt.noColor, "do not use colors, even if the output is a terminal (also enabled by setting $NO_COLOR)")
+	pflag.StringVarP(&opt.output, "output", "o", opt.output, "output format, \"diff\", \"markdown\" (uncolored diffs in fenced code blocks, for pasting into issues), \"yaml\" (current state of each object as a multi-document YAML stream) or \"json\" (one line of JSON per event)")
+	pflag.StringVar(&opt.diffWhitespace, "diff-whitespace", opt.diffWhitespace, "how to treat whitespace, \"ignore\" (trailing whitespace), \"show\" or \"mark\" (make whitespace in changed lines visible)")
+	pflag.BoolVar(&opt.detectMoves, "diff-inline-moves", opt.detectMoves, "show blocks of lines that moved to a different position (e.g. reordered list items) as moves instead of removals and additions")
+	pflag.BoolVar(&opt.stripDefaults, "strip-defaults", opt.stripDefaults, "hide commonly defaulted fields (like a container's imagePullPolicy) that still have their default value")
+	pflag.BoolVar(&opt.decodeHelm, "decode-helm", opt.decodeHelm, "show the chart, values and manifest of Helm release Secrets instead of their encoded payload")
+	pflag.StringArrayVar(&opt.extraDefaults, "extra-default", opt.extraDefaults, "additional defaulted field to hide with --strip-defaults, in the form KIND:PATH=VALUE (use \"*\" as the kind to match all kinds) (can be given multiple times)")
+	pflag.BoolVar(&opt.collapseArrays, "collapse-arrays", opt.collapseArrays, "replace unchanged items of large arrays (10 or more items) with a single marker in diffs")
+	pflag.BoolVar(&opt.sectioned, "sectioned", opt.sectioned, "show the changes of every top-level field (metadata, spec, status, ...) below its own header, leaving out unchanged fields")
+	pflag.BoolVar(&opt.lineNumbers, "line-numbers", opt.lineNumbers, "prefix every line of a diff with its line number in the new object")
+	pflag.BoolVar(&opt.relativeTimes, "relative-times", opt.relativeTimes, "show timestamps in objects (like a condition's lastTransitionTime) relative to now, e.g. \"2m ago\"")
+	pflag.IntVarP(&opt.contextLines, "context-lines", "c", opt.contextLines, "number of context lines to show in diffs (0 shows only the changed lines, a negative number the entire object)")
+	pflag.BoolVar(&opt.autoContext, "diff-context-auto", opt.autoContext, "choose the number of context lines based on the size of each object, from 1 for small objects up to 8 for very large ones")
+	pflag.BoolVar(&opt.compactTitle, "compact-title", opt.compactTitle, "show a short [UPD ns/name] tag in front of the first change instead of a full title")
+	pflag.BoolVar(&opt.noHeaders, "no-headers", opt.noHeaders, "do not show any title, only the diffs separated by blank lines")
+	pflag.BoolVarP(&opt.quiet, "quiet", "q", opt.quiet, "print a single line per event instead of a diff")
+	pflag.StringVar(&opt.quietField, "quiet-field", opt.quietField, "JSON path expression whose value is appended to each line in quiet mode (e.g. {.spec.replicas})")
+	pflag.StringVar(&opt.sortBy, "sort-by", opt.sortBy, "in quiet mode, keep showing the current line of every object, sorted by this JSON path expression (e.g. {.metadata.creationTimestamp})")
+	pflag.BoolVar(&opt.ticker, "ticker", opt.ticker, "keep updating a single line with the value of the --jsonpath expression and when it last changed instead of printing diffs (requires a terminal)")
+	pflag.StringVar(&opt.project, "project", opt.project, "print a single line with the given fields per event instead of a diff (e.g. name=metadata.name,replicas=spec.replicas)")
+	pflag.BoolVar(&opt.rollout, "rollout", opt.rollout, "print a single line with the rollout progress (updated/ready replicas, revisions) for Deployments, StatefulSets and DaemonSets instead of a diff")
+	pflag.BoolVar(&opt.finalizers, "finalizers", opt.finalizers, "print a single line with the remaining finalizers instead of a diff for objects that are being deleted")
+	pflag.DurationVar(&opt.correlationWindow, "correlate", opt.correlationWindow, "tag events of related objects (via owner references) happening within this duration with a shared ID (e.g. 5s)")
+	pflag.StringVarP(&opt.filename, "filename", "f", opt.filename, "manifest to compare against the cluster with the diff subcommand")
+	pflag.StringVar(&opt.watchFile, "watch-file", opt.watchFile, "watch a local manifest and show how it differs from the object in the cluster")
+	pflag.BoolVar(&opt.groupByGeneration, "group-updates-by-generation", opt.groupByGeneration, "only show updates when the generation changes, combining all changes since the previous generation")
+	pflag.StringSliceVar(&opt.excludeKinds, "exclude-kinds", opt.excludeKinds, "resource kinds to not watch, even if they were given (comma separated, can be given multiple times)")
+	pflag.BoolVar(&opt.snapshot, "snapshot", opt.snapshot, "print the current state of all matching resources once and exit instead of watching them")
+	pflag.BoolVar(&opt.onlyChanges, "only-changes", opt.onlyChanges, "do not show the initially existing objects, only changes that happen after stalk was started")
+	pflag.IntVar(&opt.last, "last", opt.last, "instead of all existing objects, show roughly the last N changes before stalk was started (best effort, depends on the API server's watch cache)")
+	pflag.StringSliceVar(&opt.contexts, "contexts", opt.contexts, "kubeconfig contexts to watch resources in at the same time (comma separated, can be given multiple times)")
+	pflag.BoolVar(&opt.allContexts, "all-contexts", opt.allContexts, "watch resources in all kubeconfig contexts at the same time")
+	pflag.StringVar(&opt.clusterTags, "cluster-tags", opt.clusterTags, "short tags to show instead of context names when watching multiple clusters, optionally colored (e.g. \"prod=P:red,staging=S:yellow\")")
+	pflag.StringVar(&opt.sortInitial, "sort-initial", opt.sortInitial, "sort the initially existing objects by \"name\" or \"creation\" time before showing them")
+	pflag.BoolVar(&opt.conversionWarns, "show-conversion-warnings", opt.conversionWarns, "point out changed fields that are not owned by any field manager (e.g. set by defaulting or conversion webhooks)")
+	pflag.BoolVar(&opt.ownerChanges, "show-owner-changes", opt.ownerChanges, "point out when an object was adopted by or orphaned from an owner")
+	pflag.BoolVar(&opt.quantityChanges, "show-quantity-changes", opt.quantityChanges, "point out how resource requests and limits changed, e.g. \"500m → 1 (↑2x)\"")
+	pflag.BoolVar(&opt.watchRestarts, "watch-restarts", opt.watchRestarts, "mark the first event after a watch had to be re-established with \"(after reconnect #N)\"")
+	pflag.StringVar(&opt.migrationKey, "migration-key", opt.migrationKey, "label that links the deletion of an object to the creation of an object of another kind with the same label value (to follow API migrations)")
+	pflag.StringArrayVar(&opt.revisionAnnots, "revision-annotation", opt.revisionAnnots, "annotation whose changes mark a new revision of an object, shown like (revision 3 → 4), in addition to the built-in ones like deployment.kubernetes.io/revision (can be given multiple times)")
+	pflag.StringSliceVar(&opt.eventTypes, "events", opt.eventTypes, "only show these kinds of events (comma separated list of created, modified, deleted)")
+	pflag.BoolVar(&opt.wide, "wide", opt.wide, "show additional fields like a pod's node and phase in the diff title")
+	pflag.BoolVar(&opt.scale, "scale", opt.scale, "watch the scale subresource of scalable kinds (like Deployments) instead of the full objects")
+	pflag.StringVar(&opt.uid, "uid", opt.uid, "only show events for the object with this UID (useful to follow one object that is recreated with the same name)")
+	pflag.StringVar(&opt.changedBy, "changed-by", opt.changedBy, "only show updates made by this field manager (supports glob expression, e.g. \"kubectl*\")")
+	pflag.StringVar(&opt.report, "report", opt.report, "write a JSON summary of all events to this file when exiting")
+	pflag.BoolVar(&opt.summary, "summary", opt.summary, "print the number of created, updated and deleted objects per kind to stderr when exiting")
+	pflag.StringVar(&opt.saveState, "save-state", opt.saveState, "write the last known state of every object as YAML files into this directory when exiting")
+	pflag.StringVar(&opt.outputDir, "output-dir", opt.outputDir, "additionally append the output of every object to a file of its own in this directory")
+	pflag.StringVar(&opt.publish, "publish", opt.publish, "send every event as JSON to this message broker (e.g. nats://localhost:4222/stalk.events)")
+	pflag.StringVar(&opt.otelEndpoint, "otel-endpoint", opt.otelEndpoint, "OTLP/HTTP endpoint to export every event to as an OpenTelemetry log record (e.g. http://localhost:4318)")
+	pflag.StringVar(&opt.highlightRegex, "highlight-regex", opt.highlightRegex, "regular expression to highlight matching text in diffs (e.g. an image tag or error message)")
+	pflag.BoolVar(&opt.number, "number", opt.number, "prefix every event with a consecutive number (e.g. #42)")
+	pflag.StringArrayVar(&opt.until, "until", opt.until, "stop once an object fulfills this condition, e.g. status.phase=Running (can be given multiple times)")
+	pflag.BoolVar(&opt.untilAll, "until-all", opt.untilAll, "only stop once all --until conditions are fulfilled by the same object (instead of any of them)")
+	pflag.StringArrayVar(&opt.onPathChange, "on-path-change", opt.onPathChange, "only show updates that changed the value at this path (e.g. spec.replicas or {.spec.replicas}) (can be given multiple times)")
+	pflag.BoolVar(&opt.onPathChangeAll, "on-path-change-all", opt.onPathChangeAll, "only show updates that changed all --on-path-change paths (instead of any of them)")
+	pflag.BoolVar(&opt.alertOnError, "alert-on-error", opt.alertOnError, "only show events where an object became unhealthy or recovered, based on built-in checks for common kinds")
+	pflag.StringArrayVar(&opt.unhealthyWhen, "unhealthy-when", opt.unhealthyWhen, "condition that marks objects of a kind as unhealthy for --alert-on-error, replacing the built-in checks for that kind (e.g. \"Pod:status.phase=Failed\") (can be given multiple times)")
+	pflag.StringVar(&opt.scope, "scope", opt.scope, "watch namespaced resources across the whole \"cluster\" or separately in each given \"namespace\" (useful without cluster-wide permissions)")
+	pflag.BoolVar(&opt.withPV, "with-pv", opt.withPV, "show the phase and capacity of the bound PersistentVolume as part of PersistentVolumeClaims")
+	pflag.StringVar(&opt.groupKey, "group-key", opt.groupKey, "label by which events are grouped; a header is printed whenever the label's value changes (e.g. app)")
+	pflag.BoolVar(&opt.anonymize, "anonymize", opt.anonymize, "replace names, namespaces, UIDs and IP addresses with stable pseudonyms, to safely share the output")
+	pflag.DurationVar(&opt.timeout, "timeout", opt.timeout, "stop watching after this duration (e.g. 10m)")
+	pflag.DurationVar(&opt.idleTimeout, "idle-timeout", opt.idleTimeout, "stop watching once no event was shown for this duration (e.g. 30s), for example to wait until a reconciliation settles")
+	pflag.BoolVar(&opt.exitOnError, "exit-on-error", opt.exitOnError, "exit with a non-zero code on the first watch error instead of logging it and continuing")
+	pflag.BoolVar(&opt.poll, "poll", opt.poll, "periodically list resources instead of watching them (used automatically for resources that cannot be watched)")
+	pflag.DurationVar(&opt.pollInterval, "poll-interval", opt.pollInterval, "time between two list requests when polling")
+	pflag.Float32Var(&opt.qps, "qps", opt.qps, "maximum number of requests per second to the Kubernetes API (raise this for faster startup on large clusters)")
+	pflag.IntVar(&opt.burst, "burst", opt.burst, "maximum number of requests to the Kubernetes API that can exceed --qps for a short time")
 	pflag.BoolVarP(&opt.verbose, "verbose", "v", opt.verbose, "Enable more verbose output")
 	pflag.Parse()
 
@@ -72,67 +311,609 @@ func main() {
 		log.SetLevel(logrus.DebugLevel)
 	}
 
+	// plain mode must be applied before anything is rendered
+	if opt.plain && opt.forceColor {
+		log.Fatal("--plain cannot be used with --force-color.")
+	}
+
+	if opt.forceColor && opt.noColor {
+		log.Fatal("--force-color cannot be used with --no-color.")
+	}
+
+	// colors are only used on terminals, as escape codes make redirected
+	// output hard to read; $NO_COLOR is honored by the color package itself
+	switch {
+	case opt.forceColor:
+		color.Enable = true
+		color.ForceColor()
+	case opt.noColor || !term.IsTerminal(int(os.Stdout.Fd())):
+		color.Disable()
+	}
+
+	// JSON output is meant for machines, even on a terminal
+	if opt.output == diff.OutputJSON {
+		if opt.forceColor {
+			log.Fatal("--force-color cannot be used with --output json.")
+		}
+
+		if opt.number {
+			log.Fatal("--number cannot be used with --output json.")
+		}
+
+		color.Disable()
+	}
+
+	if opt.plain {
+		if opt.diffAlgorithm != diff.AlgorithmDifflib && pflag.CommandLine.Changed("diff-algorithm") {
+			log.Fatal("--plain cannot be used with --diff-algorithm=cdiff.")
+		}
+
+		opt.diffAlgorithm = diff.AlgorithmDifflib
+		color.Disable()
+		log.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: time.RFC1123,
+			DisableColors:   true,
+		})
+	}
+
 	// validate CLI flags
+	if opt.autoContext && pflag.CommandLine.Changed("context-lines") {
+		log.Fatal("--diff-context-auto cannot be used with --context-lines.")
+	}
+
 	differOpts := &diff.Options{
+		Algorithm:        opt.diffAlgorithm,
+		Output:           opt.output,
+		EventTime:        opt.eventTime,
+		Whitespace:       opt.diffWhitespace,
+		DetectMoves:      opt.detectMoves,
+		RelativeTimes:    opt.relativeTimes,
+		LineNumbers:      opt.lineNumbers,
+		CollapseArrays:   opt.collapseArrays,
+		Sectioned:        opt.sectioned,
+		StripDefaults:    opt.stripDefaults,
+		DecodeHelm:       opt.decodeHelm,
+		ExtraDefaults:    opt.extraDefaults,
 		ContextLines:     opt.contextLines,
+		AutoContext:      opt.autoContext,
 		DisableWordDiff:  true,
+		CompactTitle:     opt.compactTitle,
+		NoHeaders:        opt.noHeaders,
+		Quiet:            opt.quiet,
+		QuietField:       opt.quietField,
+		SortBy:           opt.sortBy,
+		Ticker:           opt.ticker,
+		Project:          opt.project,
+		Rollout:          opt.rollout,
+		Finalizers:       opt.finalizers,
 		ExcludePaths:     opt.hidePaths,
+		IgnorePaths:      opt.semanticIgnore,
 		IncludePaths:     opt.showPaths,
+		EventFilters:     eventFilters(&opt),
 		HideEmptyDiffs:   !opt.showEmpty,
 		JSONPath:         opt.jsonPath,
+		HighlightRegex:   opt.highlightRegex,
 		CreateColorTheme: diff.CreateColorTheme,
 		UpdateColorTheme: diff.UpdateColorTheme,
 		DeleteColorTheme: diff.DeleteColorTheme,
 	}
 
-	if opt.hideManagedFields {
-		differOpts.ExcludePaths = append(differOpts.ExcludePaths, "metadata.managedFields")
+	// the validate subcommand reports invalid expressions itself, so it has
+	// to run before the options are validated
+	if args := pflag.Args(); len(args) > 0 && args[0] == "validate" {
+		if len(args) > 2 {
+			log.Fatal("Usage: stalk validate [SAMPLE_FILE]")
+		}
+
+		sampleFile := ""
+		if len(args) == 2 {
+			sampleFile = args[1]
+		}
+
+		valid, err := validateExpressions(log, differOpts, sampleFile, os.Stdout)
+		if err != nil {
+			log.Fatalf("Failed to validate expressions: %v", err)
+		}
+
+		if !valid {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	// these paths are hidden in addition to all --hide paths
+	hidden := []string{}
+	if opt.hideManagedFields || opt.specOnly {
+		hidden = append(hidden, "metadata.managedFields")
+	}
+	if opt.specOnly {
+		hidden = append(hidden, "status")
+	}
+
+	if len(hidden) > 0 {
+		differOpts.ExcludePaths = append(differOpts.ExcludePaths, hidden...)
+
+		for _, filter := range differOpts.EventFilters {
+			if len(filter.ExcludePaths) > 0 {
+				filter.ExcludePaths = append(filter.ExcludePaths, hidden...)
+			}
+		}
 	}
 
 	if err := differOpts.Validate(); err != nil {
+		if flag := expressionFlag(err); flag != "" {
+			log.Fatalf("Invalid %s: %v", flag, err)
+		}
+
 		log.Fatalf("Invalid CLI options: %v", err)
 	}
 
 	differ, err := diff.NewDiffer(differOpts, log)
 	if err != nil {
-		log.Fatalf("Failed to create differ: %w", err)
+		log.Fatalf("Failed to create differ: %v", err)
+	}
+
+	// the context is also cancelled once an --until condition is fulfilled
+	// or once the --timeout or --idle-timeout is reached
+	rootCtx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+
+	stopOnce := sync.Once{}
+	opt.stopWatching = func() {
+		stopOnce.Do(func() {
+			log.Info("Condition fulfilled, stopping.")
+			cancel()
+		})
+	}
+
+	if opt.timeout < 0 {
+		log.Fatal("--timeout must not be negative.")
+	}
+
+	if opt.timeout > 0 {
+		time.AfterFunc(opt.timeout, func() {
+			log.Infof("Timeout of %v reached, stopping.", opt.timeout)
+			cancel()
+		})
+	}
+
+	if opt.idleTimeout < 0 {
+		log.Fatal("--idle-timeout must not be negative.")
+	}
+
+	if opt.idleTimeout > 0 && opt.watchFile != "" {
+		log.Fatal("--idle-timeout cannot be used with --watch-file.")
+	}
+
+	// the idle timer is reset by the printer whenever an event is shown
+	var onPrint func()
+	if opt.idleTimeout > 0 {
+		idleTimer := time.AfterFunc(opt.idleTimeout, func() {
+			log.Infof("No events for %v, stopping.", opt.idleTimeout)
+			cancel()
+		})
+		defer idleTimer.Stop()
+
+		onPrint = func() {
+			idleTimer.Reset(opt.idleTimeout)
+		}
+	}
+
+	var sessionReport *report.Report
+	if opt.report != "" || opt.summary {
+		sessionReport = report.New()
+		opt.sessionReport = sessionReport
+	}
+
+	if opt.saveState != "" {
+		opt.finalState = state.NewCollector()
+	}
+
+	var publisher *publish.Publisher
+	if opt.publish != "" {
+		publisher, err = publish.New(opt.publish, log)
+		if err != nil {
+			log.Fatalf("Invalid --publish: %v", err)
+		}
+	}
+
+	var exporter *otlp.Exporter
+	if opt.otelEndpoint != "" {
+		exporter, err = otlp.New(opt.otelEndpoint, log)
+		if err != nil {
+			log.Fatalf("Invalid --otel-endpoint: %v", err)
+		}
+	}
+
+	// large collections can take a while to be shown, so indicate that
+	// stalk is still busy; this would only garble redirected output
+	isTerminal := term.IsTerminal(int(os.Stdout.Fd()))
+
+	// the pager has its own ways to scroll, so the output is not redrawn;
+	// it is not used by the subcommands or --watch-file, which print to
+	// stdout themselves
+	var output io.Writer = os.Stdout
+	var outputPager *pager
+	if opt.pager && isTerminal && opt.watchFile == "" && !isSubcommand(pflag.Args()) {
+		outputPager, err = startPager(cancel)
+		if err != nil {
+			log.Fatalf("Failed to start pager: %v", err)
+		}
+
+		output = outputPager
+		isTerminal = false
+	}
+
+	isTerminal = isTerminal && !opt.plain
+
+	if opt.ticker {
+		if !isTerminal {
+			log.Fatal("--ticker requires a terminal and cannot be used with --pager or --plain.")
+		}
+
+		if opt.watchFile != "" {
+			log.Fatal("--ticker cannot be used with --watch-file.")
+		}
+	}
+
+	// the progress indicator would fight with the ticker over the same line;
+	// with --only-changes, there are no initial objects to show progress for;
+	// JSON output must only contain events
+	if isTerminal && !opt.ticker && !opt.onlyChanges && opt.output != diff.OutputJSON {
+		opt.progress = progress.New(os.Stdout)
+	}
+
+	clusterTags, err := diff.ParseClusterTags(opt.clusterTags)
+	if err != nil {
+		log.Fatalf("Invalid --cluster-tags: %v", err)
 	}
 
-	printer := diff.NewPrinter(differ, log)
+	printer := diff.NewPrinter(differ, output, &diff.PrinterOptions{
+		CorrelationWindow:      opt.correlationWindow,
+		GroupByGeneration:      opt.groupByGeneration,
+		ShowConversionWarnings: opt.conversionWarns,
+		ShowOwnerChanges:       opt.ownerChanges,
+		ShowQuantityChanges:    opt.quantityChanges,
+		MigrationKey:           opt.migrationKey,
+		RevisionAnnotations:    opt.revisionAnnots,
+		ShowReconnects:         opt.watchRestarts,
+		ClusterTags:            clusterTags,
+		Wide:                   opt.wide,
+		Number:                 opt.number,
+		Anonymize:              opt.anonymize,
+		GroupKey:               opt.groupKey,
+		Report:                 sessionReport,
+		Publisher:              publisher,
+		Exporter:               exporter,
+		OnPrint:                onPrint,
+		Progress:               opt.progress,
+		OutputDir:              opt.outputDir,
+		Redraw:                 isTerminal,
+	}, log)
 
 	if opt.kubeconfig == "" {
 		opt.kubeconfig = os.Getenv("KUBECONFIG")
 	}
 
+	if opt.kubeContext != "" && (len(opt.contexts) > 0 || opt.allContexts) {
+		log.Fatal("--context cannot be used with --contexts or --all-contexts.")
+	}
+
+	// like kubectl, use the namespace of the chosen context if none is given
+	if opt.kubeContext != "" && opt.scope == scopeNamespace && len(opt.namespaces) == 0 {
+		namespace, _, err := kubeconfigLoader(opt.kubeconfig, opt.kubeContext).Namespace()
+		if err != nil {
+			log.Fatalf("Failed to load kubeconfig: %v", err)
+		}
+
+		opt.namespaces = []string{namespace}
+	}
+
+	parsedEventTypes, err := watcher.ParseEventTypes(opt.eventTypes)
+	if err != nil {
+		log.Fatalf("Invalid --events: %v", err)
+	}
+	opt.parsedEventTypes = parsedEventTypes
+
+	for _, until := range opt.until {
+		condition, err := watcher.ParseCondition(until)
+		if err != nil {
+			log.Fatalf("Invalid --until: %v", err)
+		}
+
+		opt.parsedUntil = append(opt.parsedUntil, condition)
+	}
+
+	if opt.untilAll && len(opt.parsedUntil) == 0 {
+		log.Fatal("--until-all requires at least one --until condition.")
+	}
+
+	for _, path := range opt.onPathChange {
+		pathChange, err := watcher.ParsePathChange(path)
+		if err != nil {
+			log.Fatalf("Invalid --on-path-change: %v", err)
+		}
+
+		opt.parsedPathChanges = append(opt.parsedPathChanges, pathChange)
+	}
+
+	if opt.onPathChangeAll && len(opt.parsedPathChanges) == 0 {
+		log.Fatal("--on-path-change-all requires at least one --on-path-change path.")
+	}
+
+	if len(opt.unhealthyWhen) > 0 && !opt.alertOnError {
+		log.Fatal("--unhealthy-when requires --alert-on-error.")
+	}
+
+	if opt.alertOnError {
+		healthChecks, err := watcher.NewHealthChecks(opt.unhealthyWhen)
+		if err != nil {
+			log.Fatalf("Invalid --unhealthy-when: %v", err)
+		}
+
+		opt.healthChecks = healthChecks
+	}
+
+	if !watcher.IsValidSortOrder(opt.sortInitial) {
+		log.Fatalf("Invalid --sort-initial value %q, must be one of %v.", opt.sortInitial, watcher.SortOrders)
+	}
+
+	if opt.selectorFile != "" {
+		if opt.labels != "" {
+			log.Fatal("Cannot specify both --labels and --selector-file at the same time.")
+		}
+
+		selector, err := readSelectorFile(opt.selectorFile)
+		if err != nil {
+			log.Fatalf("Invalid --selector-file: %v", err)
+		}
+
+		log.Debugf("Using label selector %q.", selector)
+		opt.labels = selector
+	}
+
+	if opt.scope != scopeCluster && opt.scope != scopeNamespace {
+		log.Fatalf("Invalid --scope value %q, must be either %q or %q.", opt.scope, scopeCluster, scopeNamespace)
+	}
+
+	if opt.allNamespaces {
+		if len(opt.namespaces) > 0 {
+			log.Fatal("Cannot specify both --namespace and --all-namespaces at the same time.")
+		}
+
+		if opt.scope == scopeNamespace {
+			log.Fatal("--all-namespaces cannot be used in namespace scope.")
+		}
+	}
+
+	if opt.scope == scopeNamespace {
+		for _, namespace := range opt.namespaces {
+			if strings.ContainsAny(namespace, "*?[") {
+				log.Fatalf("Namespace %q cannot be a glob expression in namespace scope.", namespace)
+			}
+		}
+	}
+
+	if opt.last < 0 {
+		log.Fatal("--last must not be negative.")
+	}
+
+	if opt.onlyChanges && opt.snapshot {
+		log.Fatal("--only-changes cannot be combined with --snapshot.")
+	}
+
+	if opt.last > 0 && (opt.snapshot || opt.poll) {
+		log.Fatal("--last cannot be combined with --snapshot or --poll.")
+	}
+
+	if opt.fieldSelector != "" {
+		if _, err := fields.ParseSelector(opt.fieldSelector); err != nil {
+			log.Fatalf("Invalid --field-selector: %v", err)
+		}
+
+		if opt.watchFile != "" {
+			log.Fatal("--field-selector cannot be used with --watch-file.")
+		}
+	}
+
+	if opt.sortBy != "" && (opt.number || opt.groupKey != "") {
+		log.Fatal("--sort-by cannot be used with --number or --group-key.")
+	}
+
+	if opt.watchFile != "" && (len(opt.namespaces) > 1 || (len(opt.namespaces) == 1 && strings.ContainsAny(opt.namespaces[0], "*?["))) {
+		log.Fatal("--watch-file supports only a single namespace, which cannot be a glob expression.")
+	}
+
+	if opt.saveState != "" && opt.watchFile != "" {
+		log.Fatal("--save-state cannot be used with --watch-file.")
+	}
+
+	if opt.outputDir != "" {
+		if opt.sortBy != "" || opt.ticker || opt.watchFile != "" {
+			log.Fatal("--output-dir cannot be used with --sort-by, --ticker or --watch-file.")
+		}
+
+		if err := os.MkdirAll(opt.outputDir, 0o755); err != nil {
+			log.Fatalf("Failed to create --output-dir: %v", err)
+		}
+	}
+
+	if opt.qps <= 0 {
+		log.Fatal("--qps must be greater than zero.")
+	}
+
+	if opt.burst <= 0 {
+		log.Fatal("--burst must be greater than zero.")
+	}
+
+	if len(opt.contexts) > 0 && opt.allContexts {
+		log.Fatal("Cannot specify both --contexts and --all-contexts at the same time.")
+	}
+
+	if opt.clusterTags != "" && len(opt.contexts) == 0 && !opt.allContexts {
+		log.Fatal("--cluster-tags requires --contexts or --all-contexts.")
+	}
+
+	if opt.watchFile != "" {
+		watchFile(rootCtx, log, opt.watchFile, &opt, differ, os.Stdout)
+		return
+	}
+
 	args := pflag.Args()
 	if len(args) == 0 {
 		log.Fatal("No resource kind and name given.")
 	}
 
+	if args[0] == "diff" {
+		if opt.filename == "" || len(args) > 1 {
+			log.Fatal("Usage: stalk diff -f MANIFEST")
+		}
+
+		if err := diffManifests(rootCtx, log, opt.filename, &opt, differ, os.Stdout); err != nil {
+			log.Fatalf("Failed to diff manifests: %v", err)
+		}
+
+		return
+	}
+
+	if opt.filename != "" {
+		log.Fatal("--filename can only be used with the diff subcommand.")
+	}
+
+	if args[0] == "doctor" {
+		if !runDoctor(rootCtx, log, args[1:], &opt, os.Stdout) {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	tickerCtx, stopTicker := context.WithCancel(rootCtx)
+	if opt.ticker {
+		go refreshTicker(tickerCtx, printer)
+	}
+
 	if args[0] == "-" {
-		watchStdin(rootCtx, log, os.Stdin, printer)
+		watchStdin(rootCtx, log, os.Stdin, &opt, printer)
 	} else {
 		watchKubernetes(rootCtx, log, args, &opt, printer)
 	}
+
+	stopTicker()
+	printer.EndTicker()
+
+	// an interrupt can arrive while a colored line is being written, so
+	// make sure the terminal is back to its normal colors
+	if signalCtx.Err() != nil && outputPager == nil {
+		if err := color.ResetTerminal(); err != nil {
+			log.Debugf("Failed to reset terminal: %v", err)
+		}
+	}
+
+	if publisher != nil {
+		if err := publisher.Close(publishTimeout); err != nil {
+			log.Warnf("Failed to close connection to message broker: %v", err)
+		}
+	}
+
+	if exporter != nil {
+		if err := exporter.Close(publishTimeout); err != nil {
+			log.Warnf("Failed to export events: %v", err)
+		}
+	}
+
+	if opt.summary {
+		if err := sessionReport.WriteSummary(os.Stderr); err != nil {
+			log.Warnf("Failed to print summary: %v", err)
+		}
+	}
+
+	if opt.report != "" {
+		if err := sessionReport.WriteFile(opt.report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+
+	if opt.finalState != nil {
+		written, err := opt.finalState.WriteDir(opt.saveState)
+		if err != nil {
+			log.Fatalf("Failed to save final state: %v", err)
+		}
+
+		log.Infof("Saved the final state of %d objects to %s.", written, opt.saveState)
+	}
+
+	if outputPager != nil {
+		outputPager.Close()
+	}
 }
 
-func watchStdin(ctx context.Context, log logrus.FieldLogger, input io.Reader, printer *diff.Printer) {
-	decoder := yamlutil.NewYAMLOrJSONDecoder(input, 1024)
+// refreshTicker redraws the ticker line every second until the context is
+// cancelled, so that the time since the last change stays current.
+func refreshTicker(ctx context.Context, printer *diff.Printer) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
 	for {
-		object := unstructured.Unstructured{}
-		err := decoder.Decode(&object)
-		if err != nil {
-			if err == io.EOF {
-				break
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			printer.RefreshTicker()
+		}
+	}
+}
+
+// isSubcommand returns true if the arguments start with one of the
+// subcommands, which do not watch resources.
+func isSubcommand(args []string) bool {
+	return len(args) > 0 && (args[0] == "diff" || args[0] == "doctor")
+}
+
+func watchStdin(ctx context.Context, log logrus.FieldLogger, input io.Reader, appOpts *options, printer *diff.Printer) {
+	w := watcher.NewWatcher(&watcher.Options{
+		IncludeNamespaces: appOpts.includeNamespaces,
+		ExcludeNamespaces: appOpts.excludeNamespaces,
+		EventTypes:        appOpts.parsedEventTypes,
+		UID:               types.UID(appOpts.uid),
+		ChangedBy:         appOpts.changedBy,
+		GenerationOnly:    appOpts.generationOnly,
+		PathChanges:       appOpts.parsedPathChanges,
+		PathChangesAll:    appOpts.onPathChangeAll,
+		HealthChecks:      appOpts.healthChecks,
+		Until:             appOpts.parsedUntil,
+		UntilAll:          appOpts.untilAll,
+		Stop:              appOpts.stopWatching,
+	})
+
+	if appOpts.finalState != nil {
+		appOpts.finalState.Add(w, printer.KeyPrefix())
+	}
+
+	go func() {
+		defer w.Close()
+
+		decoder := yamlutil.NewYAMLOrJSONDecoder(input, 1024)
+
+		for ctx.Err() == nil {
+			object := unstructured.Unstructured{}
+			err := decoder.Decode(&object)
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+
+				log.Errorf("Failed to decode YAML object: %v", err)
+				continue
 			}
 
-			log.Errorf("Failed to decode YAML object: %v", err)
-			continue
+			w.Process(watch.Modified, &object)
 		}
+	}()
 
-		printer.Print(&object, watch.Modified)
-	}
+	printer.PrintEvents(w.Events())
 }
 
 func watchKubernetes(ctx context.Context, log logrus.FieldLogger, args []string, appOpts *options, printer *diff.Printer) {
@@ -154,15 +935,170 @@ func watchKubernetes(ctx context.Context, log logrus.FieldLogger, args []string,
 		log.Fatal("Cannot specify both resource names and a label selector at the same time.")
 	}
 
-	// setup kubernetes client
-	config, err := clientcmd.BuildConfigFromFlags("", appOpts.kubeconfig)
-	if err != nil {
-		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	wg := sync.WaitGroup{}
+
+	if len(appOpts.contexts) == 0 && !appOpts.allContexts {
+		// setup kubernetes client
+		config, err := kubeconfigLoader(appOpts.kubeconfig, appOpts.kubeContext).ClientConfig()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+
+		applyRateLimits(config, appOpts)
+
+		// an --until condition can be fulfilled while the watches are still
+		// being set up, which is not an error
+		if err := startWatches(ctx, log, config, resourceKinds, resourceNames, appOpts, printer, &wg); err != nil && ctx.Err() == nil {
+			log.Fatalf("Failed to watch resources: %v", err)
+		}
+	} else {
+		contexts := appOpts.contexts
+		if appOpts.allContexts {
+			var err error
+
+			contexts, err = kubeconfigContexts(appOpts.kubeconfig)
+			if err != nil {
+				log.Fatalf("Failed to load kubeconfig: %v", err)
+			}
+		}
+
+		startMultiClusterWatches(ctx, log, contexts, resourceKinds, resourceNames, appOpts, printer, &wg)
+	}
+
+	wg.Wait()
+}
+
+// startMultiClusterWatches starts the same watches in all given kubeconfig
+// contexts. Failures in one context do not affect the others.
+func startMultiClusterWatches(ctx context.Context, log logrus.FieldLogger, contexts []string, resourceKinds []string, resourceNames []string, appOpts *options, printer *diff.Printer, wg *sync.WaitGroup) {
+	// setting up a cluster involves discovery, which is expensive enough to not
+	// do it for dozens of clusters at the same time
+	semaphore := make(chan struct{}, maxConcurrentClusterSetups)
+	setupWG := sync.WaitGroup{}
+
+	for _, contextName := range contexts {
+		setupWG.Add(1)
+
+		go func(contextName string) {
+			defer setupWG.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			contextLog := log.WithField("context", contextName)
+
+			config, err := kubeconfigLoader(appOpts.kubeconfig, contextName).ClientConfig()
+			if err != nil {
+				logSetupError(contextLog, appOpts, "Failed to create Kubernetes client: %v", err)
+				return
+			}
+
+			applyRateLimits(config, appOpts)
+
+			if err := startWatches(ctx, contextLog, config, resourceKinds, resourceNames, appOpts, printer.WithKeyPrefix(contextName), wg); err != nil && ctx.Err() == nil {
+				logSetupError(contextLog, appOpts, "Failed to watch resources: %v", err)
+			}
+		}(contextName)
 	}
 
+	setupWG.Wait()
+}
+
+// eventFilters returns the --create-*, --update-* and --delete-* paths
+// for the types of events where any were given.
+func eventFilters(appOpts *options) map[watch.EventType]*diff.EventFilter {
+	filters := map[watch.EventType]*diff.EventFilter{}
+
+	add := func(eventType watch.EventType, includePaths, excludePaths []string) {
+		if len(includePaths) > 0 || len(excludePaths) > 0 {
+			filters[eventType] = &diff.EventFilter{
+				IncludePaths: includePaths,
+				ExcludePaths: excludePaths,
+			}
+		}
+	}
+
+	add(watch.Added, appOpts.createShowPaths, appOpts.createHidePaths)
+	add(watch.Modified, appOpts.updateShowPaths, appOpts.updateHidePaths)
+	add(watch.Deleted, appOpts.deleteShowPaths, appOpts.deleteHidePaths)
+
+	return filters
+}
+
+// expressionFlag returns the name of the flag that contained the invalid
+// expression, if err is about an expression.
+func expressionFlag(err error) string {
+	var exprErr *diff.ExpressionError
+	if !errors.As(err, &exprErr) {
+		return ""
+	}
+
+	prefix := ""
+	switch exprErr.EventType {
+	case watch.Added:
+		prefix = "create-"
+	case watch.Modified:
+		prefix = "update-"
+	case watch.Deleted:
+		prefix = "delete-"
+	}
+
+	switch exprErr.Kind {
+	case diff.ErrInvalidJSONPath:
+		return "--jsonpath"
+	case diff.ErrInvalidQuietField:
+		return "--quiet-field"
+	case diff.ErrInvalidHighlight:
+		return "--highlight-regex"
+	case diff.ErrInvalidIncludePath:
+		return "--" + prefix + "show"
+	case diff.ErrInvalidExcludePath:
+		return "--" + prefix + "hide"
+	case diff.ErrInvalidDefaultField:
+		return "--extra-default"
+	case diff.ErrInvalidProjection:
+		return "--project"
+	case diff.ErrInvalidSortBy:
+		return "--sort-by"
+	case diff.ErrInvalidIgnorePath:
+		return "--semantic-ignore"
+	}
+
+	return ""
+}
+
+// logSetupError logs a failure to set up the watches in one of many
+// clusters. Unless --exit-on-error is given, the other clusters are still
+// watched.
+func logSetupError(log logrus.FieldLogger, appOpts *options, format string, args ...interface{}) {
+	if appOpts.exitOnError {
+		log.Fatalf(format, args...)
+	}
+
+	log.Errorf(format, args...)
+}
+
+// watchErrorHandler returns the function that is called for errors reported
+// by running watches. The errors are logged, unless --exit-on-error is given,
+// in which case stalk exits immediately.
+func watchErrorHandler(log logrus.FieldLogger, appOpts *options) func(error) {
+	return func(err error) {
+		if appOpts.exitOnError {
+			log.Fatalf("Watch failed: %v", err)
+		}
+
+		log.Warnf("Watch failed: %v", err)
+	}
+}
+
+// startWatches resolves the resource kinds in a cluster and starts watching
+// them. Every watch runs in its own goroutine, tracked in wg. Kinds that
+// cannot be watched are skipped (unless --exit-on-error is given), and an
+// error is only returned if no kind could be watched at all.
+func startWatches(ctx context.Context, log logrus.FieldLogger, config *rest.Config, resourceKinds []string, resourceNames []string, appOpts *options, printer *diff.Printer, wg *sync.WaitGroup) error {
 	resolver, err := kubeutil.NewResolver(config, log)
 	if err != nil {
-		log.Fatalf("Failed to create Kubernetes REST mapper: %v", err)
+		return fmt.Errorf("failed to create Kubernetes REST mapper: %w", err)
 	}
 
 	// validate resource kinds
@@ -174,10 +1110,10 @@ func watchKubernetes(ctx context.Context, log logrus.FieldLogger, args []string,
 
 		parsed, err := resolver.Resolve(resourceKind)
 		if err != nil {
-			log.Fatalf("Unknown resource kind %q: %v", resourceKind, err)
+			return fmt.Errorf("unknown resource kind %q: %w", resourceKind, err)
 		}
 		if parsed == nil {
-			log.Fatalf("Unknown resource kind %q", resourceKind)
+			return fmt.Errorf("unknown resource kind %q", resourceKind)
 		}
 
 		gvk := parsed.GroupVersionKind
@@ -190,31 +1126,325 @@ func watchKubernetes(ctx context.Context, log logrus.FieldLogger, args []string,
 		}).Debug("Resolved")
 	}
 
+	// drop kinds the user is not interested in
+	for _, excludedKind := range appOpts.excludeKinds {
+		parsed, err := resolver.Resolve(strings.ToLower(excludedKind))
+		if err != nil {
+			return fmt.Errorf("unknown resource kind %q: %w", excludedKind, err)
+		}
+		if parsed == nil {
+			return fmt.Errorf("unknown resource kind %q", excludedKind)
+		}
+
+		for key, gvk := range kinds {
+			if gvk.GroupKind() == parsed.GroupVersionKind.GroupKind() {
+				log.Debugf("Excluding %s.", gvk.Kind)
+				delete(kinds, key)
+			}
+		}
+	}
+
+	if len(kinds) == 0 {
+		return errors.New("all resource kinds have been excluded, nothing to watch")
+	}
+
 	// setup watches for each kind
 	log.Debug("Starting to watch resources...")
 
-	wg := sync.WaitGroup{}
-	w := watcher.NewWatcher(printer, appOpts.namespaces, resourceNames)
+	w := watcher.NewWatcher(&watcher.Options{
+		Namespaces:        appOpts.namespaces,
+		ResourceNames:     resourceNames,
+		IncludeNamespaces: appOpts.includeNamespaces,
+		ExcludeNamespaces: appOpts.excludeNamespaces,
+		EventTypes:        appOpts.parsedEventTypes,
+		UID:               types.UID(appOpts.uid),
+		ChangedBy:         appOpts.changedBy,
+		GenerationOnly:    appOpts.generationOnly,
+		PathChanges:       appOpts.parsedPathChanges,
+		PathChangesAll:    appOpts.onPathChangeAll,
+		HealthChecks:      appOpts.healthChecks,
+		Until:             appOpts.parsedUntil,
+		UntilAll:          appOpts.untilAll,
+		Stop:              appOpts.stopWatching,
+		OnError:           watchErrorHandler(log, appOpts),
+
+		SnapshotProgress: snapshotProgress(appOpts),
+	})
+
+	if appOpts.finalState != nil {
+		appOpts.finalState.Add(w, printer.KeyPrefix())
+	}
+
+	wg.Add(1)
+	go func() {
+		printer.PrintEvents(w.Events())
+		wg.Done()
+	}()
+
+	// close the watcher once all watches have ended (or none could be
+	// started), so that the printer can finish
+	watchWG := sync.WaitGroup{}
+	defer func() {
+		go func() {
+			watchWG.Wait()
+			w.Close()
+		}()
+	}()
+
+	failed := 0
 
 	for _, gvk := range kinds {
-		dynamicInterface, err := resolver.ResourceInterfaceFor(gvk)
+		kindLog := log.WithField("kind", gvk.Kind)
+
+		// every watch runs in its own goroutine, isolated from the others
+		run := func(fn func()) {
+			watchWG.Add(1)
+			go func() {
+				defer watchWG.Done()
+				isolate(kindLog, fn)
+			}()
+		}
+
+		if err := startKindWatches(ctx, log, resolver, gvk, appOpts, w, run); err != nil {
+			if appOpts.exitOnError || ctx.Err() != nil {
+				return err
+			}
+
+			// other kinds can still be watched
+			kindLog.Errorf("Failed to watch resources, skipping them: %v", err)
+			failed++
+		}
+	}
+
+	if failed == len(kinds) {
+		return errors.New("none of the resource kinds could be watched")
+	}
+
+	for _, name := range w.MissingNames() {
+		log.Debugf("No object matches %q yet, waiting for it to be created.", name)
+	}
+
+	return nil
+}
+
+// startKindWatches starts watching all resources of a single kind. Every
+// watch is started using run.
+func startKindWatches(ctx context.Context, log logrus.FieldLogger, resolver *kubeutil.Resolver, gvk schema.GroupVersionKind, appOpts *options, w *watcher.Watcher, run func(func())) error {
+	transforms := []watcher.TransformFunc{}
+
+	if appOpts.scale {
+		supported, err := resolver.SupportsScale(gvk)
 		if err != nil {
-			log.Fatalf("Failed to create dynamic interface for %q resources: %v", gvk.Kind, err)
+			return fmt.Errorf("failed to determine whether %q resources can be scaled: %w", gvk.Kind, err)
 		}
+		if !supported {
+			return fmt.Errorf("%q resources do not have a scale subresource", gvk.Kind)
+		}
+
+		transforms = append(transforms, watcher.ScaleTransform(func(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			client, err := resolver.NamespacedResourceInterfaceFor(gvk, obj.GetNamespace())
+			if err != nil {
+				return nil, err
+			}
 
-		wi, err := dynamicInterface.Watch(ctx, metav1.ListOptions{
+			return client.Get(ctx, obj.GetName(), metav1.GetOptions{}, "scale")
+		}, log))
+	}
+
+	if appOpts.withPV && gvk.GroupKind() == persistentVolumeClaimKind {
+		volumeClient, err := resolver.ResourceInterfaceFor(persistentVolumeKind)
+		if err != nil {
+			return fmt.Errorf("failed to create dynamic interface for PersistentVolumes: %w", err)
+		}
+
+		transforms = append(transforms, watcher.BoundVolumeTransform(func(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+			return volumeClient.Get(ctx, name, metav1.GetOptions{})
+		}, log))
+	}
+
+	transform := watcher.ChainTransforms(transforms...)
+
+	clients, err := resourceInterfacesFor(resolver, gvk, appOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic interface for %q resources: %w", gvk.Kind, err)
+	}
+
+	watchOpts := &engine.WatchOptions{
+		ListOptions: metav1.ListOptions{
 			LabelSelector: appOpts.labels,
-		})
+			FieldSelector: appOpts.fieldSelector,
+		},
+		Transform:   transform,
+		Snapshot:    appOpts.snapshot,
+		OnlyChanges: appOpts.onlyChanges,
+		SortInitial: appOpts.sortInitial,
+		Last:        appOpts.last,
+		OnReconnect: recordReconnect(appOpts),
+	}
+
+	if !appOpts.snapshot && shouldPoll(log, resolver, gvk, appOpts) {
+		watchOpts.PollInterval = appOpts.pollInterval
+	}
+
+	if appOpts.progress != nil {
+		watchOpts.OnSnapshot = appOpts.progress.Add
+	}
+
+	for _, client := range clients {
+		if err := engine.StartWatch(ctx, log, client, gvk, w, watchOpts, run); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// snapshotProgress returns the function to advance the progress indicator
+// while the initially existing objects are shown, if any.
+func snapshotProgress(appOpts *options) func() {
+	if appOpts.progress == nil {
+		return nil
+	}
+
+	return appOpts.progress.Step
+}
+
+// recordReconnect returns the function to count re-established watches in
+// the session report, if any.
+func recordReconnect(appOpts *options) func() {
+	if appOpts.sessionReport == nil {
+		return nil
+	}
+
+	return appOpts.sessionReport.RecordReconnect
+}
+
+// isolate runs fn and recovers from panics, so that a failure while watching
+// one kind does not take down the watches of all other kinds.
+func isolate(log logrus.FieldLogger, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Watch failed, giving up on these resources: %v", r)
+		}
+	}()
+
+	fn()
+}
+
+// resourceInterfacesFor returns the clients to list and watch resources
+// with. In cluster scope, a single client for all namespaces is used and
+// the namespaces are filtered by the watcher. In namespace scope, one client
+// for each of the given namespaces is returned.
+func resourceInterfacesFor(resolver *kubeutil.Resolver, gvk schema.GroupVersionKind, appOpts *options) ([]engine.Client, error) {
+	if appOpts.scope != scopeNamespace {
+		client, err := resolver.ResourceInterfaceFor(gvk)
 		if err != nil {
-			log.Fatalf("Failed to create watch for %q resources: %v", gvk.Kind, err)
+			return nil, err
 		}
 
-		wg.Add(1)
-		go func() {
-			w.Watch(ctx, wi)
-			wg.Done()
-		}()
+		return []engine.Client{{ResourceInterface: client}}, nil
 	}
 
-	wg.Wait()
+	namespaced, err := resolver.IsNamespaced(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	if !namespaced {
+		return nil, fmt.Errorf("%q resources are cluster-scoped and cannot be watched in namespace scope", gvk.Kind)
+	}
+
+	namespaces := appOpts.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceDefault}
+	}
+
+	clients := []engine.Client{}
+	for _, namespace := range namespaces {
+		client, err := resolver.NamespacedResourceInterfaceFor(gvk, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, engine.Client{ResourceInterface: client, Namespace: namespace})
+	}
+
+	return clients, nil
+}
+
+// readSelectorFile reads a structured label selector, like it is used in
+// Deployments, and returns its string representation.
+func readSelectorFile(filename string) (string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	labelSelector := metav1.LabelSelector{}
+	if err := yaml.UnmarshalStrict(content, &labelSelector); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return "", fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	if selector.Empty() {
+		return "", errors.New("label selector must not be empty")
+	}
+
+	return selector.String(), nil
+}
+
+// applyRateLimits configures the client-side rate limiting. Discovery and
+// setting up many watches can require lots of requests, which are delayed
+// by the rate limiter.
+func applyRateLimits(config *rest.Config, appOpts *options) {
+	config.QPS = appOpts.qps
+	config.Burst = appOpts.burst
+}
+
+func kubeconfigLoader(kubeconfig string, contextName string) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{
+		CurrentContext: contextName,
+	})
+}
+
+// kubeconfigContexts returns the names of all contexts in the kubeconfig.
+func kubeconfigContexts(kubeconfig string) ([]string, error) {
+	raw, err := kubeconfigLoader(kubeconfig, "").RawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := []string{}
+	for name := range raw.Contexts {
+		contexts = append(contexts, name)
+	}
+
+	sort.Strings(contexts)
+
+	return contexts, nil
+}
+
+func shouldPoll(log logrus.FieldLogger, resolver *kubeutil.Resolver, gvk schema.GroupVersionKind, appOpts *options) bool {
+	if appOpts.poll {
+		return true
+	}
+
+	supported, err := resolver.SupportsWatch(gvk)
+	if err != nil {
+		log.Debugf("Cannot determine whether %q resources can be watched, assuming they can: %v", gvk.Kind, err)
+		return false
+	}
+
+	if !supported {
+		log.Debugf("%q resources cannot be watched, polling every %v instead.", gvk.Kind, appOpts.pollInterval)
+	}
+
+	return !supported
 }
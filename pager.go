@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// defaultPager is used if $PAGER is not set.
+const defaultPager = "less"
+
+// pager pipes the output through an external pager like less, so that
+// users can scroll back through long sessions.
+type pager struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	exited chan struct{}
+}
+
+// startPager starts $PAGER (or less), which writes to stdout. onExit is
+// called once the pager has exited, e.g. because the user quit it.
+func startPager(onExit func()) (*pager, error) {
+	command := strings.Fields(os.Getenv("PAGER"))
+	if len(command) == 0 {
+		command = []string{defaultPager}
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// let less pass through the colors instead of escaping them
+	cmd.Env = os.Environ()
+	if _, exists := os.LookupEnv("LESS"); !exists {
+		cmd.Env = append(cmd.Env, "LESS=R")
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %q: %w", command[0], err)
+	}
+
+	p := &pager{
+		cmd:    cmd,
+		stdin:  stdin,
+		exited: make(chan struct{}),
+	}
+
+	go func() {
+		_ = cmd.Wait()
+		close(p.exited)
+		onExit()
+	}()
+
+	return p, nil
+}
+
+// Write sends the data to the pager. Once the pager has exited, all data
+// is discarded.
+func (p *pager) Write(data []byte) (int, error) {
+	n, err := p.stdin.Write(data)
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+		return len(data), nil
+	}
+
+	return n, err
+}
+
+// Close signals the end of the output and waits for the user to quit the
+// pager.
+func (p *pager) Close() {
+	p.stdin.Close()
+	<-p.exited
+}
@@ -1,16 +1,20 @@
 package cache
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
+	"go.xrstf.de/stalk/pkg/objectkey"
+
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type cacheItem struct {
 	resource *unstructured.Unstructured
 	lastSeen time.Time
+	// changes counts how often the object was updated after it was
+	// first stored.
+	changes int
 }
 
 type ResourceCache struct {
@@ -29,7 +33,7 @@ func (rc *ResourceCache) Get(obj *unstructured.Unstructured) (*unstructured.Unst
 	rc.lock.RLock()
 	defer rc.lock.RUnlock()
 
-	existing, exists := rc.resources[rc.objectKey(obj)]
+	existing, exists := rc.resources[objectkey.Qualified(obj)]
 	if !exists {
 		return nil, time.Time{}
 	}
@@ -41,19 +45,45 @@ func (rc *ResourceCache) Set(obj *unstructured.Unstructured) {
 	rc.lock.Lock()
 	defer rc.lock.Unlock()
 
-	rc.resources[rc.objectKey(obj)] = cacheItem{
+	key := objectkey.Qualified(obj)
+
+	changes := 0
+	if existing, exists := rc.resources[key]; exists {
+		changes = existing.changes + 1
+	}
+
+	rc.resources[key] = cacheItem{
 		resource: obj.DeepCopy(),
 		lastSeen: time.Now(),
+		changes:  changes,
 	}
 }
 
+// Changes returns how often the object has been updated since it was
+// first stored.
+func (rc *ResourceCache) Changes(obj *unstructured.Unstructured) int {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+
+	return rc.resources[objectkey.Qualified(obj)].changes
+}
+
 func (rc *ResourceCache) Delete(obj *unstructured.Unstructured) {
 	rc.lock.Lock()
 	defer rc.lock.Unlock()
 
-	delete(rc.resources, rc.objectKey(obj))
+	delete(rc.resources, objectkey.Qualified(obj))
 }
 
-func (rc *ResourceCache) objectKey(obj *unstructured.Unstructured) string {
-	return fmt.Sprintf("%s/%s/%s", obj.GroupVersionKind().String(), obj.GetNamespace(), obj.GetName())
+// Objects returns copies of all stored objects.
+func (rc *ResourceCache) Objects() []*unstructured.Unstructured {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+
+	objects := make([]*unstructured.Unstructured, 0, len(rc.resources))
+	for _, item := range rc.resources {
+		objects = append(objects, item.resource.DeepCopy())
+	}
+
+	return objects
 }
@@ -0,0 +1,115 @@
+package correlation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Correlator groups events that are likely part of the same action, like a
+// Deployment update and the ReplicaSet creation following it. Two objects
+// are correlated if one owns the other (or both have the same owner) and
+// both changed within the configured time window.
+type Correlator struct {
+	window  time.Duration
+	now     func() time.Time
+	entries map[types.UID]entry
+	nextID  int
+	lock    sync.Mutex
+}
+
+// entry links an object or owner to its group.
+type entry struct {
+	group    *group
+	lastSeen time.Time
+}
+
+// group is a set of related objects and when they changed last. It only
+// gets an ID once it contains more than one object, so that unrelated events
+// are not tagged. Objects that did not change within the time window are
+// not part of the group anymore.
+type group struct {
+	id      string
+	objects map[types.UID]time.Time
+}
+
+func NewCorrelator(window time.Duration) *Correlator {
+	return &Correlator{
+		window:  window,
+		now:     time.Now,
+		entries: map[types.UID]entry{},
+		nextID:  1,
+	}
+}
+
+// Correlate returns the correlation ID for the given object, or an empty
+// string if no related object changed within the time window. As events
+// are printed right away, the first event of a group is never tagged; its
+// ID is only assigned once a related event follows.
+func (c *Correlator) Correlate(obj *unstructured.Unstructured) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	c.expire(now)
+
+	var g *group
+
+	// prefer the owner's group, so that children join their parent's action
+	for _, ref := range obj.GetOwnerReferences() {
+		if e, exists := c.entries[ref.UID]; exists {
+			g = e.group
+			break
+		}
+	}
+
+	if g == nil {
+		if e, exists := c.entries[obj.GetUID()]; exists {
+			g = e.group
+		}
+	}
+
+	if g == nil {
+		g = &group{objects: map[types.UID]time.Time{}}
+	}
+
+	g.objects[obj.GetUID()] = now
+	g.expire(now, c.window)
+
+	// remember the object and its owners, so that siblings and later
+	// children can join the group as well
+	c.entries[obj.GetUID()] = entry{group: g, lastSeen: now}
+	for _, ref := range obj.GetOwnerReferences() {
+		c.entries[ref.UID] = entry{group: g, lastSeen: now}
+	}
+
+	if len(g.objects) < 2 {
+		return ""
+	}
+
+	if g.id == "" {
+		g.id = fmt.Sprintf("#%d", c.nextID)
+		c.nextID++
+	}
+
+	return g.id
+}
+
+func (c *Correlator) expire(now time.Time) {
+	for uid, e := range c.entries {
+		if now.Sub(e.lastSeen) > c.window {
+			delete(c.entries, uid)
+		}
+	}
+}
+
+func (g *group) expire(now time.Time, window time.Duration) {
+	for uid, lastSeen := range g.objects {
+		if now.Sub(lastSeen) > window {
+			delete(g.objects, uid)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package correlation
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCorrelate(t *testing.T) {
+	newObject := func(uid string, owners ...string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetUID(types.UID(uid))
+
+		refs := []metav1.OwnerReference{}
+		for _, owner := range owners {
+			refs = append(refs, metav1.OwnerReference{UID: types.UID(owner)})
+		}
+		obj.SetOwnerReferences(refs)
+
+		return obj
+	}
+
+	type event struct {
+		obj      *unstructured.Unstructured
+		after    time.Duration
+		expected string
+	}
+
+	testcases := []struct {
+		name   string
+		events []event
+	}{
+		{
+			name: "unrelated objects are not tagged",
+			events: []event{
+				{obj: newObject("deployment")},
+				{obj: newObject("service")},
+			},
+		},
+		{
+			name: "repeated events of a single object are not tagged",
+			events: []event{
+				{obj: newObject("deployment")},
+				{obj: newObject("deployment"), after: time.Second},
+			},
+		},
+		{
+			name: "owner and child",
+			events: []event{
+				{obj: newObject("deployment")},
+				{obj: newObject("replicaset", "deployment"), after: time.Second, expected: "#1"},
+				{obj: newObject("pod", "replicaset"), after: time.Second, expected: "#1"},
+			},
+		},
+		{
+			name: "child before owner",
+			events: []event{
+				{obj: newObject("replicaset", "deployment")},
+				{obj: newObject("deployment"), after: time.Second, expected: "#1"},
+			},
+		},
+		{
+			name: "siblings",
+			events: []event{
+				{obj: newObject("pod-1", "replicaset")},
+				{obj: newObject("pod-2", "replicaset"), after: time.Second, expected: "#1"},
+			},
+		},
+		{
+			name: "outside of the time window",
+			events: []event{
+				{obj: newObject("deployment")},
+				{obj: newObject("replicaset", "deployment"), after: 10 * time.Second},
+			},
+		},
+		{
+			// the group lives on through the ReplicaSet, but the Deployment
+			// has not changed for too long to be related
+			name: "members expire",
+			events: []event{
+				{obj: newObject("deployment")},
+				{obj: newObject("replicaset", "deployment"), after: time.Second, expected: "#1"},
+				{obj: newObject("replicaset", "deployment"), after: 3 * time.Second, expected: "#1"},
+				{obj: newObject("replicaset", "deployment"), after: 3 * time.Second},
+				{obj: newObject("pod", "replicaset"), after: time.Second, expected: "#1"},
+			},
+		},
+		{
+			name: "separate groups get separate IDs",
+			events: []event{
+				{obj: newObject("deployment-a")},
+				{obj: newObject("replicaset-a", "deployment-a"), expected: "#1"},
+				{obj: newObject("deployment-b")},
+				{obj: newObject("replicaset-b", "deployment-b"), expected: "#2"},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+
+			c := NewCorrelator(5 * time.Second)
+			c.now = func() time.Time {
+				return now
+			}
+
+			for i, e := range testcase.events {
+				now = now.Add(e.after)
+
+				if id := c.Correlate(e.obj); id != e.expected {
+					t.Errorf("Expected event %d to have ID %q, but got %q.", i, e.expected, id)
+				}
+			}
+		})
+	}
+}
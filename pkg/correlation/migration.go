@@ -0,0 +1,95 @@
+package correlation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MigrationTracker links the deletion of an object to the creation of an
+// object of another kind that shares the same value for a label. This
+// happens when a controller migrates objects from one API to another.
+type MigrationTracker struct {
+	label   string
+	window  time.Duration
+	created map[string]sighting
+	deleted map[string]sighting
+	lock    sync.Mutex
+}
+
+type sighting struct {
+	kind string
+	name string
+	seen time.Time
+}
+
+func (s sighting) String() string {
+	return fmt.Sprintf("%s/%s", s.kind, s.name)
+}
+
+func NewMigrationTracker(label string, window time.Duration) *MigrationTracker {
+	return &MigrationTracker{
+		label:   label,
+		window:  window,
+		created: map[string]sighting{},
+		deleted: map[string]sighting{},
+	}
+}
+
+// Created records the creation of the object and returns the kind and name
+// of the object it was migrated from, e.g. "OldWidget/foo", if an object
+// of another kind with the same label value has recently been deleted.
+func (m *MigrationTracker) Created(obj *unstructured.Unstructured) (string, bool) {
+	return m.record(obj, m.created, m.deleted)
+}
+
+// Deleted records the deletion of the object and returns the kind and name
+// of the object it was migrated to, if an object of another kind with the
+// same label value has recently been created.
+func (m *MigrationTracker) Deleted(obj *unstructured.Unstructured) (string, bool) {
+	return m.record(obj, m.deleted, m.created)
+}
+
+func (m *MigrationTracker) record(obj *unstructured.Unstructured, own, other map[string]sighting) (string, bool) {
+	value, ok := obj.GetLabels()[m.label]
+	if !ok || value == "" {
+		return "", false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	m.expire(now)
+
+	current := sighting{
+		kind: obj.GetKind(),
+		name: obj.GetName(),
+		seen: now,
+	}
+
+	own[value] = current
+
+	// objects that are recreated with the same kind are no migration
+	counterpart, exists := other[value]
+	if !exists || counterpart.kind == current.kind {
+		return "", false
+	}
+
+	delete(other, value)
+	delete(own, value)
+
+	return counterpart.String(), true
+}
+
+func (m *MigrationTracker) expire(now time.Time) {
+	for _, sightings := range []map[string]sighting{m.created, m.deleted} {
+		for value, s := range sightings {
+			if now.Sub(s.seen) > m.window {
+				delete(sightings, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package correlation
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMigrationTracker(t *testing.T) {
+	newObject := func(kind, name, app string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetLabels(map[string]string{"app": app})
+
+		return obj
+	}
+
+	tracker := NewMigrationTracker("app", time.Minute)
+
+	if _, ok := tracker.Created(newObject("NewWidget", "foo", "foo")); ok {
+		t.Fatal("Expected first creation not to be linked to anything.")
+	}
+
+	if to, ok := tracker.Deleted(newObject("OldWidget", "foo-legacy", "foo")); !ok || to != "NewWidget/foo" {
+		t.Errorf("Expected deletion to be linked to %q, but got %q.", "NewWidget/foo", to)
+	}
+
+	// recreating an object of the same kind is no migration
+	tracker.Deleted(newObject("OldWidget", "bar", "bar"))
+	if from, ok := tracker.Created(newObject("OldWidget", "bar", "bar")); ok {
+		t.Errorf("Expected recreation not to be linked, but got %q.", from)
+	}
+
+	tracker.Deleted(newObject("OldWidget", "baz", "baz"))
+	if from, ok := tracker.Created(newObject("NewWidget", "baz", "baz")); !ok || from != "OldWidget/baz" {
+		t.Errorf("Expected creation to be linked to %q, but got %q.", "OldWidget/baz", from)
+	}
+}
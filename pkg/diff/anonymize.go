@@ -0,0 +1,147 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// identifierToken matches everything that could be a name, namespace,
+	// UID or IP address.
+	identifierToken = regexp.MustCompile(`[A-Za-z0-9_.-]+`)
+	ipv4Address     = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+)
+
+// anonymizer consistently replaces identifiers with pseudonyms, like
+// "ns-1" for namespaces or "pod-a" for pod names. It learns the identifiers
+// from the objects it is shown, IP addresses are detected automatically.
+type anonymizer struct {
+	lock       sync.Mutex
+	pseudonyms map[string]string
+	counters   map[string]int
+}
+
+func newAnonymizer() *anonymizer {
+	return &anonymizer{
+		pseudonyms: map[string]string{},
+		counters:   map[string]int{},
+	}
+}
+
+// Learn registers the identifiers of the object.
+func (a *anonymizer) Learn(obj *unstructured.Unstructured, keyPrefix string) {
+	if obj == nil {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if keyPrefix != "" {
+		a.add(keyPrefix, a.numbered("cluster"), "cluster")
+	}
+
+	if ns := obj.GetNamespace(); ns != "" {
+		a.add(ns, a.numbered("ns"), "ns")
+	}
+
+	a.addName(obj.GetName(), obj.GetKind())
+	a.addUID(string(obj.GetUID()))
+
+	for _, ref := range obj.GetOwnerReferences() {
+		a.addName(ref.Name, ref.Kind)
+		a.addUID(string(ref.UID))
+	}
+
+	if nodeName, _, _ := unstructured.NestedString(obj.Object, "spec", "nodeName"); nodeName != "" {
+		a.addName(nodeName, "Node")
+	}
+}
+
+// Anonymize replaces all known identifiers and IP addresses in the text.
+// Color codes are left untouched.
+func (a *anonymizer) Anonymize(text string) string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var builder strings.Builder
+
+	pos := 0
+	for _, loc := range ansiSequence.FindAllStringIndex(text, -1) {
+		builder.WriteString(a.replaceTokens(text[pos:loc[0]]))
+		builder.WriteString(text[loc[0]:loc[1]])
+		pos = loc[1]
+	}
+
+	builder.WriteString(a.replaceTokens(text[pos:]))
+
+	return builder.String()
+}
+
+func (a *anonymizer) replaceTokens(text string) string {
+	return identifierToken.ReplaceAllStringFunc(text, func(token string) string {
+		if pseudonym, ok := a.pseudonyms[token]; ok {
+			return pseudonym
+		}
+
+		if ipv4Address.MatchString(token) {
+			return a.add(token, func(n int) string { return fmt.Sprintf("10.0.%d.%d", n/256, n%256) }, "ip")
+		}
+
+		return token
+	})
+}
+
+func (a *anonymizer) addName(name string, kind string) {
+	if name == "" {
+		return
+	}
+
+	kind = strings.ToLower(kind)
+	if kind == "" {
+		kind = "object"
+	}
+
+	a.add(name, func(n int) string { return fmt.Sprintf("%s-%s", kind, letters(n)) }, kind)
+}
+
+func (a *anonymizer) addUID(uid string) {
+	if uid == "" {
+		return
+	}
+
+	a.add(uid, func(n int) string { return fmt.Sprintf("00000000-0000-0000-0000-%012d", n) }, "uid")
+}
+
+func (a *anonymizer) numbered(prefix string) func(int) string {
+	return func(n int) string { return fmt.Sprintf("%s-%d", prefix, n) }
+}
+
+// add returns the pseudonym for the value, creating a new one using the
+// counter of the given category if necessary.
+func (a *anonymizer) add(value string, pseudonym func(n int) string, category string) string {
+	if existing, ok := a.pseudonyms[value]; ok {
+		return existing
+	}
+
+	a.counters[category]++
+	a.pseudonyms[value] = pseudonym(a.counters[category])
+
+	return a.pseudonyms[value]
+}
+
+// letters turns 1, 2, ..., 26, 27 into a, b, ..., z, aa.
+func letters(n int) string {
+	result := ""
+	for n > 0 {
+		n--
+		result = string(rune('a'+n%26)) + result
+		n /= 26
+	}
+
+	return result
+}
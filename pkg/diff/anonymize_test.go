@@ -0,0 +1,63 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	a := newAnonymizer()
+
+	a.Learn(parseObject(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx-7d9f
+  namespace: production
+  uid: 6f1c7a4e-0b6e-4d55-8f3b-2b8e4c1d9a01
+  ownerReferences:
+    - kind: ReplicaSet
+      name: nginx-7d
+      uid: 0d5d2a1b-6c1e-4a3f-9a8e-77f1f2a3b4c5
+spec:
+  nodeName: worker-1
+`), "prod-cluster")
+
+	testcases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "Pod prod-cluster:production/nginx-7d9f",
+			expected: "Pod cluster-1:ns-1/pod-a",
+		},
+		{
+			input:    "  uid: 6f1c7a4e-0b6e-4d55-8f3b-2b8e4c1d9a01",
+			expected: "  uid: 00000000-0000-0000-0000-000000000001",
+		},
+		{
+			input:    "    name: nginx-7d",
+			expected: "    name: replicaset-a",
+		},
+		{
+			input:    "+  nodeName: \x1b[32mworker-1\x1b[0m",
+			expected: "+  nodeName: \x1b[32mnode-a\x1b[0m",
+		},
+		{
+			input:    "  podIP: 192.168.1.17, hostIP: 192.168.1.2, again: 192.168.1.17",
+			expected: "  podIP: 10.0.0.1, hostIP: 10.0.0.2, again: 10.0.0.1",
+		},
+		{
+			input:    "  image: nginx:1.23",
+			expected: "  image: nginx:1.23",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.input, func(t *testing.T) {
+			result := a.Anonymize(testcase.input)
+			if result != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/yaml"
+)
+
+// collapseArraysMin is the minimum number of items an array needs to have
+// to be collapsed; smaller arrays are short enough to be shown in full.
+const collapseArraysMin = 10
+
+// collapseArrays replaces runs of unchanged items in large arrays of both
+// YAML documents with a marker like "… 22 unchanged items (#0-#21) …", so
+// that only the changed items remain. Items are compared by their index.
+func collapseArrays(oldString, newString string) (string, string, error) {
+	oldValue, err := decodeYAML(oldString)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode previous object: %w", err)
+	}
+
+	newValue, err := decodeYAML(newString)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode current object: %w", err)
+	}
+
+	oldValue, newValue = collapseValues(oldValue, newValue)
+
+	oldEncoded, err := yaml.Marshal(oldValue)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode previous object: %w", err)
+	}
+
+	newEncoded, err := yaml.Marshal(newValue)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode current object: %w", err)
+	}
+
+	return string(oldEncoded), string(newEncoded), nil
+}
+
+func decodeYAML(data string) (interface{}, error) {
+	encoded, err := yaml.YAMLToJSON([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// this decoder keeps integers as int64 instead of float64
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func collapseValues(oldValue, newValue interface{}) (interface{}, interface{}) {
+	switch oldTyped := oldValue.(type) {
+	case map[string]interface{}:
+		newTyped, ok := newValue.(map[string]interface{})
+		if !ok {
+			return oldValue, newValue
+		}
+
+		for key, oldChild := range oldTyped {
+			if newChild, exists := newTyped[key]; exists {
+				oldTyped[key], newTyped[key] = collapseValues(oldChild, newChild)
+			}
+		}
+
+	case []interface{}:
+		newTyped, ok := newValue.([]interface{})
+		if !ok {
+			return oldValue, newValue
+		}
+
+		return collapseItems(oldTyped, newTyped)
+	}
+
+	return oldValue, newValue
+}
+
+func collapseItems(oldItems, newItems []interface{}) ([]interface{}, []interface{}) {
+	common := len(oldItems)
+	if len(newItems) < common {
+		common = len(newItems)
+	}
+
+	// changed items can contain large arrays themselves
+	if len(oldItems) < collapseArraysMin && len(newItems) < collapseArraysMin {
+		for i := 0; i < common; i++ {
+			oldItems[i], newItems[i] = collapseValues(oldItems[i], newItems[i])
+		}
+
+		return oldItems, newItems
+	}
+
+	oldResult := []interface{}{}
+	newResult := []interface{}{}
+
+	for i := 0; i < common; {
+		if !reflect.DeepEqual(oldItems[i], newItems[i]) {
+			oldItem, newItem := collapseValues(oldItems[i], newItems[i])
+			oldResult = append(oldResult, oldItem)
+			newResult = append(newResult, newItem)
+			i++
+			continue
+		}
+
+		end := i
+		for end < common && reflect.DeepEqual(oldItems[end], newItems[end]) {
+			end++
+		}
+
+		// a marker for a single item would not save anything
+		if end-i == 1 {
+			oldResult = append(oldResult, oldItems[i])
+			newResult = append(newResult, newItems[i])
+		} else {
+			marker := fmt.Sprintf("… %d unchanged items (#%d-#%d) …", end-i, i, end-1)
+			oldResult = append(oldResult, marker)
+			newResult = append(newResult, marker)
+		}
+
+		i = end
+	}
+
+	oldResult = append(oldResult, oldItems[common:]...)
+	newResult = append(newResult, newItems[common:]...)
+
+	return oldResult, newResult
+}
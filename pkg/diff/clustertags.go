@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// ClusterTag is shown instead of the name of a cluster (i.e. kubeconfig
+// context) in front of object keys, to keep multi-cluster output compact.
+type ClusterTag struct {
+	Tag string
+
+	// Style, if set, is used to color the tag in colored output.
+	Style color.Style
+}
+
+// ParseClusterTags parses a list like "prod=P:red,staging=S" into tags by
+// context name. Colors are optional and must be one of the basic terminal
+// colors (red, green, yellow, blue, magenta, cyan, white, black).
+func ParseClusterTags(s string) (map[string]ClusterTag, error) {
+	tags := map[string]ClusterTag{}
+	used := map[string]string{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		context, tag, found := strings.Cut(item, "=")
+		context, tag = strings.TrimSpace(context), strings.TrimSpace(tag)
+		if !found || context == "" || tag == "" {
+			return nil, fmt.Errorf("%q must be in the form CONTEXT=TAG[:COLOR]", item)
+		}
+
+		clusterTag := ClusterTag{Tag: tag}
+
+		if name, colorName, hasColor := strings.Cut(tag, ":"); hasColor {
+			fg, ok := color.FgColors[strings.ToLower(colorName)]
+			if !ok || colorName == "default" {
+				return nil, fmt.Errorf("unknown color %q, must be one of %v", colorName, colorNames())
+			}
+
+			clusterTag = ClusterTag{Tag: name, Style: color.New(fg)}
+		}
+
+		if clusterTag.Tag == "" {
+			return nil, fmt.Errorf("%q has an empty tag", item)
+		}
+
+		if other, exists := used[clusterTag.Tag]; exists && other != context {
+			return nil, fmt.Errorf("tag %q is used for both %q and %q", clusterTag.Tag, other, context)
+		}
+
+		used[clusterTag.Tag] = context
+		tags[context] = clusterTag
+	}
+
+	return tags, nil
+}
+
+func colorNames() []string {
+	names := []string{}
+	for name := range color.FgColors {
+		if name != "default" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// styledPrefix colors the text and afterwards restores the surrounding
+// style, as terminals do not support nested colors.
+func styledPrefix(text string, style color.Style, surrounding color.Style) string {
+	if !color.Enable || !color.SupportColor() {
+		return text
+	}
+
+	styled := color.StartSet + style.Code() + "m" + text + color.ResetSet
+	if len(surrounding) > 0 {
+		styled += color.StartSet + surrounding.Code() + "m"
+	}
+
+	return styled
+}
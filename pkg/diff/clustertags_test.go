@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/gookit/color"
+)
+
+func TestParseClusterTags(t *testing.T) {
+	testcases := []struct {
+		name     string
+		input    string
+		expected map[string]ClusterTag
+		invalid  bool
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: map[string]ClusterTag{},
+		},
+		{
+			name:  "tags without colors",
+			input: "prod=P, staging=S",
+			expected: map[string]ClusterTag{
+				"prod":    {Tag: "P"},
+				"staging": {Tag: "S"},
+			},
+		},
+		{
+			name:  "tag with color",
+			input: "prod=P:red",
+			expected: map[string]ClusterTag{
+				"prod": {Tag: "P", Style: color.New(color.FgRed)},
+			},
+		},
+		{
+			name:    "missing tag",
+			input:   "prod=",
+			invalid: true,
+		},
+		{
+			name:    "missing separator",
+			input:   "prod",
+			invalid: true,
+		},
+		{
+			name:    "empty tag with color",
+			input:   "prod=:red",
+			invalid: true,
+		},
+		{
+			name:    "unknown color",
+			input:   "prod=P:pink",
+			invalid: true,
+		},
+		{
+			name:    "duplicate tag",
+			input:   "prod=P,preview=P",
+			invalid: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := ParseClusterTags(tc.input)
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("Expected an error, but got %v.", tags)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v.", err)
+			}
+
+			if len(tags) != len(tc.expected) {
+				t.Fatalf("Expected %d tags, but got %d.", len(tc.expected), len(tags))
+			}
+
+			for context, expected := range tc.expected {
+				tag, ok := tags[context]
+				if !ok {
+					t.Fatalf("Expected a tag for %q, but got none.", context)
+				}
+
+				if tag.Tag != expected.Tag || tag.Style.Code() != expected.Style.Code() {
+					t.Errorf("Expected %q (%q) for %q, but got %q (%q).", expected.Tag, expected.Style.Code(), context, tag.Tag, tag.Style.Code())
+				}
+			}
+		})
+	}
+}
+
+func TestClusterTagKey(t *testing.T) {
+	obj := parseObject(t, oldDeployment)
+
+	info := TitleInfo{
+		KeyPrefix:      "P",
+		KeyPrefixStyle: color.New(color.FgRed),
+		plain:          true,
+	}
+
+	expected := "P:" + obj.GetNamespace() + "/" + obj.GetName()
+	if key := info.key(obj); key != expected {
+		t.Errorf("Expected %q, but got %q.", expected, key)
+	}
+}
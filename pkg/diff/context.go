@@ -0,0 +1,51 @@
+package diff
+
+import "strings"
+
+const (
+	// linesPerContextLine is how many lines an object must have for every
+	// context line when choosing them automatically.
+	linesPerContextLine = 25
+
+	minAutoContextLines = 1
+	maxAutoContextLines = 8
+)
+
+// contextLines returns the number of context lines to show for the diff
+// between both documents. With AutoContext, this grows with the size of
+// the larger document, so that small objects are shown compactly and
+// changes in large objects can still be located. A negative ContextLines
+// shows the entire document.
+func (d *Differ) contextLines(oldString, newString string) int {
+	switch {
+	case d.opt.AutoContext:
+		return autoContextLines(oldString, newString)
+	case d.opt.ContextLines < 0:
+		return maxLines(oldString, newString)
+	default:
+		return d.opt.ContextLines
+	}
+}
+
+func autoContextLines(oldString, newString string) int {
+	contextLines := maxLines(oldString, newString) / linesPerContextLine
+
+	switch {
+	case contextLines < minAutoContextLines:
+		return minAutoContextLines
+	case contextLines > maxAutoContextLines:
+		return maxAutoContextLines
+	default:
+		return contextLines
+	}
+}
+
+// maxLines returns the number of lines of the larger document.
+func maxLines(oldString, newString string) int {
+	lines := strings.Count(oldString, "\n")
+	if n := strings.Count(newString, "\n"); n > lines {
+		lines = n
+	}
+
+	return lines
+}
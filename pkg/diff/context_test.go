@@ -0,0 +1,52 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAutoContextLines(t *testing.T) {
+	testcases := []struct {
+		oldLines int
+		newLines int
+		expected int
+	}{
+		{oldLines: 0, newLines: 6, expected: 1},
+		{oldLines: 30, newLines: 30, expected: 1},
+		{oldLines: 100, newLines: 60, expected: 4},
+		{oldLines: 60, newLines: 150, expected: 6},
+		{oldLines: 2000, newLines: 0, expected: 8},
+	}
+
+	for _, tc := range testcases {
+		oldString := strings.Repeat("foo: bar\n", tc.oldLines)
+		newString := strings.Repeat("foo: bar\n", tc.newLines)
+
+		if contextLines := autoContextLines(oldString, newString); contextLines != tc.expected {
+			t.Errorf("Expected %d context lines for %d/%d lines, but got %d.", tc.expected, tc.oldLines, tc.newLines, contextLines)
+		}
+	}
+}
+
+func TestContextLines(t *testing.T) {
+	oldString := strings.Repeat("foo: bar\n", 40)
+	newString := strings.Repeat("foo: bar\n", 60)
+
+	testcases := []struct {
+		opt      Options
+		expected int
+	}{
+		{opt: Options{ContextLines: 3}, expected: 3},
+		{opt: Options{ContextLines: 0}, expected: 0},
+		{opt: Options{ContextLines: -1}, expected: 60},
+		{opt: Options{ContextLines: -1, AutoContext: true}, expected: 2},
+	}
+
+	for _, tc := range testcases {
+		differ := &Differ{opt: &tc.opt}
+
+		if contextLines := differ.contextLines(oldString, newString); contextLines != tc.expected {
+			t.Errorf("Expected %d context lines for %d/auto=%v, but got %d.", tc.expected, tc.opt.ContextLines, tc.opt.AutoContext, contextLines)
+		}
+	}
+}
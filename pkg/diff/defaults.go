@@ -0,0 +1,238 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.xrstf.de/stalk/pkg/maputil"
+)
+
+// DefaultField is a field that is set by the API server if it was not
+// specified by the user, together with the value it is defaulted to.
+type DefaultField struct {
+	// Kind is the kind of object the field belongs to, or "*" for all kinds.
+	Kind string
+
+	// Path is the path to the field, in the same notation as for hidden
+	// paths, e.g. "spec.containers[*].imagePullPolicy".
+	Path maputil.Path
+
+	// Value is the defaulted value; strings are compared as they are, all
+	// other values in their JSON encoding, e.g. "30" or "{}".
+	Value string
+}
+
+// podSpecDefaults are the defaulted fields of a pod spec, relative to it.
+var podSpecDefaults = []string{
+	"dnsPolicy=ClusterFirst",
+	"enableServiceLinks=true",
+	"preemptionPolicy=PreemptLowerPriority",
+	"priority=0",
+	"restartPolicy=Always",
+	"schedulerName=default-scheduler",
+	"securityContext={}",
+	"terminationGracePeriodSeconds=30",
+	"containers[*].imagePullPolicy=IfNotPresent",
+	"containers[*].ports[*].protocol=TCP",
+	"containers[*].resources={}",
+	"containers[*].terminationMessagePath=/dev/termination-log",
+	"containers[*].terminationMessagePolicy=File",
+	"initContainers[*].imagePullPolicy=IfNotPresent",
+	"initContainers[*].resources={}",
+	"initContainers[*].terminationMessagePath=/dev/termination-log",
+	"initContainers[*].terminationMessagePolicy=File",
+}
+
+// BuiltinDefaults are the commonly defaulted fields of the most common
+// kinds, which make newly created objects look very different from the
+// manifests they were created from.
+var BuiltinDefaults = builtinDefaults()
+
+func builtinDefaults() []DefaultField {
+	fields := []DefaultField{}
+
+	add := func(kind string, prefix string, defaults ...string) {
+		for _, def := range defaults {
+			field, err := ParseDefaultField(fmt.Sprintf("%s:%s%s", kind, prefix, def))
+			if err != nil {
+				panic(err)
+			}
+
+			fields = append(fields, field)
+		}
+	}
+
+	add("Pod", "spec.", podSpecDefaults...)
+	add("Deployment", "spec.template.spec.", podSpecDefaults...)
+	add("ReplicaSet", "spec.template.spec.", podSpecDefaults...)
+	add("StatefulSet", "spec.template.spec.", podSpecDefaults...)
+	add("DaemonSet", "spec.template.spec.", podSpecDefaults...)
+	add("Job", "spec.template.spec.", podSpecDefaults...)
+	add("CronJob", "spec.jobTemplate.spec.template.spec.", podSpecDefaults...)
+
+	add("Deployment", "spec.",
+		"progressDeadlineSeconds=600",
+		"revisionHistoryLimit=10",
+		"strategy.rollingUpdate.maxSurge=25%",
+		"strategy.rollingUpdate.maxUnavailable=25%",
+		"strategy.type=RollingUpdate",
+	)
+
+	add("StatefulSet", "spec.",
+		"podManagementPolicy=OrderedReady",
+		"revisionHistoryLimit=10",
+		"updateStrategy.rollingUpdate.partition=0",
+		"updateStrategy.type=RollingUpdate",
+	)
+
+	add("DaemonSet", "spec.",
+		"revisionHistoryLimit=10",
+		"updateStrategy.rollingUpdate.maxSurge=0",
+		"updateStrategy.rollingUpdate.maxUnavailable=1",
+		"updateStrategy.type=RollingUpdate",
+	)
+
+	add("Job", "spec.",
+		"backoffLimit=6",
+		"completionMode=NonIndexed",
+		"completions=1",
+		"parallelism=1",
+		"suspend=false",
+	)
+
+	add("CronJob", "spec.",
+		"concurrencyPolicy=Allow",
+		"failedJobsHistoryLimit=1",
+		"successfulJobsHistoryLimit=3",
+		"suspend=false",
+	)
+
+	add("Service", "spec.",
+		"internalTrafficPolicy=Cluster",
+		"ipFamilyPolicy=SingleStack",
+		"ports[*].protocol=TCP",
+		"sessionAffinity=None",
+		"type=ClusterIP",
+	)
+
+	return fields
+}
+
+// ParseDefaultField parses a field in the form "KIND:PATH=VALUE", e.g.
+// "Deployment:spec.minReadySeconds=0".
+func ParseDefaultField(s string) (DefaultField, error) {
+	kind, rest, found := strings.Cut(s, ":")
+	if !found || kind == "" {
+		return DefaultField{}, fmt.Errorf("%q must be in the form KIND:PATH=VALUE", s)
+	}
+
+	path, value, found := strings.Cut(rest, "=")
+	if !found || path == "" {
+		return DefaultField{}, fmt.Errorf("%q must be in the form KIND:PATH=VALUE", s)
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			return DefaultField{}, fmt.Errorf("path %q contains an empty segment", path)
+		}
+	}
+
+	parsed, err := maputil.ParsePath(path)
+	if err != nil {
+		return DefaultField{}, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+
+	return DefaultField{
+		Kind:  kind,
+		Path:  parsed,
+		Value: value,
+	}, nil
+}
+
+// stripDefaults removes all fields from the object that still have the
+// value they would have been defaulted to.
+func stripDefaults(obj map[string]interface{}, fields []DefaultField) {
+	kind, _ := obj["kind"].(string)
+
+	for _, field := range fields {
+		if field.Kind == "*" || field.Kind == kind {
+			stripDefault(obj, field.Path, field.Value)
+		}
+	}
+}
+
+func stripDefault(obj map[string]interface{}, path maputil.Path, value string) {
+	head, tail := path.Head(), path.Tail()
+
+	keys := []string{head}
+	if head == maputil.Wildcard {
+		keys = make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		child, exists := obj[key]
+		if !exists {
+			continue
+		}
+
+		if len(tail) == 0 {
+			if isDefault(child, value) {
+				delete(obj, key)
+			}
+
+			continue
+		}
+
+		switch c := child.(type) {
+		case map[string]interface{}:
+			stripDefault(c, tail, value)
+
+			// do not leave empty parents behind, like a strategy without any fields
+			if len(c) == 0 {
+				delete(obj, key)
+			}
+
+		case []interface{}:
+			stripDefaultFromItems(c, tail, value)
+		}
+	}
+}
+
+// stripDefaultFromItems strips the default from the items of a list that
+// match the array segment at the start of the path. The items themselves
+// are never removed.
+func stripDefaultFromItems(items []interface{}, path maputil.Path, value string) {
+	head, tail := path.Head(), path.Tail()
+
+	index, isIndex := maputil.ParseIndexSegment(head)
+	if (!isIndex && head != maputil.ArrayWildcard) || len(tail) == 0 {
+		return
+	}
+
+	for i, item := range items {
+		if isIndex && i != index {
+			continue
+		}
+
+		if itemObj, ok := item.(map[string]interface{}); ok {
+			stripDefault(itemObj, tail, value)
+		}
+	}
+}
+
+func isDefault(value interface{}, def string) bool {
+	if s, ok := value.(string); ok {
+		return s == def
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+
+	return string(encoded) == def
+}
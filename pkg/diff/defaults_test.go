@@ -0,0 +1,184 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDefaultField(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected DefaultField
+		invalid  bool
+	}{
+		{
+			input: "Deployment:spec.minReadySeconds=0",
+			expected: DefaultField{
+				Kind:  "Deployment",
+				Path:  []string{"spec", "minReadySeconds"},
+				Value: "0",
+			},
+		},
+		{
+			input: "*:spec.containers[*].ports[*].protocol=TCP",
+			expected: DefaultField{
+				Kind:  "*",
+				Path:  []string{"spec", "containers", "[*]", "ports", "[*]", "protocol"},
+				Value: "TCP",
+			},
+		},
+		{
+			input: "ConfigMap:data.*=",
+			expected: DefaultField{
+				Kind:  "ConfigMap",
+				Path:  []string{"data", "*"},
+				Value: "",
+			},
+		},
+		{
+			input: "Pod:spec.containers[0].stdin=false",
+			expected: DefaultField{
+				Kind:  "Pod",
+				Path:  []string{"spec", "containers", "[0]", "stdin"},
+				Value: "false",
+			},
+		},
+		{
+			input:   "spec.minReadySeconds=0",
+			invalid: true,
+		},
+		{
+			input:   "Pod:spec.containers[name].stdin=false",
+			invalid: true,
+		},
+		{
+			input:   "Deployment:spec.minReadySeconds",
+			invalid: true,
+		},
+		{
+			input:   "Deployment:spec..minReadySeconds=0",
+			invalid: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.input, func(t *testing.T) {
+			actual, err := ParseDefaultField(testcase.input)
+			if testcase.invalid {
+				if err == nil {
+					t.Fatalf("Expected an error, but got %+v.", actual)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to parse field: %v", err)
+			}
+
+			if !reflect.DeepEqual(actual, testcase.expected) {
+				t.Errorf("Expected %+v, but got %+v.", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStripDefaults(t *testing.T) {
+	obj := parseObject(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  revisionHistoryLimit: 5
+  progressDeadlineSeconds: 600
+  strategy:
+    type: RollingUpdate
+    rollingUpdate:
+      maxSurge: 25%
+      maxUnavailable: 25%
+  template:
+    spec:
+      dnsPolicy: ClusterFirst
+      securityContext: {}
+      containers:
+        - name: nginx
+          image: nginx:latest
+          imagePullPolicy: Always
+          terminationMessagePath: /dev/termination-log
+          ports:
+            - containerPort: 80
+              protocol: TCP
+`)
+
+	expected := parseObject(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  revisionHistoryLimit: 5
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:latest
+          imagePullPolicy: Always
+          ports:
+            - containerPort: 80
+`)
+
+	stripDefaults(obj.Object, BuiltinDefaults)
+
+	if !reflect.DeepEqual(obj.Object, expected.Object) {
+		t.Errorf("Expected %v, but got %v.", expected.Object, obj.Object)
+	}
+}
+
+func TestStripDefaultsWithPatterns(t *testing.T) {
+	obj := parseObject(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+    tier: ""
+spec:
+  containers:
+    - name: nginx
+      stdin: false
+    - name: sidecar
+      stdin: false
+`)
+
+	expected := parseObject(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+spec:
+  containers:
+    - name: nginx
+    - name: sidecar
+      stdin: false
+`)
+
+	fields := []DefaultField{}
+	for _, field := range []string{"Pod:metadata.labels.*=", "Pod:spec.containers[0].stdin=false"} {
+		parsed, err := ParseDefaultField(field)
+		if err != nil {
+			t.Fatalf("Failed to parse field: %v", err)
+		}
+
+		fields = append(fields, parsed)
+	}
+
+	stripDefaults(obj.Object, fields)
+
+	if !reflect.DeepEqual(obj.Object, expected.Object) {
+		t.Errorf("Expected %v, but got %v.", expected.Object, obj.Object)
+	}
+}
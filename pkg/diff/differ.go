@@ -3,23 +3,31 @@ package diff
 import (
 	"bytes"
 	"fmt"
-	"regexp"
-	"strings"
+	"io"
+	"sync"
 	"time"
 
 	"go.xrstf.de/stalk/pkg/maputil"
+	"go.xrstf.de/stalk/pkg/objectkey"
 
 	"github.com/gookit/color"
 	"github.com/shibukawa/cdiff"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/yaml"
 )
 
 type Differ struct {
 	opt *Options
 	log logrus.FieldLogger
+	now func() time.Time
+
+	// unmatchedJSONPath ensures that a JSON path that does not match an
+	// object is only reported once.
+	unmatchedJSONPath sync.Once
 }
 
 func NewDiffer(opt *Options, log logrus.FieldLogger) (*Differ, error) {
@@ -36,27 +44,46 @@ func NewDiffer(opt *Options, log logrus.FieldLogger) (*Differ, error) {
 	return &Differ{
 		opt: opt,
 		log: log,
+		now: time.Now,
 	}, nil
 }
 
-func (d *Differ) PrintDiff(oldObj, newObj *unstructured.Unstructured, lastSeen time.Time) error {
-	oldString, err := d.preprocess(oldObj)
-	if err != nil {
-		return fmt.Errorf("failed to process previous object: %w", err)
+// PrintDiff renders the diff between both objects into out. Either object
+// can be nil, in which case a creation or deletion is shown.
+func (d *Differ) PrintDiff(out io.Writer, oldObj, newObj *unstructured.Unstructured, lastSeen time.Time, info TitleInfo) error {
+	eventType := watch.Modified
+	switch {
+	case oldObj == nil:
+		eventType = watch.Added
+	case newObj == nil:
+		eventType = watch.Deleted
 	}
 
-	newString, err := d.preprocess(newObj)
+	oldString, newString, hidden, err := d.preprocessChange(oldObj, newObj, eventType)
 	if err != nil {
-		return fmt.Errorf("failed to process current object: %w", err)
+		return err
 	}
 
-	// this can happen if the spec changes, but `--show metadata` was given by the user
-	if oldString == newString && d.opt.HideEmptyDiffs {
+	if hidden {
 		return nil
 	}
 
-	titleA := diffTitle(oldObj, lastSeen)
-	titleB := diffTitle(newObj, time.Now())
+	if d.opt.Output == OutputYAML {
+		return d.printYAML(out, eventType, newObj, oldObj, newString, info)
+	}
+
+	if d.opt.Output == OutputJSON {
+		return d.printJSON(out, eventType, newObj, oldObj, oldString, newString, info)
+	}
+
+	if d.opt.CollapseArrays && oldObj != nil && newObj != nil {
+		oldCollapsed, newCollapsed, err := collapseArrays(oldString, newString)
+		if err != nil {
+			d.log.Warnf("Failed to collapse unchanged array items: %v", err)
+		} else {
+			oldString, newString = oldCollapsed, newCollapsed
+		}
+	}
 
 	colorTheme := d.opt.UpdateColorTheme
 	if oldObj == nil {
@@ -66,21 +93,203 @@ func (d *Differ) PrintDiff(oldObj, newObj *unstructured.Unstructured, lastSeen t
 		colorTheme = d.opt.DeleteColorTheme
 	}
 
-	diff := cdiff.Diff(oldString, newString, cdiff.WordByWord)
+	var body string
+
+	// plain diffs are meant for machines, so no colors are used at all;
+	// Markdown is rendered by the platform it is pasted into, which
+	// highlights the diff on its own
+	markdown := d.opt.Output == OutputMarkdown
+	plain := d.opt.Algorithm == AlgorithmDifflib || markdown
+	if d.opt.Sectioned {
+		body, err = d.renderSections(oldString, newString, colorTheme, plain)
+	} else {
+		body, err = d.renderBody(oldString, newString, colorTheme, plain)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create diff: %w", err)
+	}
+
+	header := colorTheme[cdiff.OpenHeader].Sprint
+	if plain {
+		header = fmt.Sprint
+	}
+
+	info.plain = plain
+	info.surrounding = colorTheme[cdiff.OpenHeader]
+
+	// type changes are easy to overlook when both values look alike
+	if oldObj != nil && newObj != nil {
+		changes, err := typeChanges(oldString, newString)
+		if err != nil {
+			d.log.Warnf("Failed to detect type changes: %v", err)
+		} else if len(changes) > 0 {
+			body += header(typeChangeNotes(changes))
+		}
+	}
 
 	var buf bytes.Buffer
-	color.Fprint(&buf, diff.UnifiedWithGooKitColor(titleA, titleB, d.opt.ContextLines, colorTheme))
 
-	fmt.Println(fixBadSection(buf.String(), colorTheme))
+	switch {
+	case d.opt.NoHeaders:
+		// only the body is printed
+
+	case d.opt.CompactTitle && markdown:
+		// the object is already named by the heading
+
+	case d.opt.CompactTitle:
+		title := compactTitle(oldObj, newObj, info) + info.suffix()
+
+		// place the title in front of the first hunk marker
+		buf.WriteString(header(title))
+		buf.WriteString(" ")
+
+	default:
+		titleA := diffTitle(oldObj, d.eventTime(oldObj, lastSeen), info)
+		titleB := diffTitle(newObj, d.eventTime(newObj, d.now()), info)
+
+		// annotate the most recent object
+		if newObj != nil {
+			titleB += info.suffix()
+		} else {
+			titleA += info.suffix()
+		}
+
+		buf.WriteString(header("--- " + titleA + "\n+++ " + titleB + "\n"))
+	}
+
+	buf.WriteString(body)
+
+	output := buf.String()
+	if d.opt.compiledHighlight != nil && !plain {
+		output = highlight(output, d.opt.compiledHighlight, HighlightStyle)
+	}
+
+	if markdown {
+		heading := ""
+		if !d.opt.NoHeaders {
+			heading = markdownHeading(oldObj, newObj, info)
+		}
+
+		output = markdownSection(heading, output)
+	}
+
+	// write the entire diff at once, so it cannot be torn apart by a
+	// partially successful write
+	_, err = fmt.Fprintln(out, output)
+
+	return err
+}
+
+// preprocessChange preprocesses both objects for diffing. hidden is true
+// if the change should not be shown at all with HideEmptyDiffs, because it
+// would produce no diff or only bumps the resourceVersion.
+func (d *Differ) preprocessChange(oldObj, newObj *unstructured.Unstructured, eventType watch.EventType) (oldString, newString string, hidden bool, err error) {
+	// fields that are ignored are compared with their current value, so
+	// they cannot produce a diff
+	oldCompared := oldObj
+	if len(d.opt.parsedIgnorePaths) > 0 && oldObj != nil && newObj != nil {
+		oldCompared = equalizeFields(oldObj, newObj, d.opt.parsedIgnorePaths)
+	}
+
+	oldString, err = d.preprocess(oldCompared, eventType)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to process previous object: %w", err)
+	}
+
+	newString, err = d.preprocess(newObj, eventType)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to process current object: %w", err)
+	}
+
+	if d.opt.Whitespace == "" || d.opt.Whitespace == WhitespaceIgnore {
+		oldString = normalizeWhitespace(oldString)
+		newString = normalizeWhitespace(newString)
+	}
+
+	// this can happen if the spec changes, but `--show metadata` was given by the user
+	if oldString == newString && d.opt.HideEmptyDiffs {
+		return "", "", true, nil
+	}
+
+	// controllers often touch objects without changing anything visible,
+	// which only bumps the resourceVersion
+	if d.opt.HideEmptyDiffs && oldObj != nil && newObj != nil {
+		noop, err := d.onlyResourceVersionChanged(oldCompared, newObj, eventType, newString)
+		if err != nil {
+			return "", "", false, fmt.Errorf("failed to process previous object: %w", err)
+		}
+
+		if noop {
+			return "", "", true, nil
+		}
+	}
+
+	return oldString, newString, false, nil
+}
+
+// resourceVersionPath is where the resourceVersion is stored in an object.
+var resourceVersionPath = maputil.Path{"metadata", "resourceVersion"}
+
+// onlyResourceVersionChanged returns true if the preprocessed old object
+// only differs from the new one (newString) by its resourceVersion.
+func (d *Differ) onlyResourceVersionChanged(oldObj, newObj *unstructured.Unstructured, eventType watch.EventType, newString string) (bool, error) {
+	if oldObj.GetResourceVersion() == newObj.GetResourceVersion() {
+		return false, nil
+	}
+
+	bumped := equalizeFields(oldObj, newObj, []maputil.Path{resourceVersionPath})
+
+	bumpedString, err := d.preprocess(bumped, eventType)
+	if err != nil {
+		return false, err
+	}
+
+	if d.opt.Whitespace == "" || d.opt.Whitespace == WhitespaceIgnore {
+		bumpedString = normalizeWhitespace(bumpedString)
+	}
+
+	return bumpedString == newString, nil
+}
+
+// renderBody renders the diff between both documents, without any title.
+func (d *Differ) renderBody(oldString, newString string, colorTheme map[cdiff.Tag]color.Style, plain bool) (string, error) {
+	if plain {
+		body, err := renderDifflib(oldString, newString, d.contextLines(oldString, newString))
+		if err != nil {
+			return "", err
+		}
+
+		if d.opt.Whitespace == WhitespaceMark {
+			body = markWhitespaceLines(body)
+		}
+
+		if d.opt.LineNumbers {
+			body = numberDifflibLines(body)
+		}
+
+		return body, nil
+	}
 
-	return nil
+	diff := cdiff.Diff(oldString, newString, cdiff.WordByWord)
+	if d.opt.Whitespace == WhitespaceMark {
+		diff = markWhitespaceChanges(diff)
+	}
+
+	var moved *moves
+	if d.opt.DetectMoves {
+		diff, moved = detectMoves(diff)
+	}
+
+	return renderUnified(diff, d.contextLines(oldString, newString), colorTheme, moved, d.opt.LineNumbers), nil
 }
 
-func (d *Differ) preprocess(obj *unstructured.Unstructured) (string, error) {
+func (d *Differ) preprocess(obj *unstructured.Unstructured, eventType watch.EventType) (string, error) {
 	if obj == nil {
 		return "", nil
 	}
 
+	includePaths, excludePaths := d.filterPaths(eventType)
+
 	generic, err := json.Marshal(obj)
 	if err != nil {
 		return "", fmt.Errorf("failed to encode object as JSON: %w", err)
@@ -91,12 +300,50 @@ func (d *Differ) preprocess(obj *unstructured.Unstructured) (string, error) {
 		return "", fmt.Errorf("failed to re-decode object from JSON: %w", err)
 	}
 
+	// releases are decoded first, so that all other options can refer to
+	// their fields
+	if d.opt.DecodeHelm && isHelmRelease(genericObj) {
+		if err := decodeHelmRelease(genericObj); err != nil {
+			d.log.Warnf("Failed to decode Helm release: %v", err)
+		} else {
+			generic, err = json.Marshal(genericObj)
+			if err != nil {
+				return "", fmt.Errorf("failed to encode decoded Helm release as JSON: %w", err)
+			}
+		}
+	}
+
+	// defaults are stripped first, as their paths refer to the entire object
+	if d.opt.StripDefaults {
+		stripDefaults(genericObj, d.opt.parsedDefaultFields)
+
+		generic, err = json.Marshal(genericObj)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode object without defaults as JSON: %w", err)
+		}
+	}
+
 	if d.opt.compiledJSONPath != nil {
-		results, err := d.opt.compiledJSONPath.FindResults(genericObj)
+		values, err := jsonPathResults(d.opt.compiledJSONPath, genericObj)
 		if err != nil {
-			d.log.Warnf("Failed to apply JSON path: %w", err)
-		} else if len(results) > 0 && len(results[0]) > 0 {
-			generic, err = json.Marshal(results[0][0].Interface())
+			d.log.Warnf("Failed to apply JSON path: %v", err)
+		} else if len(values) == 0 {
+			// this is expected for some objects (e.g. optional fields), so
+			// warning about every single one would flood the output
+			d.unmatchedJSONPath.Do(func() {
+				d.log.Warnf("JSON path %s does not match anything in %s, objects without matches are shown as empty.", d.opt.JSONPath, objectkey.Of(obj))
+			})
+
+			return "", nil
+		} else {
+			// expressions with a single match are shown as they are, all
+			// others as a list of all matches
+			var result interface{} = values
+			if len(values) == 1 {
+				result = values[0]
+			}
+
+			generic, err = json.Marshal(result)
 			if err != nil {
 				return "", fmt.Errorf("failed to encode JSON path result as JSON: %w", err)
 			}
@@ -121,8 +368,8 @@ func (d *Differ) preprocess(obj *unstructured.Unstructured) (string, error) {
 		}
 	}
 
-	if len(d.opt.parsedIncludePaths) > 0 {
-		genericObj, err = maputil.PruneObject(genericObj, d.opt.parsedIncludePaths)
+	if len(includePaths) > 0 {
+		genericObj, err = maputil.PruneObject(genericObj, includePaths)
 		if err != nil {
 			return "", fmt.Errorf("failed to apply include inpressions: %w", err)
 		}
@@ -133,8 +380,8 @@ func (d *Differ) preprocess(obj *unstructured.Unstructured) (string, error) {
 		}
 	}
 
-	if len(d.opt.parsedExcludePaths) > 0 {
-		for _, excludePath := range d.opt.parsedExcludePaths {
+	if len(excludePaths) > 0 {
+		for _, excludePath := range excludePaths {
 			genericObj, err = maputil.RemovePath(genericObj, excludePath)
 			if err != nil {
 				return "", fmt.Errorf("failed to apply exclude expression %v: %w", excludePath, err)
@@ -147,6 +394,15 @@ func (d *Differ) preprocess(obj *unstructured.Unstructured) (string, error) {
 		}
 	}
 
+	if d.opt.RelativeTimes {
+		genericObj = relativeTimes(genericObj, d.now()).(map[string]interface{})
+
+		generic, err = json.Marshal(genericObj)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode object with relative times as JSON: %w", err)
+		}
+	}
+
 	final, err := yaml.JSONToYAML(generic)
 	if err != nil {
 		return "", fmt.Errorf("failed to encode object as YAML: %w", err)
@@ -155,33 +411,37 @@ func (d *Differ) preprocess(obj *unstructured.Unstructured) (string, error) {
 	return string(final), nil
 }
 
-func objectKey(obj *unstructured.Unstructured) string {
-	key := obj.GetName()
-	if ns := obj.GetNamespace(); ns != "" {
-		key = fmt.Sprintf("%s/%s", ns, key)
+// jsonPathResults returns all values the path yields for the object.
+func jsonPathResults(path *jsonpath.JSONPath, obj map[string]interface{}) ([]interface{}, error) {
+	results, err := path.FindResults(obj)
+	if err != nil {
+		return nil, err
 	}
 
-	return key
-}
-
-func diffTitle(obj *unstructured.Unstructured, lastSeen time.Time) string {
-	if obj == nil {
-		return "(none)"
+	values := []interface{}{}
+	for _, result := range results {
+		for _, value := range result {
+			values = append(values, value.Interface())
+		}
 	}
 
-	timestamp := lastSeen.Format(time.RFC3339)
-	kind := obj.GroupVersionKind().Kind
-
-	return fmt.Sprintf("%s %s v%s (%s) (gen. %d)", kind, objectKey(obj), obj.GetResourceVersion(), timestamp, obj.GetGeneration())
+	return values, nil
 }
 
-// this ensures that the first line of a context/diff is not placed in the
-// same line as the @@...@@ marker
-func fixBadSection(output string, theme map[cdiff.Tag]color.Style) string {
-	regex := theme[cdiff.OpenSection].Render("@@ _placeholder_ @@")
-	regex = regexp.QuoteMeta(regex)
-	regex = strings.Replace(regex, "_placeholder_", "[0-9-+, ]+", -1)
-	expr := regexp.MustCompile(regex)
+// filterPaths returns the include and exclude paths for the given type of
+// event, falling back to the global paths.
+func (d *Differ) filterPaths(eventType watch.EventType) ([]maputil.Path, []maputil.Path) {
+	includePaths, excludePaths := d.opt.parsedIncludePaths, d.opt.parsedExcludePaths
+
+	if filter, ok := d.opt.EventFilters[eventType]; ok {
+		if len(filter.parsedIncludePaths) > 0 {
+			includePaths = filter.parsedIncludePaths
+		}
+
+		if len(filter.parsedExcludePaths) > 0 {
+			excludePaths = filter.parsedExcludePaths
+		}
+	}
 
-	return expr.ReplaceAllString(output, `$0`+"\n")
+	return includePaths, excludePaths
 }
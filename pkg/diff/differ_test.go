@@ -0,0 +1,505 @@
+package diff
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/yaml"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/")
+
+const (
+	oldDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  resourceVersion: "100"
+  generation: 1
+  labels:
+    app: nginx
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.22
+status:
+  readyReplicas: 1
+`
+
+	newDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  resourceVersion: "101"
+  generation: 2
+  labels:
+    app: nginx
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.23
+status:
+  readyReplicas: 1
+`
+)
+
+const (
+	oldConditions = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  namespace: default
+status:
+  conditions:
+  - type: Ready
+    status: "True"
+  - type: ContainersReady
+    status: "True"
+  - type: PodScheduled
+    status: "True"
+  phase: Running
+`
+
+	newConditions = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  namespace: default
+status:
+  conditions:
+  - type: ContainersReady
+    status: "True"
+  - type: PodScheduled
+    status: "True"
+  - type: Ready
+    status: "True"
+  phase: Running
+`
+)
+
+// endpoints returns an Endpoints object with many addresses, one of which
+// points to the given node.
+func endpoints(node string) string {
+	var builder strings.Builder
+
+	builder.WriteString(`
+apiVersion: v1
+kind: Endpoints
+metadata:
+  name: web
+  namespace: default
+subsets:
+  - addresses:
+`)
+
+	for i := 0; i < 12; i++ {
+		nodeName := "worker-1"
+		if i == 5 {
+			nodeName = node
+		}
+
+		fmt.Fprintf(&builder, "      - ip: 10.0.0.%d\n        nodeName: %s\n", i, nodeName)
+	}
+
+	return builder.String()
+}
+
+func TestPrintDiff(t *testing.T) {
+	color.Disable()
+
+	testcases := []struct {
+		name string
+		old  string
+		new  string
+		opt  Options
+	}{
+		{
+			name: "create",
+			new:  newDeployment,
+		},
+		{
+			name: "update",
+			old:  oldDeployment,
+			new:  newDeployment,
+		},
+		{
+			name: "delete",
+			old:  oldDeployment,
+		},
+		{
+			name: "markdown",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Output: OutputMarkdown,
+			},
+		},
+		{
+			name: "yaml",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Output: OutputYAML,
+			},
+		},
+		{
+			name: "yaml-delete",
+			old:  oldDeployment,
+			opt: Options{
+				Output: OutputYAML,
+			},
+		},
+		{
+			name: "json",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Output: OutputJSON,
+			},
+		},
+		{
+			name: "json-create",
+			new:  newDeployment,
+			opt: Options{
+				Output:       OutputJSON,
+				ExcludePaths: []string{"status"},
+			},
+		},
+		{
+			name: "json-delete",
+			old:  oldDeployment,
+			opt: Options{
+				Output: OutputJSON,
+			},
+		},
+		{
+			name: "json-jsonpath",
+			new:  newDeployment,
+			opt: Options{
+				Output:   OutputJSON,
+				JSONPath: "{.spec.replicas}",
+			},
+		},
+		{
+			name: "json-jsonpath-multiple",
+			old:  oldConditions,
+			opt: Options{
+				Output:   OutputJSON,
+				JSONPath: "{.status.conditions[*].type}",
+			},
+		},
+		{
+			name: "exclude",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				ExcludePaths: []string{"metadata", "status"},
+			},
+		},
+		{
+			name: "event-filter",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				ExcludePaths: []string{"metadata"},
+				EventFilters: map[watch.EventType]*EventFilter{
+					watch.Added: {
+						ExcludePaths: []string{"spec"},
+					},
+					watch.Modified: {
+						ExcludePaths: []string{"metadata", "status"},
+					},
+				},
+			},
+		},
+		{
+			name: "type-change",
+			old: `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: foo
+spec:
+  port: "8080"
+  selector: app=foo
+`,
+			new: `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: foo
+spec:
+  port: 8080
+  selector:
+    app: foo
+`,
+		},
+		{
+			name: "include",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				IncludePaths: []string{"spec.replicas"},
+			},
+		},
+		{
+			name: "include-exclude",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				IncludePaths: []string{"spec", "status.readyReplicas"},
+				ExcludePaths: []string{"spec.template"},
+			},
+		},
+		{
+			name: "jsonpath",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				JSONPath: "{.spec.template.spec.containers[0]}",
+			},
+		},
+		{
+			name: "jsonpath-multiple",
+			old:  oldConditions,
+			new:  newConditions,
+			opt: Options{
+				JSONPath: "{.status.conditions[*].type}",
+			},
+		},
+		{
+			name: "compact-title",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				CompactTitle: true,
+			},
+		},
+		{
+			name: "no-headers",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				NoHeaders: true,
+			},
+		},
+		{
+			name: "whitespace-mark",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Whitespace: WhitespaceMark,
+			},
+		},
+		{
+			name: "moves",
+			old:  oldConditions,
+			new:  newConditions,
+			opt: Options{
+				DetectMoves: true,
+			},
+		},
+		{
+			name: "moves-disabled",
+			old:  oldConditions,
+			new:  newConditions,
+		},
+		{
+			name: "difflib",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Algorithm: AlgorithmDifflib,
+			},
+		},
+		{
+			name: "difflib-last-line",
+			old:  oldDeployment,
+			new:  strings.Replace(oldDeployment, "readyReplicas: 1", "readyReplicas: 2", 1),
+			opt: Options{
+				Algorithm: AlgorithmDifflib,
+			},
+		},
+		{
+			name: "collapse-arrays",
+			old:  endpoints("worker-1"),
+			new:  endpoints("worker-2"),
+			opt: Options{
+				CollapseArrays: true,
+			},
+		},
+		{
+			name: "line-numbers",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				LineNumbers: true,
+			},
+		},
+		{
+			name: "sectioned",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Sectioned: true,
+			},
+		},
+		{
+			name: "sectioned-difflib",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Algorithm: AlgorithmDifflib,
+				Sectioned: true,
+			},
+		},
+		{
+			name: "line-numbers-difflib",
+			old:  oldDeployment,
+			new:  newDeployment,
+			opt: Options{
+				Algorithm:   AlgorithmDifflib,
+				LineNumbers: true,
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			opt := testcase.opt
+			opt.ContextLines = 3
+			opt.HideEmptyDiffs = true
+			opt.CreateColorTheme = CreateColorTheme
+			opt.UpdateColorTheme = UpdateColorTheme
+			opt.DeleteColorTheme = DeleteColorTheme
+
+			differ, err := NewDiffer(&opt, logrus.New())
+			if err != nil {
+				t.Fatalf("failed to create differ: %v", err)
+			}
+
+			differ.now = func() time.Time {
+				return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+			}
+
+			lastSeen := time.Date(2022, 9, 1, 11, 0, 0, 0, time.UTC)
+
+			var buf bytes.Buffer
+			if err := differ.PrintDiff(&buf, parseObject(t, testcase.old), parseObject(t, testcase.new), lastSeen, TitleInfo{}); err != nil {
+				t.Fatalf("failed to print diff: %v", err)
+			}
+
+			assertGolden(t, testcase.name, buf.String())
+		})
+	}
+}
+
+func parseObject(t *testing.T, data string) *unstructured.Unstructured {
+	if data == "" {
+		return nil
+	}
+
+	encoded, err := yaml.YAMLToJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("invalid testcase: %v", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(encoded); err != nil {
+		t.Fatalf("invalid testcase: %v", err)
+	}
+
+	return obj
+}
+
+func assertGolden(t *testing.T, name string, actual string) {
+	filename := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.WriteFile(filename, []byte(actual), 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+
+	if string(expected) != actual {
+		t.Errorf("Output does not match %s.\n\nExpected:\n%s\nActual:\n%s", filename, string(expected), actual)
+	}
+}
+
+func TestHideResourceVersionBumps(t *testing.T) {
+	bumped := strings.Replace(oldDeployment, `resourceVersion: "100"`, `resourceVersion: "101"`, 1)
+	if bumped == oldDeployment {
+		t.Fatal("invalid testcase: resourceVersion not found")
+	}
+
+	for _, hideEmpty := range []bool{true, false} {
+		differ, err := NewDiffer(&Options{HideEmptyDiffs: hideEmpty, UpdateColorTheme: UpdateColorTheme}, logrus.New())
+		if err != nil {
+			t.Fatalf("failed to create differ: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := differ.PrintDiff(&buf, parseObject(t, oldDeployment), parseObject(t, bumped), time.Now(), TitleInfo{}); err != nil {
+			t.Fatalf("failed to print diff: %v", err)
+		}
+
+		if hidden := buf.Len() == 0; hidden != hideEmpty {
+			t.Errorf("Expected hidden=%v, but got output %q.", hideEmpty, buf.String())
+		}
+	}
+}
+
+func TestUnmatchedJSONPath(t *testing.T) {
+	var logs bytes.Buffer
+
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	differ, err := NewDiffer(&Options{JSONPath: "{.spec.missing}"}, log)
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	for _, data := range []string{oldDeployment, newDeployment} {
+		result, err := differ.preprocess(parseObject(t, data), watch.Modified)
+		if err != nil {
+			t.Fatalf("failed to preprocess object: %v", err)
+		}
+
+		// the object must not be shown in full instead
+		if result != "" {
+			t.Errorf("Expected an empty result, but got %q.", result)
+		}
+	}
+
+	if count := strings.Count(logs.String(), "does not match anything"); count != 1 {
+		t.Errorf("Expected a single warning, but got %d: %q", count, logs.String())
+	}
+}
@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// These errors describe which expression in the Options is invalid. Use
+// errors.Is to check for them and errors.As with an *ExpressionError to
+// get the offending expression.
+var (
+	ErrInvalidJSONPath     = errors.New("invalid JSON path")
+	ErrInvalidQuietField   = errors.New("invalid quiet field JSON path")
+	ErrInvalidHighlight    = errors.New("invalid highlight expression")
+	ErrInvalidIncludePath  = errors.New("invalid include expression")
+	ErrInvalidExcludePath  = errors.New("invalid exclude expression")
+	ErrInvalidDefaultField = errors.New("invalid extra default")
+	ErrInvalidProjection   = errors.New("invalid projection")
+	ErrInvalidSortBy       = errors.New("invalid sort JSON path")
+	ErrInvalidIgnorePath   = errors.New("invalid semantic ignore expression")
+)
+
+// ExpressionError is returned by Options.Validate if an expression cannot
+// be parsed.
+type ExpressionError struct {
+	// Kind is one of the ErrInvalid* errors.
+	Kind error
+
+	// Expression is the offending expression.
+	Expression string
+
+	// EventType is set if the expression is part of an EventFilter.
+	EventType watch.EventType
+
+	// Err is the reason why the expression is invalid.
+	Err error
+}
+
+func (e *ExpressionError) Error() string {
+	// paths are quoted, as they are given in great numbers and would be
+	// hard to tell apart otherwise
+	if e.Kind != ErrInvalidIncludePath && e.Kind != ErrInvalidExcludePath && e.Kind != ErrInvalidIgnorePath {
+		return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+	}
+
+	if e.EventType != "" {
+		return fmt.Sprintf("%v %q for %s events: %v", e.Kind, e.Expression, e.EventType, e.Err)
+	}
+
+	return fmt.Sprintf("%v %q: %v", e.Kind, e.Expression, e.Err)
+}
+
+func (e *ExpressionError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match the Kind of the error.
+func (e *ExpressionError) Is(target error) bool {
+	return target == e.Kind
+}
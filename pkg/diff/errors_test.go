@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestExpressionErrors(t *testing.T) {
+	testcases := []struct {
+		name       string
+		opt        Options
+		kind       error
+		expression string
+		message    string
+	}{
+		{
+			name:       "JSON path",
+			opt:        Options{JSONPath: "{.spec"},
+			kind:       ErrInvalidJSONPath,
+			expression: "{.spec",
+			message:    "invalid JSON path: unclosed action",
+		},
+		{
+			name:       "exclude path",
+			opt:        Options{ExcludePaths: []string{"..."}},
+			kind:       ErrInvalidExcludePath,
+			expression: "...",
+			message:    `invalid exclude expression "...": path does not contain a single path element`,
+		},
+		{
+			name:       "semantic ignore path",
+			opt:        Options{IgnorePaths: []string{".."}},
+			kind:       ErrInvalidIgnorePath,
+			expression: "..",
+			message:    `invalid semantic ignore expression "..": path does not contain a single path element`,
+		},
+		{
+			name:       "include path with wildcard",
+			opt:        Options{IncludePaths: []string{"metadata.annotations.*"}},
+			kind:       ErrInvalidIncludePath,
+			expression: "metadata.annotations.*",
+			message:    `invalid include expression "metadata.annotations.*": wildcards and array segments are only supported for hidden paths`,
+		},
+		{
+			name:       "semantic ignore path with array index",
+			opt:        Options{IgnorePaths: []string{"spec.containers[0].image"}},
+			kind:       ErrInvalidIgnorePath,
+			expression: "spec.containers[0].image",
+			message:    `invalid semantic ignore expression "spec.containers[0].image": wildcards and array segments are only supported for hidden paths`,
+		},
+		{
+			name: "event specific include path",
+			opt: Options{
+				EventFilters: map[watch.EventType]*EventFilter{
+					watch.Modified: {IncludePaths: []string{"."}},
+				},
+			},
+			kind:       ErrInvalidIncludePath,
+			expression: ".",
+			message:    `invalid include expression "." for MODIFIED events: path does not contain a single path element`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			err := testcase.opt.Validate()
+			if err == nil {
+				t.Fatal("Expected an error, but got none.")
+			}
+
+			if !errors.Is(err, testcase.kind) {
+				t.Errorf("Expected error to be %v, but got %v.", testcase.kind, err)
+			}
+
+			var exprErr *ExpressionError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("Expected an ExpressionError, but got %T.", err)
+			}
+
+			if exprErr.Expression != testcase.expression {
+				t.Errorf("Expected expression %q, but got %q.", testcase.expression, exprErr.Expression)
+			}
+
+			if err.Error() != testcase.message {
+				t.Errorf("Expected %q, but got %q.", testcase.message, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shibukawa/cdiff"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// isKubernetesEvent returns true for core/v1 and events.k8s.io/v1 Events.
+func isKubernetesEvent(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+
+	return gvk.Kind == "Event" && (gvk.Group == "" || gvk.Group == "events.k8s.io")
+}
+
+// eventDedupKey identifies repeats of the same Event, even if they are
+// reported using different Event objects.
+func eventDedupKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", eventInvolvedObject(obj), eventString(obj, "reason"))
+}
+
+// eventInvolvedObject returns "Kind ns/name" of the object the event is
+// about ("involvedObject" in core/v1, "regarding" in events.k8s.io/v1).
+func eventInvolvedObject(obj *unstructured.Unstructured) string {
+	field := "involvedObject"
+	if obj.GroupVersionKind().Group == "events.k8s.io" {
+		field = "regarding"
+	}
+
+	kind := eventString(obj, field, "kind")
+	name := eventString(obj, field, "name")
+	if ns := eventString(obj, field, "namespace"); ns != "" {
+		name = fmt.Sprintf("%s/%s", ns, name)
+	}
+
+	return fmt.Sprintf("%s %s", kind, name)
+}
+
+// eventCount returns how often the event has occurred. Events without a
+// count have occurred once.
+func eventCount(obj *unstructured.Unstructured) int64 {
+	paths := [][]string{
+		{"count"},
+		{"series", "count"},
+		{"deprecatedCount"},
+	}
+
+	for _, path := range paths {
+		if count, found, _ := unstructured.NestedInt64(obj.Object, path...); found && count > 0 {
+			return count
+		}
+	}
+
+	return 1
+}
+
+func eventString(obj *unstructured.Unstructured, path ...string) string {
+	value, _, _ := unstructured.NestedString(obj.Object, path...)
+	return value
+}
+
+func eventMessage(obj *unstructured.Unstructured) string {
+	message := eventString(obj, "message")
+	if message == "" {
+		message = eventString(obj, "note")
+	}
+
+	return strings.TrimSpace(message)
+}
+
+// eventCounter tracks the counts of all live Kubernetes Events. Events with
+// the same dedup key can be separate objects, so their counts are added up.
+type eventCounter struct {
+	counts map[types.UID]int64
+	byKey  map[string]map[types.UID]struct{}
+}
+
+func newEventCounter() *eventCounter {
+	return &eventCounter{
+		counts: map[types.UID]int64{},
+		byKey:  map[string]map[types.UID]struct{}{},
+	}
+}
+
+// observe records the current count of the Event and returns the total
+// count of all Events with the same dedup key before and after. seen is
+// false if there was no such Event before.
+func (c *eventCounter) observe(obj *unstructured.Unstructured) (previous int64, current int64, seen bool) {
+	key := eventDedupKey(obj)
+	uids, seen := c.byKey[key]
+	if !seen {
+		uids = map[types.UID]struct{}{}
+		c.byKey[key] = uids
+	}
+
+	previous = c.total(uids)
+	uids[obj.GetUID()] = struct{}{}
+	c.counts[obj.GetUID()] = eventCount(obj)
+
+	return previous, c.total(uids), seen
+}
+
+// forget removes a deleted Event, so that the counts do not grow forever in
+// long sessions (Events expire after an hour by default).
+func (c *eventCounter) forget(obj *unstructured.Unstructured) {
+	key := eventDedupKey(obj)
+
+	delete(c.counts, obj.GetUID())
+	delete(c.byKey[key], obj.GetUID())
+
+	if len(c.byKey[key]) == 0 {
+		delete(c.byKey, key)
+	}
+}
+
+func (c *eventCounter) total(uids map[types.UID]struct{}) int64 {
+	total := int64(0)
+	for uid := range uids {
+		total += c.counts[uid]
+	}
+
+	return total
+}
+
+// PrintEventRepeat renders a single line like "15:04:05 Event Pod
+// default/nginx BackOff: Back-off restarting failed container (x5 → x7)" for
+// an Event that has been seen before, instead of a full diff.
+func (d *Differ) PrintEventRepeat(out io.Writer, obj *unstructured.Unstructured, previousCount int64, count int64, info TitleInfo) error {
+	involved := eventInvolvedObject(obj)
+	if info.KeyPrefix != "" {
+		involved = fmt.Sprintf("%s:%s", info.KeyPrefix, involved)
+	}
+
+	parts := []string{
+		d.eventTime(obj, d.now()).Format("15:04:05"),
+		"Event",
+		involved,
+		eventString(obj, "reason") + ":",
+		eventMessage(obj),
+		fmt.Sprintf("(x%d → x%d)", previousCount, count),
+	}
+
+	parts = append(parts, info.annotations()...)
+
+	_, err := fmt.Fprintln(out, d.opt.UpdateColorTheme[cdiff.OpenHeader].Sprint(strings.Join(parts, " ")))
+
+	return err
+}
@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestEventDedup(t *testing.T) {
+	testcases := []struct {
+		name          string
+		object        string
+		expectedKey   string
+		expectedCount int64
+	}{
+		{
+			name: "core event",
+			object: `
+apiVersion: v1
+kind: Event
+metadata:
+  name: nginx.17a
+  namespace: default
+reason: BackOff
+count: 5
+involvedObject:
+  kind: Pod
+  name: nginx
+  namespace: default
+`,
+			expectedKey:   "Pod default/nginx/BackOff",
+			expectedCount: 5,
+		},
+		{
+			name: "events.k8s.io event with series",
+			object: `
+apiVersion: events.k8s.io/v1
+kind: Event
+metadata:
+  name: nginx.17a
+  namespace: default
+reason: BackOff
+series:
+  count: 7
+regarding:
+  kind: Pod
+  name: nginx
+  namespace: default
+`,
+			expectedKey:   "Pod default/nginx/BackOff",
+			expectedCount: 7,
+		},
+		{
+			name: "event without count",
+			object: `
+apiVersion: v1
+kind: Event
+metadata:
+  name: node.17a
+reason: NodeReady
+involvedObject:
+  kind: Node
+  name: worker-1
+`,
+			expectedKey:   "Node worker-1/NodeReady",
+			expectedCount: 1,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			obj := parseObject(t, testcase.object)
+
+			if !isKubernetesEvent(obj) {
+				t.Fatal("Expected object to be recognized as an Event.")
+			}
+
+			if key := eventDedupKey(obj); key != testcase.expectedKey {
+				t.Errorf("Expected key %q, but got %q.", testcase.expectedKey, key)
+			}
+
+			if count := eventCount(obj); count != testcase.expectedCount {
+				t.Errorf("Expected count %d, but got %d.", testcase.expectedCount, count)
+			}
+		})
+	}
+}
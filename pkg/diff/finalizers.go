@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// isTerminating returns true if the object is being deleted, but is still
+// kept around by its finalizers.
+func isTerminating(obj *unstructured.Unstructured) bool {
+	return obj != nil && obj.GetDeletionTimestamp() != nil
+}
+
+// PrintFinalizersLine renders a single line like "15:04:05 MODIFIED
+// Namespace test finalizers remaining: [kubernetes] (removed: example.com/foo)"
+// for objects that are being deleted. Updates that did not change the
+// finalizers are not shown, unless they started the deletion.
+func (d *Differ) PrintFinalizersLine(out io.Writer, eventType watch.EventType, oldObj, newObj *unstructured.Unstructured, info TitleInfo) error {
+	obj := newObj
+	if obj == nil {
+		obj = oldObj
+	}
+
+	parts := []string{
+		d.eventTime(obj, d.now()).Format("15:04:05"),
+		string(eventType),
+		obj.GroupVersionKind().Kind,
+		info.key(obj),
+	}
+
+	if eventType == watch.Deleted {
+		parts = append(parts, "is gone")
+	} else {
+		remaining := obj.GetFinalizers()
+		removed := removedFinalizers(oldObj, newObj)
+
+		if isTerminating(oldObj) && len(removed) == 0 && len(remaining) == len(oldObj.GetFinalizers()) {
+			return nil
+		}
+
+		parts = append(parts, fmt.Sprintf("finalizers remaining: [%s]", strings.Join(remaining, ", ")))
+
+		if len(removed) > 0 {
+			parts = append(parts, fmt.Sprintf("(removed: %s)", strings.Join(removed, ", ")))
+		}
+	}
+
+	parts = append(parts, info.annotations()...)
+
+	_, err := fmt.Fprintln(out, strings.Join(parts, " "))
+
+	return err
+}
+
+// removedFinalizers returns all finalizers of the old object that the new
+// object does not have anymore.
+func removedFinalizers(oldObj, newObj *unstructured.Unstructured) []string {
+	if oldObj == nil || newObj == nil {
+		return nil
+	}
+
+	remaining := map[string]struct{}{}
+	for _, finalizer := range newObj.GetFinalizers() {
+		remaining[finalizer] = struct{}{}
+	}
+
+	removed := []string{}
+	for _, finalizer := range oldObj.GetFinalizers() {
+		if _, ok := remaining[finalizer]; !ok {
+			removed = append(removed, finalizer)
+		}
+	}
+
+	return removed
+}
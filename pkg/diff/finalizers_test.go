@@ -0,0 +1,101 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const finalizersActive = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+  finalizers:
+    - example.com/foo
+    - kubernetes
+`
+
+const finalizersTerminating = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+  deletionTimestamp: "2022-09-01T11:59:00Z"
+  finalizers:
+    - example.com/foo
+    - kubernetes
+`
+
+const finalizersRemoved = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+  deletionTimestamp: "2022-09-01T11:59:00Z"
+  finalizers:
+    - kubernetes
+`
+
+func TestPrintFinalizersLine(t *testing.T) {
+	testcases := []struct {
+		name      string
+		eventType watch.EventType
+		old       string
+		new       string
+		expected  string
+	}{
+		{
+			name:      "deletion started",
+			eventType: watch.Modified,
+			old:       finalizersActive,
+			new:       finalizersTerminating,
+			expected:  "12:00:00 MODIFIED Namespace test finalizers remaining: [example.com/foo, kubernetes]\n",
+		},
+		{
+			name:      "finalizer removed",
+			eventType: watch.Modified,
+			old:       finalizersTerminating,
+			new:       finalizersRemoved,
+			expected:  "12:00:00 MODIFIED Namespace test finalizers remaining: [kubernetes] (removed: example.com/foo)\n",
+		},
+		{
+			name:      "unrelated changes are skipped",
+			eventType: watch.Modified,
+			old:       finalizersRemoved,
+			new:       finalizersRemoved,
+			expected:  "",
+		},
+		{
+			name:      "object is gone",
+			eventType: watch.Deleted,
+			old:       finalizersRemoved,
+			expected:  "12:00:00 DELETED Namespace test is gone\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			differ, err := NewDiffer(&Options{Finalizers: true}, logrus.New())
+			if err != nil {
+				t.Fatalf("failed to create differ: %v", err)
+			}
+
+			differ.now = func() time.Time {
+				return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+			}
+
+			var buf bytes.Buffer
+			if err := differ.PrintFinalizersLine(&buf, testcase.eventType, parseObject(t, testcase.old), parseObject(t, testcase.new), TitleInfo{}); err != nil {
+				t.Fatalf("failed to print line: %v", err)
+			}
+
+			if actual := buf.String(); actual != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package diff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	helmReleaseType = "helm.sh/release.v1"
+	helmOwnerLabel  = "owner"
+	helmOwner       = "helm"
+)
+
+// gzipMagic marks compressed releases; older Helm versions stored them
+// uncompressed.
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// helmRelease is the subset of a Helm release that is worth diffing.
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status      string `json:"status"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Config   map[string]interface{} `json:"config"`
+	Manifest string                 `json:"manifest"`
+}
+
+// isHelmRelease returns true if the object is a Secret in which Helm
+// stores a release.
+func isHelmRelease(obj map[string]interface{}) bool {
+	if obj["kind"] != "Secret" {
+		return false
+	}
+
+	if obj["type"] == helmReleaseType {
+		return true
+	}
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	labels, _ := metadata["labels"].(map[string]interface{})
+
+	return labels[helmOwnerLabel] == helmOwner
+}
+
+// decodeHelmRelease replaces the encoded release in a Helm release Secret
+// with its chart, values and manifest, so that changes to them can be
+// diffed like any other field.
+func decodeHelmRelease(obj map[string]interface{}) error {
+	data, ok := obj["data"].(map[string]interface{})
+	if !ok {
+		return errors.New("secret has no data")
+	}
+
+	encoded, ok := data["release"].(string)
+	if !ok {
+		return errors.New("secret has no release")
+	}
+
+	release, err := parseHelmRelease(encoded)
+	if err != nil {
+		return err
+	}
+
+	decoded := map[string]interface{}{
+		"name":      release.Name,
+		"namespace": release.Namespace,
+		"version":   release.Version,
+		"status":    release.Info.Status,
+		"chart":     fmt.Sprintf("%s-%s", release.Chart.Metadata.Name, release.Chart.Metadata.Version),
+		"manifest":  release.Manifest,
+	}
+
+	if release.Info.Description != "" {
+		decoded["description"] = release.Info.Description
+	}
+
+	if release.Chart.Metadata.AppVersion != "" {
+		decoded["appVersion"] = release.Chart.Metadata.AppVersion
+	}
+
+	if len(release.Config) > 0 {
+		decoded["values"] = release.Config
+	}
+
+	data["release"] = decoded
+
+	return nil
+}
+
+// parseHelmRelease decodes the release as it is stored in a Secret's data:
+// base64 encoded by Kubernetes, base64 encoded again by Helm, usually
+// gzipped and finally JSON.
+func parseHelmRelease(encoded string) (*helmRelease, error) {
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret data: %w", err)
+	}
+
+	payload, err = base64.StdEncoding.DecodeString(string(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+
+	if bytes.HasPrefix(payload, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+		defer reader.Close()
+
+		payload, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+	}
+
+	release := &helmRelease{}
+	if err := json.Unmarshal(payload, release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+
+	return release, nil
+}
@@ -0,0 +1,128 @@
+package diff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+)
+
+func encodeHelmRelease(t *testing.T, release string, compress bool) string {
+	payload := []byte(release)
+
+	if compress {
+		var buf bytes.Buffer
+
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(payload); err != nil {
+			t.Fatalf("failed to compress release: %v", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("failed to compress release: %v", err)
+		}
+
+		payload = buf.Bytes()
+	}
+
+	helmEncoded := base64.StdEncoding.EncodeToString(payload)
+
+	return base64.StdEncoding.EncodeToString([]byte(helmEncoded))
+}
+
+func TestDecodeHelmRelease(t *testing.T) {
+	release := `{
+		"name": "web",
+		"namespace": "default",
+		"version": 2,
+		"info": {"status": "deployed", "description": "Upgrade complete"},
+		"chart": {"metadata": {"name": "nginx", "version": "1.2.3", "appVersion": "1.23"}},
+		"config": {"replicaCount": 3},
+		"manifest": "kind: Deployment\n"
+	}`
+
+	for _, compress := range []bool{true, false} {
+		secret := map[string]interface{}{
+			"kind": "Secret",
+			"type": helmReleaseType,
+			"data": map[string]interface{}{
+				"release": encodeHelmRelease(t, release, compress),
+			},
+		}
+
+		if !isHelmRelease(secret) {
+			t.Fatal("Expected secret to be detected as a Helm release.")
+		}
+
+		if err := decodeHelmRelease(secret); err != nil {
+			t.Fatalf("Expected no error, but got %v.", err)
+		}
+
+		decoded := secret["data"].(map[string]interface{})["release"].(map[string]interface{})
+
+		expected := map[string]interface{}{
+			"name":        "web",
+			"namespace":   "default",
+			"version":     2,
+			"status":      "deployed",
+			"description": "Upgrade complete",
+			"chart":       "nginx-1.2.3",
+			"appVersion":  "1.23",
+			"manifest":    "kind: Deployment\n",
+		}
+
+		for key, value := range expected {
+			if decoded[key] != value {
+				t.Errorf("Expected %s to be %v, but got %v.", key, value, decoded[key])
+			}
+		}
+
+		values, ok := decoded["values"].(map[string]interface{})
+		if !ok || values["replicaCount"] != float64(3) {
+			t.Errorf("Expected values with replicaCount=3, but got %v.", decoded["values"])
+		}
+	}
+}
+
+func TestIsHelmRelease(t *testing.T) {
+	testcases := []struct {
+		name     string
+		obj      map[string]interface{}
+		expected bool
+	}{
+		{
+			name:     "release type",
+			obj:      map[string]interface{}{"kind": "Secret", "type": helmReleaseType},
+			expected: true,
+		},
+		{
+			name: "owner label",
+			obj: map[string]interface{}{
+				"kind":     "Secret",
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"owner": "helm"}},
+			},
+			expected: true,
+		},
+		{
+			name:     "other secret",
+			obj:      map[string]interface{}{"kind": "Secret", "type": "Opaque"},
+			expected: false,
+		},
+		{
+			name: "other kind",
+			obj: map[string]interface{}{
+				"kind":     "ConfigMap",
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"owner": "helm"}},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := isHelmRelease(tc.obj); result != tc.expected {
+				t.Errorf("Expected %v, but got %v.", tc.expected, result)
+			}
+		})
+	}
+}
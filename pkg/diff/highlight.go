@@ -0,0 +1,102 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// HighlightStyle is used to mark text matching the highlight expression.
+var HighlightStyle = color.New(color.FgBlack, color.BgYellow)
+
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// highlight marks all matches of the expression in the already rendered
+// (and possibly colored) text. Matches are searched line by line, ignoring
+// any color codes, so that they can span multiple differently colored
+// fragments.
+func highlight(text string, expr *regexp.Regexp, style color.Style) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = highlightLine(line, expr, style)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func highlightLine(line string, expr *regexp.Regexp, style color.Style) string {
+	plain := ansiSequence.ReplaceAllString(line, "")
+
+	matches := expr.FindAllStringIndex(plain, -1)
+	if len(matches) == 0 {
+		return line
+	}
+
+	var (
+		builder  strings.Builder
+		matched  strings.Builder
+		active   string // color codes in effect since the last reset
+		plainPos int
+		matchIdx int
+	)
+
+	inMatch := func(pos int) bool {
+		for matchIdx < len(matches) && matches[matchIdx][1] <= pos {
+			matchIdx++
+		}
+
+		return matchIdx < len(matches) && matches[matchIdx][0] <= pos
+	}
+
+	// after a match, restore the colors of the surrounding text
+	flush := func() {
+		builder.WriteString(style.Sprint(matched.String()))
+		builder.WriteString(active)
+		matched.Reset()
+	}
+
+	sequences := ansiSequence.FindAllStringIndex(line, -1)
+	pos := 0
+
+	for pos < len(line) {
+		if len(sequences) > 0 && sequences[0][0] == pos {
+			sequence := line[pos:sequences[0][1]]
+			sequences = sequences[1:]
+
+			if sequence == "\x1b[0m" {
+				active = ""
+			} else {
+				active += sequence
+			}
+
+			// sequences inside a match are dropped, as they would
+			// override the highlight
+			if matched.Len() == 0 {
+				builder.WriteString(sequence)
+			}
+
+			pos += len(sequence)
+			continue
+		}
+
+		if inMatch(plainPos) {
+			matched.WriteByte(line[pos])
+		} else {
+			if matched.Len() > 0 {
+				flush()
+			}
+
+			builder.WriteByte(line[pos])
+		}
+
+		pos++
+		plainPos++
+	}
+
+	if matched.Len() > 0 {
+		flush()
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/gookit/color"
+)
+
+func TestHighlightLine(t *testing.T) {
+	color.Enable = true
+	defer color.Disable()
+
+	style := color.New(color.FgBlack, color.BgYellow)
+	hl := func(s string) string {
+		return "\x1b[30;43m" + s + "\x1b[0m"
+	}
+
+	testcases := []struct {
+		name     string
+		line     string
+		expr     string
+		expected string
+	}{
+		{
+			name:     "no match",
+			line:     "+  image: nginx:1.22",
+			expr:     "1\\.23",
+			expected: "+  image: nginx:1.22",
+		},
+		{
+			name:     "plain text",
+			line:     "+  image: nginx:1.22",
+			expr:     "nginx:\\S+",
+			expected: "+  image: " + hl("nginx:1.22"),
+		},
+		{
+			name:     "multiple matches",
+			line:     " a: error, b: error",
+			expr:     "error",
+			expected: " a: " + hl("error") + ", b: " + hl("error"),
+		},
+		{
+			name:     "restores color after match",
+			line:     "\x1b[32m+ foo bar\x1b[0m",
+			expr:     "foo",
+			expected: "\x1b[32m+ " + hl("foo") + "\x1b[32m bar\x1b[0m",
+		},
+		{
+			name:     "match spanning colored fragments",
+			line:     "\x1b[32m+ fo\x1b[0m\x1b[92mo bar\x1b[0m",
+			expr:     "foo",
+			expected: "\x1b[32m+ " + hl("foo") + "\x1b[92m bar\x1b[0m",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			result := highlightLine(testcase.line, regexp.MustCompile(testcase.expr), style)
+			if result != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, result)
+			}
+		})
+	}
+}
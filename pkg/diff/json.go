@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/yaml"
+)
+
+// jsonEvent is a single line of JSON output.
+type jsonEvent struct {
+	EventType       string      `json:"eventType"`
+	Cluster         string      `json:"cluster,omitempty"`
+	Kind            string      `json:"kind"`
+	Namespace       string      `json:"namespace,omitempty"`
+	Name            string      `json:"name"`
+	ResourceVersion string      `json:"resourceVersion"`
+	Generation      int64       `json:"generation,omitempty"`
+	Timestamp       time.Time   `json:"timestamp"`
+	Annotations     []string    `json:"annotations,omitempty"`
+	Diff            string      `json:"diff,omitempty"`
+	Object          interface{} `json:"object,omitempty"`
+}
+
+// printJSON renders the event as a single line of JSON. Updates contain
+// the unified diff, creations and deletions the (last) state of the object,
+// with the same fields hidden or shown as in a diff. With a JSONPath, the
+// object is whatever the expression selected, e.g. a single number.
+func (d *Differ) printJSON(out io.Writer, eventType watch.EventType, newObj, oldObj *unstructured.Unstructured, oldString, newString string, info TitleInfo) error {
+	obj := newObj
+	if obj == nil {
+		obj = oldObj
+	}
+
+	event := jsonEvent{
+		EventType:       yamlEventNames[eventType],
+		Cluster:         info.KeyPrefix,
+		Kind:            obj.GetKind(),
+		Namespace:       obj.GetNamespace(),
+		Name:            obj.GetName(),
+		ResourceVersion: obj.GetResourceVersion(),
+		Generation:      obj.GetGeneration(),
+		Timestamp:       d.eventTime(obj, d.now()),
+		Annotations:     info.annotations(),
+	}
+
+	if oldObj != nil && newObj != nil {
+		body, err := renderDifflib(oldString, newString, d.contextLines(oldString, newString))
+		if err != nil {
+			return fmt.Errorf("failed to create diff: %w", err)
+		}
+
+		event.Diff = body
+	} else {
+		objString := newString
+		if newObj == nil {
+			objString = oldString
+		}
+
+		if err := yaml.Unmarshal([]byte(objString), &event.Object); err != nil {
+			return fmt.Errorf("failed to encode object: %w", err)
+		}
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	// write the entire line at once, so it cannot be torn apart by a
+	// partially successful write
+	_, err = fmt.Fprintln(out, string(encoded))
+
+	return err
+}
@@ -0,0 +1,49 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// markdownHeading names the object that a diff belongs to.
+func markdownHeading(oldObj, newObj *unstructured.Unstructured, info TitleInfo) string {
+	obj := newObj
+	if obj == nil {
+		obj = oldObj
+	}
+
+	return fmt.Sprintf("### %s %s%s", obj.GroupVersionKind().Kind, info.key(obj), info.suffix())
+}
+
+// markdownSection wraps a plain diff in a fenced code block, so that it is
+// highlighted when pasted into GitHub or GitLab. The heading is omitted
+// if empty.
+func markdownSection(heading string, diff string) string {
+	var builder strings.Builder
+
+	if heading != "" {
+		builder.WriteString(heading)
+		builder.WriteString("\n\n")
+	}
+
+	// the fence must be longer than any backtick sequence in the diff
+	fence := "```"
+	for strings.Contains(diff, fence) {
+		fence += "`"
+	}
+
+	builder.WriteString(fence)
+	builder.WriteString("diff\n")
+	builder.WriteString(diff)
+
+	if !strings.HasSuffix(diff, "\n") {
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString(fence)
+	builder.WriteString("\n")
+
+	return builder.String()
+}
@@ -0,0 +1,45 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestMarkdownSection(t *testing.T) {
+	testcases := []struct {
+		name     string
+		heading  string
+		diff     string
+		expected string
+	}{
+		{
+			name:     "with heading",
+			heading:  "### ConfigMap default/foo",
+			diff:     "-a\n+b\n",
+			expected: "### ConfigMap default/foo\n\n```diff\n-a\n+b\n```\n",
+		},
+		{
+			name:     "without heading",
+			diff:     "-a\n+b\n",
+			expected: "```diff\n-a\n+b\n```\n",
+		},
+		{
+			name:     "missing trailing newline",
+			diff:     "-a",
+			expected: "```diff\n-a\n```\n",
+		},
+		{
+			name:     "diff contains a fence",
+			diff:     "+```\n",
+			expected: "````diff\n+```\n````\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			actual := markdownSection(testcase.heading, testcase.diff)
+			if actual != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, actual)
+			}
+		})
+	}
+}
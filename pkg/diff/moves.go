@@ -0,0 +1,161 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/shibukawa/cdiff"
+)
+
+// moves describes the lines of a diff result that have been moved.
+type moves struct {
+	// lines are the indices of inserted lines that were removed elsewhere.
+	lines map[int]bool
+	// starts are the indices of the first line of each moved block.
+	starts map[int]bool
+}
+
+func (m *moves) isMoved(index int) bool {
+	return m != nil && m.lines[index]
+}
+
+type block struct {
+	start int
+	end   int // exclusive
+}
+
+// detectMoves finds blocks of deleted lines that reappear as a block of
+// inserted lines elsewhere, like a list element that changed its position.
+// The deleted lines are removed from the returned result and the inserted
+// lines are reported as moved.
+func detectMoves(result cdiff.Result) (cdiff.Result, *moves) {
+	deleted := changeBlocks(result.Lines, cdiff.Delete)
+	inserted := changeBlocks(result.Lines, cdiff.Insert)
+
+	hidden := map[int]bool{}
+	movedLines := map[int]bool{}
+	movedStarts := map[int]bool{}
+	used := map[int]bool{}
+
+	for _, d := range deleted {
+		variants := blockVariants(result.Lines, d)
+		if strings.TrimSpace(blockText(result.Lines, d)) == "" {
+			continue
+		}
+
+		for i, ins := range inserted {
+			if used[i] || !anyVariantMatches(variants, blockVariants(result.Lines, ins)) {
+				continue
+			}
+
+			used[i] = true
+
+			for j := d.start; j < d.end; j++ {
+				hidden[j] = true
+			}
+
+			for j := ins.start; j < ins.end; j++ {
+				movedLines[j] = true
+			}
+
+			movedStarts[ins.start] = true
+
+			break
+		}
+	}
+
+	m := &moves{
+		lines:  map[int]bool{},
+		starts: map[int]bool{},
+	}
+
+	filtered := []cdiff.Line{}
+	for i, line := range result.Lines {
+		if hidden[i] {
+			continue
+		}
+
+		if movedLines[i] {
+			m.lines[len(filtered)] = true
+		}
+
+		if movedStarts[i] {
+			m.starts[len(filtered)] = true
+		}
+
+		filtered = append(filtered, line)
+	}
+
+	return cdiff.Result{Lines: filtered}, m
+}
+
+// changeBlocks returns all maximal runs of consecutive lines with the given
+// operation.
+func changeBlocks(lines []cdiff.Line, ope cdiff.Ope) []block {
+	blocks := []block{}
+
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Ope != ope {
+			continue
+		}
+
+		start := i
+		for i < len(lines) && lines[i].Ope == ope {
+			i++
+		}
+
+		blocks = append(blocks, block{start: start, end: i})
+	}
+
+	return blocks
+}
+
+// blockVariants returns the texts of the block and of all equivalent blocks
+// it can be shifted to. Diffs are ambiguous when a block is surrounded by
+// lines equal to its own, e.g. deleting "b, a" from "a, b, a" could also be
+// shown as deleting "a, b".
+func blockVariants(lines []cdiff.Line, b block) []string {
+	variants := []string{blockText(lines, b)}
+
+	for shifted := b; shifted.start > 0; {
+		before, last := lines[shifted.start-1], lines[shifted.end-1]
+		if before.Ope != cdiff.Keep || before.String() != last.String() {
+			break
+		}
+
+		shifted = block{start: shifted.start - 1, end: shifted.end - 1}
+		variants = append(variants, blockText(lines, shifted))
+	}
+
+	for shifted := b; shifted.end < len(lines); {
+		after, first := lines[shifted.end], lines[shifted.start]
+		if after.Ope != cdiff.Keep || after.String() != first.String() {
+			break
+		}
+
+		shifted = block{start: shifted.start + 1, end: shifted.end + 1}
+		variants = append(variants, blockText(lines, shifted))
+	}
+
+	return variants
+}
+
+func anyVariantMatches(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func blockText(lines []cdiff.Line, b block) string {
+	texts := []string{}
+	for _, line := range lines[b.start:b.end] {
+		texts = append(texts, line.String())
+	}
+
+	return strings.Join(texts, "\n")
+}
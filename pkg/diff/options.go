@@ -3,18 +3,159 @@ package diff
 import (
 	"errors"
 	"fmt"
+	"regexp"
 
 	"go.xrstf.de/stalk/pkg/maputil"
 
 	"github.com/gookit/color"
 	"github.com/shibukawa/cdiff"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/util/jsonpath"
 )
 
+const (
+	// AlgorithmCDiff produces colored diffs with highlighting of changes
+	// within lines, which is best for interactive use.
+	AlgorithmCDiff = "cdiff"
+
+	// AlgorithmDifflib produces plain unified diffs, which are easier to
+	// process by other tools.
+	AlgorithmDifflib = "difflib"
+)
+
+var Algorithms = []string{AlgorithmCDiff, AlgorithmDifflib}
+
+const (
+	// OutputDiff prints every event as a diff.
+	OutputDiff = "diff"
+
+	// OutputMarkdown wraps every diff in a fenced code block below a
+	// heading, ready to be pasted into issues or pull requests.
+	OutputMarkdown = "markdown"
+
+	// OutputYAML prints the current state of every object as a YAML
+	// document instead of a diff, so the output can be processed by other
+	// tools or applied to a cluster.
+	OutputYAML = "yaml"
+
+	// OutputJSON prints every event as a single line of JSON, containing
+	// the diff of updates and the full object of creations and deletions,
+	// to feed the events into log pipelines.
+	OutputJSON = "json"
+)
+
+var Outputs = []string{OutputDiff, OutputMarkdown, OutputYAML, OutputJSON}
+
+const (
+	// EventTimeReceived shows when stalk received an event.
+	EventTimeReceived = "received"
+
+	// EventTimeObject shows the latest time recorded in the object itself
+	// (its managed fields, creation or deletion timestamp), which is more
+	// meaningful for delayed or replayed events.
+	EventTimeObject = "object"
+)
+
+var EventTimes = []string{EventTimeReceived, EventTimeObject}
+
 type Options struct {
-	ContextLines    int
+	// Algorithm is one of the Algorithm* constants; if empty, cdiff is used.
+	Algorithm string
+
+	// Output is one of the Output* constants; if empty, plain diffs are
+	// printed. Markdown output always uses uncolored diffs.
+	Output string
+
+	// Whitespace is one of the Whitespace* constants; if empty,
+	// insignificant whitespace is ignored.
+	Whitespace string
+
+	// EventTime is one of the EventTime* constants; if empty, the time an
+	// event was received is shown.
+	EventTime string
+
+	// ContextLines is the number of unchanged lines shown around each
+	// change. With 0, only the changed lines are shown, a negative number
+	// shows the entire object.
+	ContextLines int
+
 	HideEmptyDiffs  bool
 	DisableWordDiff bool
+	CompactTitle    bool
+
+	// AutoContext chooses the number of context lines based on the size
+	// of each object, instead of using ContextLines.
+	AutoContext bool
+
+	// DetectMoves renders blocks of lines that were removed in one place
+	// and inserted in another as a single move. This is not supported by
+	// the difflib algorithm.
+	DetectMoves bool
+
+	// CollapseArrays replaces runs of unchanged items in large arrays with
+	// a single marker when diffing two objects.
+	CollapseArrays bool
+
+	// LineNumbers prefixes every line of a diff with its line number in
+	// the new object.
+	LineNumbers bool
+
+	// Sectioned renders the diff of every top-level field (like "spec" or
+	// "status") separately below its own header, leaving out the fields
+	// that did not change.
+	Sectioned bool
+
+	// RelativeTimes shows all timestamps in the objects as durations
+	// relative to the current time, e.g. "2m ago".
+	RelativeTimes bool
+
+	// DecodeHelm replaces the encoded release in Secrets managed by Helm
+	// with the release's chart, values and manifest.
+	DecodeHelm bool
+
+	// StripDefaults removes fields that still have the value the API server
+	// defaulted them to, so that objects resemble the manifests they were
+	// created from. The BuiltinDefaults are extended by the ExtraDefaults,
+	// which are in the form "KIND:PATH=VALUE".
+	StripDefaults       bool
+	ExtraDefaults       []string
+	parsedDefaultFields []DefaultField
+
+	// NoHeaders omits the title of each diff entirely, leaving only the
+	// diff bodies.
+	NoHeaders bool
+
+	// Quiet prints a single line per event instead of a diff.
+	Quiet bool
+
+	// Rollout prints a single line with the rollout progress for workloads
+	// (Deployments, StatefulSets and DaemonSets) instead of a diff.
+	Rollout bool
+
+	// Finalizers prints a single line with the remaining finalizers instead
+	// of a diff for objects that are being deleted.
+	Finalizers bool
+
+	// Ticker prints a single, constantly updated line with the value of the
+	// JSONPath and when it last changed instead of a diff, e.g.
+	// "Deployment default/web readyReplicas: 3 (changed 12s ago)".
+	Ticker bool
+
+	// QuietField is a JSON path whose value is appended to each line in
+	// quiet mode.
+	QuietField         string
+	compiledQuietField *jsonpath.JSONPath
+
+	// SortBy is a JSON path by which all objects are sorted in quiet mode.
+	// Instead of a line per event, the current line of every object is
+	// printed in this order whenever an event happens.
+	SortBy         string
+	compiledSortBy *jsonpath.JSONPath
+
+	// Project prints a single line with the given fields per event instead
+	// of a diff, e.g. "name=metadata.name,replicas=spec.replicas".
+	Project          string
+	parsedProjection []projectedField
 
 	JSONPath         string
 	compiledJSONPath *jsonpath.JSONPath
@@ -25,20 +166,179 @@ type Options struct {
 	ExcludePaths       []string
 	parsedExcludePaths []maputil.Path
 
+	// IgnorePaths are fields whose changes are not considered real
+	// changes: they are still shown, but always with their current value,
+	// so updates that only changed these fields produce no diff.
+	IgnorePaths       []string
+	parsedIgnorePaths []maputil.Path
+
+	// EventFilters replace the IncludePaths and ExcludePaths for single
+	// types of events, e.g. to show created objects in full, but only parts
+	// of updated ones. Paths that are not set default to the global ones.
+	EventFilters map[watch.EventType]*EventFilter
+
+	// HighlightRegex marks all matching text in the rendered diffs.
+	HighlightRegex    string
+	compiledHighlight *regexp.Regexp
+
 	CreateColorTheme map[cdiff.Tag]color.Style
 	UpdateColorTheme map[cdiff.Tag]color.Style
 	DeleteColorTheme map[cdiff.Tag]color.Style
 }
 
+// EventFilter contains the include and exclude paths for a single type
+// of event.
+type EventFilter struct {
+	IncludePaths       []string
+	parsedIncludePaths []maputil.Path
+
+	ExcludePaths       []string
+	parsedExcludePaths []maputil.Path
+}
+
 func (o *Options) Validate() error {
-	if o.ContextLines < 0 {
-		return errors.New("context lines cannot be negative")
+	switch o.Algorithm {
+	case "", AlgorithmCDiff, AlgorithmDifflib:
+	default:
+		return fmt.Errorf("invalid diff algorithm %q, must be one of %v", o.Algorithm, Algorithms)
+	}
+
+	if o.Sectioned && o.LineNumbers {
+		return errors.New("sections cannot be combined with line numbers")
+	}
+
+	switch o.EventTime {
+	case "", EventTimeReceived, EventTimeObject:
+	default:
+		return fmt.Errorf("invalid event time %q, must be one of %v", o.EventTime, EventTimes)
+	}
+
+	if o.DetectMoves && o.Algorithm == AlgorithmDifflib {
+		return errors.New("move detection is not supported by the difflib algorithm")
+	}
+
+	switch o.Output {
+	case "", OutputDiff:
+	case OutputMarkdown:
+		if o.DetectMoves {
+			return errors.New("move detection is not supported by Markdown output")
+		}
+
+		if o.Quiet {
+			return errors.New("quiet mode cannot be combined with Markdown output")
+		}
+
+		if o.Rollout {
+			return errors.New("rollout mode cannot be combined with Markdown output")
+		}
+
+		if o.Finalizers {
+			return errors.New("finalizers mode cannot be combined with Markdown output")
+		}
+	case OutputYAML:
+		if o.Quiet {
+			return errors.New("quiet mode cannot be combined with YAML output")
+		}
+
+		if o.Rollout {
+			return errors.New("rollout mode cannot be combined with YAML output")
+		}
+
+		if o.Finalizers {
+			return errors.New("finalizers mode cannot be combined with YAML output")
+		}
+	case OutputJSON:
+		if o.DetectMoves {
+			return errors.New("move detection is not supported by JSON output")
+		}
+
+		if o.Quiet {
+			return errors.New("quiet mode cannot be combined with JSON output")
+		}
+
+		if o.Rollout {
+			return errors.New("rollout mode cannot be combined with JSON output")
+		}
+
+		if o.Finalizers {
+			return errors.New("finalizers mode cannot be combined with JSON output")
+		}
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of %v", o.Output, Outputs)
+	}
+
+	switch o.Whitespace {
+	case "", WhitespaceIgnore, WhitespaceShow, WhitespaceMark:
+	default:
+		return fmt.Errorf("invalid whitespace mode %q, must be one of %v", o.Whitespace, WhitespaceModes)
+	}
+
+	if o.Rollout && o.Quiet {
+		return errors.New("rollout mode cannot be combined with quiet mode")
+	}
+
+	if o.Finalizers && o.Quiet {
+		return errors.New("finalizers mode cannot be combined with quiet mode")
+	}
+
+	if o.Ticker {
+		switch {
+		case o.JSONPath == "":
+			return errors.New("ticker mode requires a JSON path")
+		case o.Quiet:
+			return errors.New("ticker mode cannot be combined with quiet mode")
+		case o.Rollout:
+			return errors.New("ticker mode cannot be combined with rollout mode")
+		case o.Finalizers:
+			return errors.New("ticker mode cannot be combined with finalizers mode")
+		case o.Project != "":
+			return errors.New("ticker mode cannot be combined with a projection")
+		case o.Output != "" && o.Output != OutputDiff:
+			return fmt.Errorf("ticker mode cannot be combined with %s output", o.Output)
+		}
+	}
+
+	if len(o.ExtraDefaults) > 0 && !o.StripDefaults {
+		return errors.New("extra defaults can only be used when stripping defaults")
+	}
+
+	if o.StripDefaults {
+		o.parsedDefaultFields = append([]DefaultField{}, BuiltinDefaults...)
+
+		for _, field := range o.ExtraDefaults {
+			parsed, err := ParseDefaultField(field)
+			if err != nil {
+				return &ExpressionError{Kind: ErrInvalidDefaultField, Expression: field, Err: err}
+			}
+
+			o.parsedDefaultFields = append(o.parsedDefaultFields, parsed)
+		}
+	}
+
+	if o.Project != "" {
+		switch {
+		case o.Quiet:
+			return errors.New("a projection cannot be combined with quiet mode")
+		case o.Rollout:
+			return errors.New("a projection cannot be combined with rollout mode")
+		case o.Finalizers:
+			return errors.New("a projection cannot be combined with finalizers mode")
+		case o.Output != "" && o.Output != OutputDiff:
+			return fmt.Errorf("a projection cannot be combined with %s output", o.Output)
+		}
+
+		fields, err := parseProjection(o.Project)
+		if err != nil {
+			return &ExpressionError{Kind: ErrInvalidProjection, Expression: o.Project, Err: err}
+		}
+
+		o.parsedProjection = fields
 	}
 
 	if o.JSONPath != "" {
 		path := jsonpath.New("mypath")
 		if err := path.Parse(o.JSONPath); err != nil {
-			return fmt.Errorf("invalid JSON path: %w", err)
+			return &ExpressionError{Kind: ErrInvalidJSONPath, Expression: o.JSONPath, Err: err}
 		}
 
 		path.EnableJSONOutput(true)
@@ -47,13 +347,52 @@ func (o *Options) Validate() error {
 		o.compiledJSONPath = path
 	}
 
+	if o.QuietField != "" {
+		if !o.Quiet {
+			return errors.New("a quiet field can only be used in quiet mode")
+		}
+
+		path := jsonpath.New("quietfield")
+		if err := path.Parse(o.QuietField); err != nil {
+			return &ExpressionError{Kind: ErrInvalidQuietField, Expression: o.QuietField, Err: err}
+		}
+
+		path.AllowMissingKeys(true)
+
+		o.compiledQuietField = path
+	}
+
+	if o.SortBy != "" {
+		if !o.Quiet {
+			return errors.New("sorting can only be used in quiet mode")
+		}
+
+		path := jsonpath.New("sortby")
+		if err := path.Parse(o.SortBy); err != nil {
+			return &ExpressionError{Kind: ErrInvalidSortBy, Expression: o.SortBy, Err: err}
+		}
+
+		path.AllowMissingKeys(true)
+
+		o.compiledSortBy = path
+	}
+
+	if o.HighlightRegex != "" {
+		expr, err := regexp.Compile(o.HighlightRegex)
+		if err != nil {
+			return &ExpressionError{Kind: ErrInvalidHighlight, Expression: o.HighlightRegex, Err: err}
+		}
+
+		o.compiledHighlight = expr
+	}
+
 	if len(o.IncludePaths) > 0 {
 		o.parsedIncludePaths = []maputil.Path{}
 
 		for _, path := range o.IncludePaths {
-			parsed, err := maputil.ParsePath(path)
+			parsed, err := parseLiteralPath(path)
 			if err != nil {
-				return fmt.Errorf("invalid include expression %q: %w", path, err)
+				return &ExpressionError{Kind: ErrInvalidIncludePath, Expression: path, Err: err}
 			}
 
 			o.parsedIncludePaths = append(o.parsedIncludePaths, parsed)
@@ -66,12 +405,61 @@ func (o *Options) Validate() error {
 		for _, path := range o.ExcludePaths {
 			parsed, err := maputil.ParsePath(path)
 			if err != nil {
-				return fmt.Errorf("invalid exclude expression %q: %w", path, err)
+				return &ExpressionError{Kind: ErrInvalidExcludePath, Expression: path, Err: err}
 			}
 
 			o.parsedExcludePaths = append(o.parsedExcludePaths, parsed)
 		}
 	}
 
+	o.parsedIgnorePaths = nil
+
+	for _, path := range o.IgnorePaths {
+		parsed, err := parseLiteralPath(path)
+		if err != nil {
+			return &ExpressionError{Kind: ErrInvalidIgnorePath, Expression: path, Err: err}
+		}
+
+		o.parsedIgnorePaths = append(o.parsedIgnorePaths, parsed)
+	}
+
+	for eventType, filter := range o.EventFilters {
+		filter.parsedIncludePaths = nil
+		filter.parsedExcludePaths = nil
+
+		for _, path := range filter.IncludePaths {
+			parsed, err := parseLiteralPath(path)
+			if err != nil {
+				return &ExpressionError{Kind: ErrInvalidIncludePath, Expression: path, EventType: eventType, Err: err}
+			}
+
+			filter.parsedIncludePaths = append(filter.parsedIncludePaths, parsed)
+		}
+
+		for _, path := range filter.ExcludePaths {
+			parsed, err := maputil.ParsePath(path)
+			if err != nil {
+				return &ExpressionError{Kind: ErrInvalidExcludePath, Expression: path, EventType: eventType, Err: err}
+			}
+
+			filter.parsedExcludePaths = append(filter.parsedExcludePaths, parsed)
+		}
+	}
+
 	return nil
 }
+
+// parseLiteralPath parses a path that must not contain wildcards or array
+// segments, which are only supported for excluded paths.
+func parseLiteralPath(path string) (maputil.Path, error) {
+	parsed, err := maputil.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !parsed.IsLiteral() {
+		return nil, errors.New("wildcards and array segments are only supported for hidden paths")
+	}
+
+	return parsed, nil
+}
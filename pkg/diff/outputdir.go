@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// unsafeFilenameChars matches everything that should not end up in a
+// filename, like the colons and slashes in kubeconfig context names.
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// objectLogFilename returns the name of the file for the events of the
+// object, in the form "[prefix_][namespace_]kind[.group]_name.log".
+func objectLogFilename(obj *unstructured.Unstructured, prefix string) string {
+	gvk := obj.GroupVersionKind()
+
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind = fmt.Sprintf("%s.%s", kind, gvk.Group)
+	}
+
+	parts := []string{}
+	if prefix != "" {
+		parts = append(parts, prefix)
+	}
+
+	if ns := obj.GetNamespace(); ns != "" {
+		parts = append(parts, ns)
+	}
+
+	parts = append(parts, kind, obj.GetName())
+
+	for i, part := range parts {
+		parts[i] = unsafeFilenameChars.ReplaceAllString(part, "_")
+	}
+
+	return strings.Join(parts, "_") + ".log"
+}
+
+// appendObjectLog appends the output of the event to the file of its
+// object in the OutputDir. Color codes are removed, as the files are meant
+// to be read later.
+func (p *Printer) appendObjectLog(obj *unstructured.Unstructured, output []byte) {
+	filename := objectLogFilename(obj, p.keyPrefix)
+	if p.opt.Anonymize {
+		filename = p.anonymizer.Anonymize(filename)
+	}
+
+	f, err := os.OpenFile(filepath.Join(p.opt.OutputDir, filename), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		p.log.Errorf("Failed to open output file: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(ansiSequence.ReplaceAll(output, nil)); err != nil {
+		p.log.Errorf("Failed to write output file: %v", err)
+	}
+}
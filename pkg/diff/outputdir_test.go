@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestObjectLogFilename(t *testing.T) {
+	obj := parseObject(t, oldDeployment)
+
+	testcases := []struct {
+		name     string
+		prefix   string
+		expected string
+	}{
+		{
+			name:     "without prefix",
+			expected: "default_deployment.apps_nginx.log",
+		},
+		{
+			name:     "unsafe prefix",
+			prefix:   "arn:aws:eks:eu-west-1:123:cluster/prod",
+			expected: "arn_aws_eks_eu-west-1_123_cluster_prod_default_deployment.apps_nginx.log",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if actual := objectLogFilename(obj, testcase.prefix); actual != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	differ, err := NewDiffer(&Options{}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	printer := NewPrinter(differ, &buf, &PrinterOptions{OutputDir: dir}, logrus.New())
+
+	oldObj := parseObject(t, oldDeployment)
+	newObj := parseObject(t, newDeployment)
+
+	printer.PrintEvent(watcher.Event{Type: watch.Added, New: oldObj})
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: oldObj, New: newObj})
+
+	content, err := os.ReadFile(filepath.Join(dir, "default_deployment.apps_nginx.log"))
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+
+	// the file contains the same events as the combined output
+	if actual, expected := string(content), ansiSequence.ReplaceAllString(buf.String(), ""); actual != expected {
+		t.Errorf("Expected %q, but got %q.", expected, actual)
+	}
+
+	if !strings.Contains(string(content), "replicas: 3") {
+		t.Errorf("Expected the update to be written, but got %q.", string(content))
+	}
+}
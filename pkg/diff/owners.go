@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ownerChanges describes how the owner references differ between both
+// objects, e.g. "adopted by ReplicaSet/foo". Owners are identified by their
+// UID.
+func ownerChanges(oldObj, newObj *unstructured.Unstructured) []string {
+	oldOwners := ownersByUID(oldObj.GetOwnerReferences())
+	newOwners := ownersByUID(newObj.GetOwnerReferences())
+
+	changes := []string{}
+
+	for _, owner := range newObj.GetOwnerReferences() {
+		previous, existed := oldOwners[owner.UID]
+
+		switch {
+		case !existed:
+			changes = append(changes, fmt.Sprintf("adopted by %s", ownerName(owner)))
+
+		case isController(previous) != isController(owner):
+			if isController(owner) {
+				changes = append(changes, fmt.Sprintf("%s became the controller", ownerName(owner)))
+			} else {
+				changes = append(changes, fmt.Sprintf("%s is not the controller anymore", ownerName(owner)))
+			}
+		}
+	}
+
+	for _, owner := range oldObj.GetOwnerReferences() {
+		if _, exists := newOwners[owner.UID]; !exists {
+			changes = append(changes, fmt.Sprintf("orphaned from %s", ownerName(owner)))
+		}
+	}
+
+	return changes
+}
+
+func ownersByUID(owners []metav1.OwnerReference) map[types.UID]metav1.OwnerReference {
+	result := map[types.UID]metav1.OwnerReference{}
+	for _, owner := range owners {
+		result[owner.UID] = owner
+	}
+
+	return result
+}
+
+func ownerName(owner metav1.OwnerReference) string {
+	return fmt.Sprintf("%s/%s", owner.Kind, owner.Name)
+}
+
+func isController(owner metav1.OwnerReference) bool {
+	return owner.Controller != nil && *owner.Controller
+}
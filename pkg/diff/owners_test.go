@@ -0,0 +1,106 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnerChanges(t *testing.T) {
+	testcases := []struct {
+		name     string
+		old      string
+		new      string
+		expected []string
+	}{
+		{
+			name: "adoption",
+			old: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+`,
+			new: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: ReplicaSet
+      name: bar
+      uid: "1"
+      controller: true
+`,
+			expected: []string{"adopted by ReplicaSet/bar"},
+		},
+		{
+			name: "orphaning and controller changes",
+			old: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: ReplicaSet
+      name: bar
+      uid: "1"
+    - apiVersion: apps/v1
+      kind: ReplicaSet
+      name: baz
+      uid: "2"
+`,
+			new: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: ReplicaSet
+      name: bar
+      uid: "1"
+      controller: true
+`,
+			expected: []string{"ReplicaSet/bar became the controller", "orphaned from ReplicaSet/baz"},
+		},
+		{
+			name: "no changes",
+			old: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: ReplicaSet
+      name: bar
+      uid: "1"
+`,
+			new: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  labels:
+    foo: bar
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: ReplicaSet
+      name: bar
+      uid: "1"
+`,
+			expected: []string{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			actual := ownerChanges(parseObject(t, testcase.old), parseObject(t, testcase.new))
+			if !reflect.DeepEqual(actual, testcase.expected) {
+				t.Errorf("Expected %v, but got %v.", testcase.expected, actual)
+			}
+		})
+	}
+}
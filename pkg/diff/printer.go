@@ -1,48 +1,725 @@
 package diff
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"go.xrstf.de/stalk/pkg/cache"
+	"go.xrstf.de/stalk/pkg/correlation"
+	"go.xrstf.de/stalk/pkg/managedfields"
+	"go.xrstf.de/stalk/pkg/otlp"
+	"go.xrstf.de/stalk/pkg/progress"
+	"go.xrstf.de/stalk/pkg/publish"
+	"go.xrstf.de/stalk/pkg/report"
+	"go.xrstf.de/stalk/pkg/watcher"
 
+	"github.com/gookit/color"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
+type PrinterOptions struct {
+	// CorrelationWindow enables tagging related events (e.g. a Deployment
+	// and its ReplicaSets) with a shared correlation ID if they happen
+	// within this duration of each other. Zero disables correlation.
+	CorrelationWindow time.Duration
+
+	// GroupByGeneration only prints updates once the generation of an
+	// object changed, showing the net change since the last printed
+	// generation. Intermediate (usually status-only) updates are skipped.
+	// Objects without a generation are not affected.
+	GroupByGeneration bool
+
+	// ShowConversionWarnings adds a note to updates that changed fields which
+	// are not owned by any field manager, i.e. that were most likely set by
+	// the API server itself (defaulting, conversion webhooks).
+	ShowConversionWarnings bool
+
+	// ShowOwnerChanges adds a note to updates that changed the owner
+	// references of an object, i.e. when it was adopted or orphaned.
+	ShowOwnerChanges bool
+
+	// ShowQuantityChanges adds a note to updates that changed resource
+	// requests or limits, comparing the old and new quantities.
+	ShowQuantityChanges bool
+
+	// ShowReconnects marks the first event after a watch had to be
+	// re-established with the number of the reconnect.
+	ShowReconnects bool
+
+	// MigrationKey is a label that links the deletion of an object to the
+	// creation of an object of another kind with the same label value, to
+	// follow objects that are migrated from one API to another.
+	MigrationKey string
+
+	// RevisionAnnotations are annotations that mark a new revision of an
+	// object when they change, in addition to the BuiltinRevisionAnnotations.
+	// Such updates are labeled like "(revision 3 → 4)".
+	RevisionAnnotations []string
+
+	// ClusterTags are shown instead of the cluster names given with
+	// WithKeyPrefix. Reports, published events and the like still use the
+	// full names.
+	ClusterTags map[string]ClusterTag
+
+	// Wide adds commonly useful fields like a pod's node or phase to the
+	// title, for kinds where such fields are known.
+	Wide bool
+
+	// Number prefixes every printed event with a consecutive number.
+	Number bool
+
+	// Anonymize replaces names, namespaces, UIDs and IP addresses in the
+	// output with stable pseudonyms.
+	Anonymize bool
+
+	// GroupKey is the name of a label by which events are grouped. Whenever
+	// the label's value differs from the previous event, a group header is
+	// printed.
+	GroupKey string
+
+	// Report, if set, records every printed event.
+	Report *report.Report
+
+	// Publisher, if set, sends every printed event to a message broker.
+	Publisher *publish.Publisher
+
+	// Exporter, if set, sends every printed event as an OpenTelemetry log
+	// record.
+	Exporter *otlp.Exporter
+
+	// OnPrint, if set, is called after every event that produced output.
+	OnPrint func()
+
+	// Progress, if set, is the indicator for loading the initial state. It
+	// is hidden while an event is written.
+	Progress *progress.Indicator
+
+	// OutputDir, if set, is a directory in which the output of every
+	// object's events is additionally appended to a file of its own.
+	OutputDir string
+
+	// Redraw clears the screen before the sorted view (see Options.SortBy)
+	// is printed again. Otherwise, every version of the view is printed
+	// below the previous one.
+	Redraw bool
+}
+
+// migrationWindow is how long deletions and creations are remembered to
+// link them to their counterparts when following migrations.
+const migrationWindow = 10 * time.Minute
+
 type Printer struct {
-	differ *Differ
-	log    logrus.FieldLogger
-	cache  *cache.ResourceCache
+	differ     *Differ
+	opt        *PrinterOptions
+	log        logrus.FieldLogger
+	correlator *correlation.Correlator
+	migrations *correlation.MigrationTracker
+
+	keyPrefix string
+
+	// printed contains the last printed state of each object when
+	// grouping updates by generation.
+	printed *cache.ResourceCache
+
+	// events contains the counts of all live Kubernetes Events, to render
+	// repeated Events compactly.
+	events *eventCounter
+
+	out     io.Writer
+	outLock *sync.Mutex
+	// eventNumber is shared with all clones and protected by outLock.
+	eventNumber *int
+	// group is shared with all clones and protected by outLock.
+	group *groupState
+	// view is shared with all clones and protected by outLock.
+	view *sortedView
+	// ticker is shared with all clones and protected by outLock.
+	ticker *tickerState
+	// anonymizer is shared with all clones, so that pseudonyms are
+	// consistent across clusters.
+	anonymizer *anonymizer
+	// syncOutput is true if the output is a regular file, in which case
+	// it is fsync'ed after every event.
+	syncOutput bool
+}
+
+// groupState is the label value of the last printed event when grouping
+// by a label.
+type groupState struct {
+	started bool
+	value   string
 }
 
-func NewPrinter(differ *Differ, log logrus.FieldLogger) *Printer {
-	return &Printer{
-		differ: differ,
-		log:    log,
-		cache:  cache.NewCache(),
+func NewPrinter(differ *Differ, out io.Writer, opt *PrinterOptions, log logrus.FieldLogger) *Printer {
+	p := &Printer{
+		differ:      differ,
+		opt:         opt,
+		log:         log,
+		printed:     cache.NewCache(),
+		events:      newEventCounter(),
+		out:         out,
+		outLock:     &sync.Mutex{},
+		eventNumber: new(int),
+		group:       &groupState{},
+		anonymizer:  newAnonymizer(),
+		syncOutput:  isRegularFile(out),
 	}
+
+	if opt.CorrelationWindow > 0 {
+		p.correlator = correlation.NewCorrelator(opt.CorrelationWindow)
+	}
+
+	if differ.opt.SortBy != "" {
+		p.view = newSortedView()
+	}
+
+	if differ.opt.Ticker {
+		p.ticker = newTickerState()
+	}
+
+	if opt.MigrationKey != "" {
+		p.migrations = correlation.NewMigrationTracker(opt.MigrationKey, migrationWindow)
+	}
+
+	return p
 }
 
-func (p *Printer) Print(obj *unstructured.Unstructured, event watch.EventType) {
-	switch event {
-	case watch.Added:
-		if err := p.differ.PrintDiff(nil, obj, time.Time{}); err != nil {
-			p.log.Errorf("Failed to show diff: %w", err)
+// WithKeyPrefix returns a printer that writes to the same output, but has
+// its own state and prefixes all object keys with the given prefix. This is
+// used to distinguish objects from multiple clusters.
+func (p *Printer) WithKeyPrefix(prefix string) *Printer {
+	clone := *p
+	clone.printed = cache.NewCache()
+	clone.events = newEventCounter()
+	clone.keyPrefix = prefix
+
+	return &clone
+}
+
+// KeyPrefix returns the prefix set by WithKeyPrefix.
+func (p *Printer) KeyPrefix() string {
+	return p.keyPrefix
+}
+
+// PrintEvents prints all events until the channel is closed.
+func (p *Printer) PrintEvents(events <-chan watcher.Event) {
+	for event := range events {
+		p.PrintEvent(event)
+	}
+}
+
+func (p *Printer) PrintEvent(event watcher.Event) {
+	// multiple watchers can print at the same time, so make sure that
+	// their output is not interleaved
+	p.outLock.Lock()
+	defer p.outLock.Unlock()
+
+	if p.ticker != nil {
+		p.updateTicker(event)
+		p.recordPrinted(event)
+		return
+	}
+
+	// render into a buffer first, so that events which produce no output
+	// can be recognized and every event is written at once
+	var buf bytes.Buffer
+	p.renderEvent(&buf, event)
+
+	if buf.Len() == 0 {
+		return
+	}
+
+	output := buf.Bytes()
+	if p.view != nil {
+		output = p.renderView(event, output)
+	}
+
+	if p.opt.GroupKey != "" {
+		output = append(p.groupHeader(event), output...)
+	}
+
+	if p.opt.Anonymize {
+		p.anonymizer.Learn(event.Old, p.keyPrefix)
+		p.anonymizer.Learn(event.New, p.keyPrefix)
+		output = []byte(p.anonymizer.Anonymize(string(output)))
+	}
+
+	if p.opt.Number {
+		*p.eventNumber++
+
+		// in YAML output, the number must not turn a document separator
+		// into a comment
+		prefix := fmt.Sprintf("#%d ", *p.eventNumber)
+		if bytes.HasPrefix(output, []byte("---")) {
+			prefix = fmt.Sprintf("#%d\n", *p.eventNumber)
+		}
+
+		output = append([]byte(prefix), output...)
+	}
+
+	if p.opt.OutputDir != "" {
+		p.appendObjectLog(event.Object(), output)
+	}
+
+	if p.opt.Progress != nil {
+		p.opt.Progress.Pause()
+	}
+
+	if _, err := p.out.Write(output); err != nil {
+		p.log.Errorf("Failed to write output: %v", err)
+	} else {
+		p.recordPrinted(event)
+	}
+
+	if p.opt.Progress != nil {
+		p.opt.Progress.Resume()
+	}
+
+	p.flush()
+
+	if p.opt.OnPrint != nil {
+		p.opt.OnPrint()
+	}
+}
+
+// recordPrinted counts an event in the report, publishes and exports it
+// once it has been written. Events that produced no output (e.g. empty
+// diffs or skipped updates) are neither recorded, published nor exported.
+func (p *Printer) recordPrinted(event watcher.Event) {
+	if p.opt.Report != nil {
+		p.opt.Report.Record(event, p.keyPrefix)
+	}
+
+	if p.opt.Publisher != nil {
+		p.opt.Publisher.Publish(event, p.keyPrefix)
+	}
+
+	if p.opt.Exporter != nil {
+		p.opt.Exporter.Export(event, p.keyPrefix)
+	}
+}
+
+// updateTicker records the event's value and redraws the ticker line.
+func (p *Printer) updateTicker(event watcher.Event) {
+	obj := event.Object()
+	key := obj.GroupVersionKind().Kind + " " + TitleInfo{KeyPrefix: p.keyPrefix}.key(obj)
+
+	title := obj.GroupVersionKind().Kind + " " + p.keyInfo().key(obj)
+	if p.opt.Anonymize {
+		p.anonymizer.Learn(obj, p.keyPrefix)
+		title = p.anonymizer.Anonymize(title)
+	}
+
+	p.ticker.update(key, title, p.differ.tickerValue(event), p.differ.now())
+	p.redrawTicker()
+
+	if p.opt.OnPrint != nil {
+		p.opt.OnPrint()
+	}
+}
+
+// RefreshTicker redraws the ticker line (see Options.Ticker), so that the
+// time since the last change stays current. It does nothing in other modes.
+func (p *Printer) RefreshTicker() {
+	if p.ticker == nil {
+		return
+	}
+
+	p.outLock.Lock()
+	defer p.outLock.Unlock()
+
+	p.redrawTicker()
+}
+
+// EndTicker finishes the ticker line, so that later output does not
+// overwrite it. Afterwards, the line is not redrawn anymore.
+func (p *Printer) EndTicker() {
+	if p.ticker == nil {
+		return
+	}
+
+	p.outLock.Lock()
+	defer p.outLock.Unlock()
+
+	if err := p.ticker.end(p.out); err != nil {
+		p.log.Errorf("Failed to write output: %v", err)
+	}
+}
+
+func (p *Printer) redrawTicker() {
+	if err := p.ticker.render(p.out, jsonPathLabel(p.differ.opt.JSONPath), p.differ.now()); err != nil {
+		p.log.Errorf("Failed to write output: %v", err)
+	}
+
+	p.flush()
+}
+
+// renderView updates the object's line in the sorted view and returns the
+// whole view.
+func (p *Printer) renderView(event watcher.Event, line []byte) []byte {
+	obj := event.Object()
+	key := obj.GroupVersionKind().Kind + " " + TitleInfo{KeyPrefix: p.keyPrefix}.key(obj)
+
+	if event.Type == watch.Deleted {
+		p.view.remove(key)
+	} else {
+		p.view.set(key, strings.TrimSuffix(string(line), "\n"), p.differ.sortValue(obj))
+	}
+
+	var buf bytes.Buffer
+	if p.opt.Redraw {
+		buf.WriteString(clearScreen)
+	}
+
+	for _, row := range p.view.sorted() {
+		buf.WriteString(row.line)
+		buf.WriteString("\n")
+	}
+
+	if !p.opt.Redraw {
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+func (p *Printer) renderEvent(out io.Writer, event watcher.Event) {
+	oldObj, lastSeen := event.Old, event.LastSeen
+
+	if p.opt.GroupByGeneration {
+		switch event.Type {
+		case watch.Added:
+			p.printed.Set(event.New)
+
+		case watch.Deleted:
+			p.printed.Delete(event.Old)
+
+		case watch.Modified:
+			// compare against the last printed state, so the diff covers all
+			// changes since then
+			if printed, printedAt := p.printed.Get(event.New); printed != nil {
+				oldObj, lastSeen = printed, printedAt
+			}
+
+			if oldObj != nil && event.New.GetGeneration() > 0 && oldObj.GetGeneration() == event.New.GetGeneration() {
+				return
+			}
+
+			p.printed.Set(event.New)
+		}
+	}
+
+	info := p.keyInfo()
+	info.Annotations = p.annotations(event)
+
+	// hidden events must not make other events look related
+	if p.correlator != nil {
+		info.deferred = p.correlationAnnotation(event.Object())
+	}
+
+	if event.Type == watch.Deleted && event.Old != nil && isKubernetesEvent(event.Old) {
+		p.events.forget(event.Old)
+	}
+
+	// Kubernetes Events are mostly repeats with increasing counts, so only
+	// the first occurrence is shown in full
+	if event.New != nil && isKubernetesEvent(event.New) && !p.yamlOutput() && !p.jsonOutput() {
+		previousCount, count, seen := p.events.observe(event.New)
+
+		if seen {
+			if err := p.differ.PrintEventRepeat(out, event.New, previousCount, count, info); err != nil {
+				p.log.Errorf("Failed to show event: %v", err)
+			}
+
+			return
 		}
-		p.cache.Set(obj)
+	}
+
+	if err := p.render(out, event.Type, oldObj, event.New, lastSeen, info); err != nil {
+		p.log.Errorf("Failed to show diff: %v", err)
+	}
+
+	if p.opt.ShowConversionWarnings && event.Type == watch.Modified && oldObj != nil {
+		p.printConversionWarnings(out, oldObj, event.New)
+	}
+
+	if p.opt.ShowOwnerChanges && event.Type == watch.Modified && oldObj != nil {
+		p.printOwnerChanges(out, oldObj, event.New)
+	}
 
-	case watch.Modified:
-		previous, lastSeen := p.cache.Get(obj)
-		if err := p.differ.PrintDiff(previous, obj, lastSeen); err != nil {
-			p.log.Errorf("Failed to show diff: %w", err)
+	if p.opt.ShowQuantityChanges && event.Type == watch.Modified && oldObj != nil {
+		p.printQuantityChanges(out, oldObj, event.New)
+	}
+
+	if p.migrations != nil {
+		p.printMigration(out, event)
+	}
+}
+
+func (p *Printer) render(out io.Writer, event watch.EventType, oldObj, newObj *unstructured.Unstructured, lastSeen time.Time, info TitleInfo) error {
+	obj := newObj
+	if obj == nil {
+		obj = oldObj
+	}
+
+	if p.differ.opt.Quiet {
+		// updates that would not produce a diff are not shown as lines either
+		if event == watch.Modified && oldObj != nil {
+			_, _, hidden, err := p.differ.preprocessChange(oldObj, newObj, event)
+			if err != nil || hidden {
+				return err
+			}
+		}
+
+		return p.differ.PrintEventLine(out, event, obj, info)
+	}
+
+	if p.differ.opt.Project != "" {
+		return p.differ.PrintProjectionLine(out, event, obj, info)
+	}
+
+	// the deletion of an object that was terminating concludes its
+	// finalization, regular deletions are still shown as diffs
+	if p.differ.opt.Finalizers && (isTerminating(newObj) || (event == watch.Deleted && isTerminating(oldObj))) {
+		return p.differ.PrintFinalizersLine(out, event, oldObj, newObj, info)
+	}
+
+	// other kinds are still shown as diffs in rollout mode
+	if p.differ.opt.Rollout && hasRolloutFields(obj) {
+		return p.differ.PrintRolloutLine(out, event, oldObj, newObj, info)
+	}
+
+	return p.differ.PrintDiff(out, oldObj, newObj, lastSeen, info)
+}
+
+func (p *Printer) printConversionWarnings(out io.Writer, oldObj, newObj *unstructured.Unstructured) {
+	unowned := managedfields.UnownedChanges(oldObj, newObj)
+	if len(unowned) == 0 {
+		return
+	}
+
+	paths := []string{}
+	for _, path := range unowned {
+		paths = append(paths, path.String())
+	}
+
+	warning := fmt.Sprintf("! changed fields not owned by any field manager (likely set by API server defaulting or a conversion webhook): %s", strings.Join(paths, ", "))
+
+	p.printNotes(out, []string{warning}, color.New(color.Yellow))
+}
+
+func (p *Printer) printOwnerChanges(out io.Writer, oldObj, newObj *unstructured.Unstructured) {
+	changes := ownerChanges(oldObj, newObj)
+	if len(changes) == 0 {
+		return
+	}
+
+	notes := []string{}
+	for _, change := range changes {
+		notes = append(notes, "! "+change)
+	}
+
+	p.printNotes(out, notes, color.New(color.Magenta))
+}
+
+func (p *Printer) printQuantityChanges(out io.Writer, oldObj, newObj *unstructured.Unstructured) {
+	changes := quantityChanges(oldObj, newObj)
+	if len(changes) == 0 {
+		return
+	}
+
+	notes := []string{}
+	for _, change := range changes {
+		notes = append(notes, "! "+change)
+	}
+
+	p.printNotes(out, notes, color.New(color.Cyan))
+}
+
+func (p *Printer) printMigration(out io.Writer, event watcher.Event) {
+	var note string
+
+	switch event.Type {
+	case watch.Added:
+		if from, ok := p.migrations.Created(event.New); ok {
+			note = "! migrated from " + from
 		}
-		p.cache.Set(obj)
 
 	case watch.Deleted:
-		if err := p.differ.PrintDiff(obj, nil, time.Now()); err != nil {
-			p.log.Errorf("Failed to show diff: %w", err)
+		if to, ok := p.migrations.Deleted(event.Old); ok {
+			note = "! migrated to " + to
 		}
-		p.cache.Delete(obj)
 	}
+
+	if note != "" {
+		p.printNotes(out, []string{note}, color.New(color.Magenta))
+	}
+}
+
+// printNotes prints additional information below a diff, or as comments
+// in YAML output. JSON output has no room for notes.
+func (p *Printer) printNotes(out io.Writer, notes []string, style color.Style) {
+	if p.jsonOutput() {
+		return
+	}
+
+	if p.yamlOutput() {
+		fmt.Fprint(out, yamlComment(strings.Join(notes, "\n")))
+		return
+	}
+
+	for _, note := range notes {
+		fmt.Fprintln(out, style.Sprint(note))
+	}
+
+	fmt.Fprintln(out)
+}
+
+// keyInfo returns the title info to render object keys with, using the
+// cluster's tag instead of its name, if one is configured.
+func (p *Printer) keyInfo() TitleInfo {
+	info := TitleInfo{KeyPrefix: p.keyPrefix}
+
+	if tag, ok := p.opt.ClusterTags[p.keyPrefix]; ok {
+		info.KeyPrefix = tag.Tag
+		info.KeyPrefixStyle = tag.Style
+	}
+
+	return info
+}
+
+func (p *Printer) annotations(event watcher.Event) []string {
+	obj := event.Object()
+	annotations := []string{}
+
+	if event.Type == watch.Modified && event.Changes > 0 {
+		annotations = append(annotations, fmt.Sprintf("(change #%d)", event.Changes))
+	}
+
+	if event.Type == watch.Modified && event.Old != nil {
+		revision := revisionChange(event.Old, event.New, BuiltinRevisionAnnotations)
+		if revision == "" {
+			revision = revisionChange(event.Old, event.New, p.opt.RevisionAnnotations)
+		}
+
+		if revision != "" {
+			annotations = append(annotations, revision)
+		}
+	}
+
+	if event.Alert != "" {
+		annotations = append(annotations, fmt.Sprintf("(%s)", event.Alert))
+	}
+
+	// the missed changes are shown as one big diff after a reconnect
+	if p.opt.ShowReconnects && event.Reconnect > 0 {
+		annotations = append(annotations, fmt.Sprintf("(after reconnect #%d)", event.Reconnect))
+	}
+
+	if p.opt.Wide {
+		if wide := wideAnnotation(obj); wide != "" {
+			annotations = append(annotations, wide)
+		}
+	}
+
+	return annotations
+}
+
+// correlationAnnotation returns a function that correlates the object when
+// it is first called and returns the annotation for its correlation ID, if
+// any.
+func (p *Printer) correlationAnnotation(obj *unstructured.Unstructured) func() []string {
+	var (
+		once        sync.Once
+		annotations []string
+	)
+
+	return func() []string {
+		once.Do(func() {
+			if id := p.correlator.Correlate(obj); id != "" {
+				annotations = []string{fmt.Sprintf("(corr. %s)", id)}
+			}
+		})
+
+		return annotations
+	}
+}
+
+// groupHeader returns a header line if the event belongs to a different
+// group than the previously printed one, otherwise nil.
+func (p *Printer) groupHeader(event watcher.Event) []byte {
+	group, ok := event.Object().GetLabels()[p.opt.GroupKey]
+	if !ok {
+		group = "<none>"
+	}
+
+	if p.group.started && p.group.value == group {
+		return nil
+	}
+
+	p.group.started = true
+	p.group.value = group
+
+	// every line of JSON output must be an event
+	if p.jsonOutput() {
+		return nil
+	}
+
+	header := fmt.Sprintf("=== %s=%s ===", p.opt.GroupKey, group)
+	if p.yamlOutput() {
+		return []byte(yamlComment(header))
+	}
+
+	return []byte(color.New(color.Bold).Sprint(header) + "\n\n")
+}
+
+// yamlOutput returns true if objects are printed as YAML documents, in
+// which case everything else must be printed as YAML comments.
+func (p *Printer) yamlOutput() bool {
+	return p.differ.opt.Output == OutputYAML
+}
+
+// jsonOutput returns true if events are printed as lines of JSON, in which
+// case nothing else must be printed.
+func (p *Printer) jsonOutput() bool {
+	return p.differ.opt.Output == OutputJSON
+}
+
+// flush ensures that the last event is not stuck in any buffer, so that
+// tools like tee or less receive it immediately.
+func (p *Printer) flush() {
+	if flusher, ok := p.out.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			p.log.Warnf("Failed to flush output: %v", err)
+		}
+	}
+
+	if p.syncOutput {
+		if syncer, ok := p.out.(interface{ Sync() error }); ok {
+			if err := syncer.Sync(); err != nil {
+				p.log.Warnf("Failed to sync output: %v", err)
+			}
+		}
+	}
+}
+
+func isRegularFile(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode().IsRegular()
 }
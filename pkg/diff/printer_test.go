@@ -0,0 +1,186 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/report"
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/gookit/color"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestReportOnlyPrintedEvents(t *testing.T) {
+	differ, err := NewDiffer(&Options{HideEmptyDiffs: true}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	r := report.New()
+
+	var buf bytes.Buffer
+	printer := NewPrinter(differ, &buf, &PrinterOptions{Report: r}, logrus.New())
+
+	oldObj := parseObject(t, oldDeployment)
+	bumped := parseObject(t, strings.Replace(oldDeployment, `resourceVersion: "100"`, `resourceVersion: "101"`, 1))
+
+	gvk := oldObj.GroupVersionKind()
+
+	printer.PrintEvent(watcher.Event{Type: watch.Added, GVK: gvk, New: oldObj})
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, GVK: gvk, Old: oldObj, New: bumped})
+
+	var summary bytes.Buffer
+	if err := r.WriteSummary(&summary); err != nil {
+		t.Fatalf("failed to write summary: %v", err)
+	}
+
+	expected := "Deployment: 1 created, 0 updated, 0 deleted"
+	if !strings.Contains(summary.String(), expected) {
+		t.Errorf("Expected %q, but got %q.", expected, summary.String())
+	}
+}
+
+func TestQuietHidesResourceVersionBumps(t *testing.T) {
+	differ, err := NewDiffer(&Options{Quiet: true, HideEmptyDiffs: true}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	var buf bytes.Buffer
+	printer := NewPrinter(differ, &buf, &PrinterOptions{}, logrus.New())
+
+	oldObj := parseObject(t, oldDeployment)
+	bumped := parseObject(t, strings.Replace(oldDeployment, `resourceVersion: "100"`, `resourceVersion: "101"`, 1))
+
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: oldObj, New: bumped})
+
+	if buf.Len() > 0 {
+		t.Errorf("Expected no output, but got %q.", buf.String())
+	}
+
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: oldObj, New: parseObject(t, newDeployment)})
+
+	if !strings.Contains(buf.String(), "MODIFIED Deployment default/nginx") {
+		t.Errorf("Expected a line for the update, but got %q.", buf.String())
+	}
+}
+
+func TestForgetDeletedKubernetesEvents(t *testing.T) {
+	color.Disable()
+
+	differ, err := NewDiffer(&Options{
+		HideEmptyDiffs:   true,
+		CreateColorTheme: CreateColorTheme,
+		UpdateColorTheme: UpdateColorTheme,
+		DeleteColorTheme: DeleteColorTheme,
+	}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	var buf bytes.Buffer
+	printer := NewPrinter(differ, &buf, &PrinterOptions{}, logrus.New())
+
+	newEvent := func(name string, count int) *unstructured.Unstructured {
+		return parseObject(t, fmt.Sprintf(`
+apiVersion: v1
+kind: Event
+metadata:
+  name: %s
+  namespace: default
+  uid: %s
+reason: BackOff
+count: %d
+involvedObject:
+  kind: Pod
+  name: nginx
+  namespace: default
+`, name, name, count))
+	}
+
+	// both Events have the same reason and involved object
+	first := newEvent("nginx.17a", 5)
+	second := newEvent("nginx.17b", 1)
+
+	printer.PrintEvent(watcher.Event{Type: watch.Added, New: first})
+	printer.PrintEvent(watcher.Event{Type: watch.Added, New: second})
+
+	if !strings.Contains(buf.String(), "(x5 → x6)") {
+		t.Errorf("Expected the counts of both Events to be added up, but got %q.", buf.String())
+	}
+
+	// the other Event is still live and must still be deduplicated
+	printer.PrintEvent(watcher.Event{Type: watch.Deleted, Old: first})
+	buf.Reset()
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: second, New: newEvent("nginx.17b", 2)})
+
+	if !strings.Contains(buf.String(), "(x1 → x2)") {
+		t.Errorf("Expected a repeat of the remaining Event, but got %q.", buf.String())
+	}
+
+	printer.PrintEvent(watcher.Event{Type: watch.Deleted, Old: second})
+
+	if len(printer.events.counts) != 0 || len(printer.events.byKey) != 0 {
+		t.Errorf("Expected the deleted Events to be forgotten, but got %v and %v.", printer.events.counts, printer.events.byKey)
+	}
+}
+
+func TestCorrelateOnlyPrintedEvents(t *testing.T) {
+	differ, err := NewDiffer(&Options{Quiet: true, HideEmptyDiffs: true}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	var buf bytes.Buffer
+	printer := NewPrinter(differ, &buf, &PrinterOptions{CorrelationWindow: time.Minute}, logrus.New())
+
+	deployment := parseObject(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  uid: deployment
+  resourceVersion: "1"
+`)
+	bumped := deployment.DeepCopy()
+	bumped.SetResourceVersion("2")
+
+	replicaSet := parseObject(t, `
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: nginx-1
+  namespace: default
+  uid: replicaset
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: nginx
+    uid: deployment
+`)
+
+	// the hidden update must not make the ReplicaSet look related
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: deployment, New: bumped})
+	printer.PrintEvent(watcher.Event{Type: watch.Added, New: replicaSet})
+
+	if strings.Contains(buf.String(), "corr.") {
+		t.Errorf("Expected no correlation, but got %q.", buf.String())
+	}
+
+	sibling := replicaSet.DeepCopy()
+	sibling.SetName("nginx-2")
+	sibling.SetUID("sibling")
+
+	printer.PrintEvent(watcher.Event{Type: watch.Added, New: sibling})
+
+	if !strings.Contains(buf.String(), "(corr. #1)") {
+		t.Errorf("Expected printed siblings to be correlated, but got %q.", buf.String())
+	}
+}
@@ -0,0 +1,93 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// missingProjection is shown for projected fields that do not exist.
+const missingProjection = "<none>"
+
+type projectedField struct {
+	name string
+	path *jsonpath.JSONPath
+}
+
+// parseProjection parses "name=metadata.name,replicas=spec.replicas" into
+// its fields. Paths can be given as JSON path expressions like
+// "{.spec.replicas}" or in the short dotted form.
+func parseProjection(projection string) ([]projectedField, error) {
+	fields := []projectedField{}
+
+	for _, field := range strings.Split(projection, ",") {
+		name, expr, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found || name == "" || expr == "" {
+			return nil, fmt.Errorf("%q must be in the form NAME=PATH", field)
+		}
+
+		if !strings.HasPrefix(expr, "{") {
+			expr = "{." + strings.TrimPrefix(expr, ".") + "}"
+		}
+
+		path := jsonpath.New(name)
+		if err := path.Parse(expr); err != nil {
+			return nil, fmt.Errorf("invalid JSON path for %q: %w", name, err)
+		}
+
+		path.AllowMissingKeys(true)
+
+		fields = append(fields, projectedField{
+			name: name,
+			path: path,
+		})
+	}
+
+	return fields, nil
+}
+
+// PrintProjectionLine renders a single line like "15:04:05 MODIFIED
+// name=nginx replicas=3" with the projected fields of the object. This is
+// used instead of PrintDiff when a projection is configured.
+func (d *Differ) PrintProjectionLine(out io.Writer, eventType watch.EventType, obj *unstructured.Unstructured, info TitleInfo) error {
+	parts := []string{
+		d.eventTime(obj, d.now()).Format("15:04:05"),
+		string(eventType),
+	}
+
+	if info.KeyPrefix != "" {
+		parts = append(parts, fmt.Sprintf("cluster=%s", info.KeyPrefix))
+	}
+
+	for _, field := range d.opt.parsedProjection {
+		value, err := jsonPathValue(field.path, obj)
+		if err != nil {
+			d.log.Warnf("Failed to apply JSON path for %q: %v", field.name, err)
+		}
+
+		parts = append(parts, fmt.Sprintf("%s=%s", field.name, projectionValue(value)))
+	}
+
+	_, err := fmt.Fprintln(out, strings.Join(parts, " "))
+
+	return err
+}
+
+// projectionValue quotes values that would otherwise break up the line into
+// more fields than there are.
+func projectionValue(value string) string {
+	if value == "" {
+		return missingProjection
+	}
+
+	if strings.ContainsAny(value, " \t\n\"") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
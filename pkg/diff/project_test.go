@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestPrintProjectionLine(t *testing.T) {
+	testcases := []struct {
+		name       string
+		projection string
+		expected   string
+	}{
+		{
+			name:       "dotted paths",
+			projection: "name=metadata.name,replicas=spec.replicas",
+			expected:   "12:00:00 MODIFIED name=nginx replicas=3\n",
+		},
+		{
+			name:       "JSON path expressions",
+			projection: "image={.spec.template.spec.containers[0].image}",
+			expected:   "12:00:00 MODIFIED image=nginx:1.23\n",
+		},
+		{
+			name:       "missing fields",
+			projection: "name=metadata.name,paused=spec.paused",
+			expected:   "12:00:00 MODIFIED name=nginx paused=<none>\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			differ, err := NewDiffer(&Options{Project: testcase.projection}, logrus.New())
+			if err != nil {
+				t.Fatalf("Failed to create differ: %v", err)
+			}
+
+			differ.now = func() time.Time {
+				return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+			}
+
+			var buf bytes.Buffer
+			if err := differ.PrintProjectionLine(&buf, watch.Modified, parseObject(t, newDeployment), TitleInfo{}); err != nil {
+				t.Fatalf("Failed to print line: %v", err)
+			}
+
+			if actual := buf.String(); actual != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseProjection(t *testing.T) {
+	for _, projection := range []string{"name", "=metadata.name", "name=", "name={.metadata.name"} {
+		if _, err := parseProjection(projection); err == nil {
+			t.Errorf("Expected %q to be invalid.", projection)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.xrstf.de/stalk/pkg/maputil"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type quantityField struct {
+	path  string
+	value string
+}
+
+// quantityChanges describes how the resource requests and limits differ
+// between both objects, e.g. "spec.containers[0].resources.requests.cpu:
+// 500m → 1 (↑2x)". Quantities are shown in their canonical form, values
+// that cannot be parsed are compared as plain strings.
+func quantityChanges(oldObj, newObj *unstructured.Unstructured) []string {
+	oldFields := quantityFields(oldObj.Object, nil, nil)
+	newFields := quantityFields(newObj.Object, nil, nil)
+
+	oldValues := map[string]string{}
+	for _, field := range oldFields {
+		oldValues[field.path] = field.value
+	}
+
+	newValues := map[string]string{}
+	for _, field := range newFields {
+		newValues[field.path] = field.value
+	}
+
+	changes := []string{}
+
+	for _, field := range newFields {
+		oldValue, existed := oldValues[field.path]
+		if !existed {
+			changes = append(changes, fmt.Sprintf("%s: <none> → %s", field.path, canonicalQuantity(field.value)))
+			continue
+		}
+
+		if oldValue != field.value {
+			changes = append(changes, fmt.Sprintf("%s: %s", field.path, describeQuantityChange(oldValue, field.value)))
+		}
+	}
+
+	for _, field := range oldFields {
+		if _, exists := newValues[field.path]; !exists {
+			changes = append(changes, fmt.Sprintf("%s: %s → <none>", field.path, canonicalQuantity(field.value)))
+		}
+	}
+
+	return changes
+}
+
+// quantityFields returns all values in "requests" and "limits" below a
+// "resources" field, ordered by their path.
+func quantityFields(value interface{}, path maputil.Path, fields []quantityField) []quantityField {
+	switch asserted := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(asserted))
+		for key := range asserted {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fieldPath := path.Append(key)
+
+			if _, isMap := asserted[key].(map[string]interface{}); !isMap && isQuantityMap(path) {
+				fields = append(fields, quantityField{
+					path:  fieldPath.String(),
+					value: fmt.Sprint(asserted[key]),
+				})
+
+				continue
+			}
+
+			fields = quantityFields(asserted[key], fieldPath, fields)
+		}
+
+	case []interface{}:
+		for i, item := range asserted {
+			fields = quantityFields(item, path.Append(fmt.Sprintf("[%d]", i)), fields)
+		}
+	}
+
+	return fields
+}
+
+func isQuantityMap(path maputil.Path) bool {
+	if len(path) < 2 || path[len(path)-2] != "resources" {
+		return false
+	}
+
+	last := path[len(path)-1]
+
+	return last == "requests" || last == "limits"
+}
+
+func canonicalQuantity(value string) string {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return value
+	}
+
+	return quantity.String()
+}
+
+func describeQuantityChange(oldValue, newValue string) string {
+	oldQuantity, oldErr := resource.ParseQuantity(oldValue)
+	newQuantity, newErr := resource.ParseQuantity(newValue)
+	if oldErr != nil || newErr != nil {
+		return fmt.Sprintf("%s → %s", oldValue, newValue)
+	}
+
+	change := fmt.Sprintf("%s → %s", oldQuantity.String(), newQuantity.String())
+
+	switch newQuantity.Cmp(oldQuantity) {
+	case 0:
+		return change + " (same value)"
+
+	case 1:
+		if oldQuantity.IsZero() {
+			return change + " (↑)"
+		}
+
+		return fmt.Sprintf("%s (↑%sx)", change, quantityRatio(oldQuantity, newQuantity))
+
+	default:
+		if oldQuantity.IsZero() {
+			return change + " (↓)"
+		}
+
+		return fmt.Sprintf("%s (↓%sx)", change, quantityRatio(oldQuantity, newQuantity))
+	}
+}
+
+func quantityRatio(oldQuantity, newQuantity resource.Quantity) string {
+	ratio := newQuantity.AsApproximateFloat64() / oldQuantity.AsApproximateFloat64()
+
+	return strconv.FormatFloat(ratio, 'g', 3, 64)
+}
@@ -0,0 +1,143 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuantityChanges(t *testing.T) {
+	testcases := []struct {
+		name     string
+		old      string
+		new      string
+		expected []string
+	}{
+		{
+			name: "scaled requests and limits",
+			old: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  containers:
+    - name: app
+      resources:
+        requests:
+          cpu: 500m
+          memory: 1024Mi
+        limits:
+          cpu: 2
+`,
+			new: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  containers:
+    - name: app
+      resources:
+        requests:
+          cpu: "1"
+          memory: 1Gi
+        limits:
+          cpu: 500m
+`,
+			expected: []string{
+				"spec.containers[0].resources.limits.cpu: 2 → 500m (↓0.25x)",
+				"spec.containers[0].resources.requests.cpu: 500m → 1 (↑2x)",
+				"spec.containers[0].resources.requests.memory: 1Gi → 1Gi (same value)",
+			},
+		},
+		{
+			name: "added and removed quantities",
+			old: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  containers:
+    - name: app
+      resources:
+        limits:
+          memory: 2Gi
+`,
+			new: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  containers:
+    - name: app
+      resources:
+        requests:
+          memory: 1G
+`,
+			expected: []string{
+				"spec.containers[0].resources.requests.memory: <none> → 1G",
+				"spec.containers[0].resources.limits.memory: 2Gi → <none>",
+			},
+		},
+		{
+			name: "unparsable values are compared as strings",
+			old: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  containers:
+    - name: app
+      resources:
+        requests:
+          cpu: lots
+`,
+			new: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  containers:
+    - name: app
+      resources:
+        requests:
+          cpu: 100m
+`,
+			expected: []string{"spec.containers[0].resources.requests.cpu: lots → 100m"},
+		},
+		{
+			name: "other fields are ignored",
+			old: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  requests: "1"
+`,
+			new: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  requests: "2"
+`,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := quantityChanges(parseObject(t, tc.old), parseObject(t, tc.new))
+
+			if !reflect.DeepEqual(changes, tc.expected) {
+				t.Errorf("Expected %v, but got %v.", tc.expected, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// PrintEventLine renders a single line like "15:04:05 MODIFIED Deployment
+// default/nginx" for the event, followed by the value of the QuietField (if
+// configured). This is used instead of PrintDiff in quiet mode.
+func (d *Differ) PrintEventLine(out io.Writer, eventType watch.EventType, obj *unstructured.Unstructured, info TitleInfo) error {
+	parts := []string{
+		d.eventTime(obj, d.now()).Format("15:04:05"),
+		string(eventType),
+		obj.GroupVersionKind().Kind,
+		info.key(obj),
+	}
+
+	if d.opt.compiledQuietField != nil {
+		value, err := jsonPathValue(d.opt.compiledQuietField, obj)
+		if err != nil {
+			d.log.Warnf("Failed to apply quiet field JSON path: %v", err)
+		}
+
+		parts = append(parts, fmt.Sprintf("%s=%s", jsonPathLabel(d.opt.QuietField), value))
+	}
+
+	parts = append(parts, info.annotations()...)
+
+	_, err := fmt.Fprintln(out, strings.Join(parts, " "))
+
+	return err
+}
+
+// jsonPathValue evaluates the path against the object and returns all
+// matches, separated by commas. Missing values result in an empty string.
+func jsonPathValue(path *jsonpath.JSONPath, obj *unstructured.Unstructured) (string, error) {
+	results, err := jsonPathResults(path, obj.Object)
+	if err != nil {
+		return "", err
+	}
+
+	values := []string{}
+	for _, result := range results {
+		values = append(values, fmt.Sprintf("%v", result))
+	}
+
+	return strings.Join(values, ","), nil
+}
+
+// jsonPathLabel turns "{.status.readyReplicas}" into "readyReplicas".
+func jsonPathLabel(expr string) string {
+	expr = strings.Trim(expr, "{}")
+
+	if idx := strings.LastIndex(expr, "."); idx >= 0 {
+		expr = expr[idx+1:]
+	}
+
+	if idx := strings.Index(expr, "["); idx >= 0 {
+		expr = expr[:idx]
+	}
+
+	if expr == "" {
+		return "value"
+	}
+
+	return expr
+}
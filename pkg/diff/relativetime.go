@@ -0,0 +1,56 @@
+package diff
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// justNow is the threshold below which timestamps are shown as "just now".
+const justNow = 5 * time.Second
+
+// relativeTimes replaces all RFC3339 timestamps in the value with their
+// distance to now, e.g. "2m ago". Both objects of a diff are rendered at the
+// same time, so unchanged timestamps still produce no diff.
+func relativeTimes(value interface{}, now time.Time) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = relativeTimes(item, now)
+		}
+
+		return v
+
+	case []interface{}:
+		for i, item := range v {
+			v[i] = relativeTimes(item, now)
+		}
+
+		return v
+
+	case string:
+		timestamp, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return v
+		}
+
+		return relativeTime(timestamp, now)
+
+	default:
+		return v
+	}
+}
+
+func relativeTime(timestamp time.Time, now time.Time) string {
+	distance := now.Sub(timestamp)
+
+	switch {
+	case distance > -justNow && distance < justNow:
+		return "just now"
+	case distance < 0:
+		return fmt.Sprintf("in %s", duration.HumanDuration(-distance))
+	default:
+		return fmt.Sprintf("%s ago", duration.HumanDuration(distance))
+	}
+}
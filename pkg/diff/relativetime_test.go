@@ -0,0 +1,68 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRelativeTimes(t *testing.T) {
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{
+			name:     "past",
+			value:    "2022-09-01T11:58:00Z",
+			expected: "2m ago",
+		},
+		{
+			name:     "just now",
+			value:    "2022-09-01T11:59:58Z",
+			expected: "just now",
+		},
+		{
+			name:     "future",
+			value:    "2022-09-01T15:00:00Z",
+			expected: "in 3h",
+		},
+		{
+			name:     "other strings are kept",
+			value:    "2022-09-01",
+			expected: "2022-09-01",
+		},
+		{
+			name: "nested values",
+			value: map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"lastTransitionTime": "2022-09-01T10:00:00Z",
+						"status":             "True",
+					},
+				},
+				"replicas": int64(3),
+			},
+			expected: map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"lastTransitionTime": "120m ago",
+						"status":             "True",
+					},
+				},
+				"replicas": int64(3),
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			actual := relativeTimes(testcase.value, now)
+			if !reflect.DeepEqual(actual, testcase.expected) {
+				t.Errorf("Expected %v, but got %v.", testcase.expected, actual)
+			}
+		})
+	}
+}
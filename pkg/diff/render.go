@@ -0,0 +1,286 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/shibukawa/cdiff"
+)
+
+type hunk struct {
+	start int
+	end   int // inclusive
+}
+
+// renderUnified renders the lines of a diff result in unified format,
+// similar to cdiff's UnifiedWithGooKitColor, but without any header, so
+// that the caller is in full control of the title. Moved lines (if any)
+// are marked with "~" instead of "+". If lineNumbers is set, every line is
+// prefixed with its line number in the new object.
+func renderUnified(result cdiff.Result, contextLines int, theme map[cdiff.Tag]color.Style, moved *moves, lineNumbers bool) string {
+	var builder strings.Builder
+
+	width := lineNumberWidth(result.Lines)
+
+	for _, h := range groupHunks(result.Lines, contextLines) {
+		builder.WriteString(theme[cdiff.OpenSection].Sprint(hunkHeader(result.Lines, h)))
+		builder.WriteString("\n")
+
+		for i := h.start; i <= h.end; i++ {
+			line := result.Lines[i]
+
+			if lineNumbers {
+				builder.WriteString(lineNumber(line.NewLineNumber, line.Ope != cdiff.Delete, width))
+			}
+
+			if moved.isMoved(i) {
+				text := "~" + line.String()
+				if moved.starts[i] {
+					text += "  (moved)"
+				}
+
+				builder.WriteString(theme[cdiff.OpenHeader].Sprint(text))
+			} else {
+				builder.WriteString(renderLine(line, theme))
+			}
+
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+func renderLine(line cdiff.Line, theme map[cdiff.Tag]color.Style) string {
+	var (
+		builder       strings.Builder
+		prefix        string
+		modified      cdiff.Tag
+		notModified   cdiff.Tag
+		hasLineColors = true
+	)
+
+	switch line.Ope {
+	case cdiff.Insert:
+		prefix, modified, notModified = "+", cdiff.OpenInsertedModified, cdiff.OpenInsertedNotModified
+	case cdiff.Delete:
+		prefix, modified, notModified = "-", cdiff.OpenDeletedModified, cdiff.OpenDeletedNotModified
+	default:
+		prefix, hasLineColors = " ", false
+	}
+
+	if !hasLineColors {
+		builder.WriteString(prefix)
+		builder.WriteString(line.String())
+		return builder.String()
+	}
+
+	builder.WriteString(theme[notModified].Sprint(prefix))
+
+	for _, f := range line.Fragments {
+		if f.Changed {
+			builder.WriteString(theme[modified].Sprint(f.Text))
+		} else {
+			builder.WriteString(theme[notModified].Sprint(f.Text))
+		}
+	}
+
+	return builder.String()
+}
+
+// groupHunks returns the ranges of lines that contain changes, each
+// surrounded by up to contextLines unchanged lines. Overlapping ranges
+// are merged.
+func groupHunks(lines []cdiff.Line, contextLines int) []hunk {
+	hunks := []hunk{}
+
+	for i, line := range lines {
+		if line.Ope == cdiff.Keep {
+			continue
+		}
+
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+
+		end := i + contextLines
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+
+		if len(hunks) > 0 && hunks[len(hunks)-1].end >= start-1 {
+			hunks[len(hunks)-1].end = end
+		} else {
+			hunks = append(hunks, hunk{start: start, end: end})
+		}
+	}
+
+	return hunks
+}
+
+// hunkHeader returns the "@@ -a,b +c,d @@" marker for the given hunk.
+func hunkHeader(lines []cdiff.Line, h hunk) string {
+	oldStart, oldCount := 0, 0
+	newStart, newCount := 0, 0
+
+	for _, line := range lines[h.start : h.end+1] {
+		if line.Ope != cdiff.Insert {
+			if oldCount == 0 {
+				oldStart = line.OldLineNumber
+			}
+			oldCount++
+		}
+
+		if line.Ope != cdiff.Delete {
+			if newCount == 0 {
+				newStart = line.NewLineNumber
+			}
+			newCount++
+		}
+	}
+
+	// by convention, empty ranges point to the line before the hunk
+	if oldCount == 0 {
+		oldStart = precedingLineNumber(lines, h.start, func(l cdiff.Line) int { return l.OldLineNumber })
+	}
+
+	if newCount == 0 {
+		newStart = precedingLineNumber(lines, h.start, func(l cdiff.Line) int { return l.NewLineNumber })
+	}
+
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+}
+
+func precedingLineNumber(lines []cdiff.Line, index int, lineNumber func(cdiff.Line) int) int {
+	for i := index - 1; i >= 0; i-- {
+		if n := lineNumber(lines[i]); n > 0 {
+			return n
+		}
+	}
+
+	return 0
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// lineNumberWidth returns the number of digits of the largest line number
+// in the new object.
+func lineNumberWidth(lines []cdiff.Line) int {
+	largest := 0
+	for _, line := range lines {
+		if line.NewLineNumber > largest {
+			largest = line.NewLineNumber
+		}
+	}
+
+	return len(strconv.Itoa(largest))
+}
+
+// lineNumber returns the prefix for a line; lines that do not exist in the
+// new object are padded instead.
+func lineNumber(number int, exists bool, width int) string {
+	if !exists {
+		return strings.Repeat(" ", width+1)
+	}
+
+	return fmt.Sprintf("%*d ", width, number)
+}
+
+// numberDifflibLines prefixes every line of a unified diff with its line
+// number in the new object, based on the hunk headers.
+func numberDifflibLines(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+
+	// hunks never reach beyond the end of the new object, so the largest
+	// line number is the last line of the last hunk
+	width, current := 1, 0
+	for _, line := range lines {
+		if start, count, ok := newHunkRange(line); ok {
+			width = len(strconv.Itoa(start + count))
+		}
+	}
+
+	var builder strings.Builder
+
+	for _, line := range lines {
+		if start, _, ok := newHunkRange(line); ok {
+			current = start
+			builder.WriteString(line)
+			continue
+		}
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "-"):
+			builder.WriteString(lineNumber(0, false, width))
+		default:
+			builder.WriteString(lineNumber(current, true, width))
+			current++
+		}
+
+		builder.WriteString(line)
+	}
+
+	return builder.String()
+}
+
+// newHunkRange parses the new range "+c,d" from a "@@ -a,b +c,d @@" line.
+func newHunkRange(line string) (int, int, bool) {
+	if !strings.HasPrefix(line, "@@ ") {
+		return 0, 0, false
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, false
+	}
+
+	startText, countText, hasCount := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	return start, count, true
+}
+
+// renderDifflib renders a plain unified diff using go-difflib. The file
+// header is omitted, as the caller prints its own title.
+func renderDifflib(oldString, newString string, contextLines int) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:       splitLines(oldString),
+		B:       splitLines(newString),
+		Context: contextLines,
+	})
+}
+
+// splitLines splits the document into lines, each ending with a newline.
+// Unlike difflib.SplitLines, it does not add an empty line after the final
+// newline, which would show up as a context line that does not exist.
+func splitLines(s string) []string {
+	lines := difflib.SplitLines(s)
+	if s == "" || strings.HasSuffix(s, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
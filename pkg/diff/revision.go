@@ -0,0 +1,46 @@
+package diff
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// BuiltinRevisionAnnotations are annotations that controllers change
+// whenever an object was actually reconfigured, as opposed to incidental
+// changes like status updates.
+var BuiltinRevisionAnnotations = []string{
+	// Deployments and their ReplicaSets
+	"deployment.kubernetes.io/revision",
+	// DaemonSets
+	"deprecated.daemonset.template.generation",
+}
+
+// revisionChange returns an annotation like "(revision 3 → 4)" for the
+// first of the given annotations that differs between both objects, or an
+// empty string if none changed.
+func revisionChange(oldObj, newObj *unstructured.Unstructured, keys []string) string {
+	oldAnnotations := oldObj.GetAnnotations()
+	newAnnotations := newObj.GetAnnotations()
+
+	for _, key := range keys {
+		oldValue, oldExists := oldAnnotations[key]
+		newValue, newExists := newAnnotations[key]
+
+		if oldValue == newValue && oldExists == newExists {
+			continue
+		}
+
+		if !oldExists {
+			oldValue = "<none>"
+		}
+
+		if !newExists {
+			newValue = "<none>"
+		}
+
+		return fmt.Sprintf("(revision %s → %s)", oldValue, newValue)
+	}
+
+	return ""
+}
@@ -0,0 +1,58 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestRevisionChange(t *testing.T) {
+	testcases := []struct {
+		name     string
+		old      map[string]string
+		new      map[string]string
+		keys     []string
+		expected string
+	}{
+		{
+			name:     "changed revision",
+			old:      map[string]string{"deployment.kubernetes.io/revision": "3"},
+			new:      map[string]string{"deployment.kubernetes.io/revision": "4"},
+			keys:     BuiltinRevisionAnnotations,
+			expected: "(revision 3 → 4)",
+		},
+		{
+			name:     "unchanged revision",
+			old:      map[string]string{"deployment.kubernetes.io/revision": "3", "other": "a"},
+			new:      map[string]string{"deployment.kubernetes.io/revision": "3", "other": "b"},
+			keys:     BuiltinRevisionAnnotations,
+			expected: "",
+		},
+		{
+			name:     "first revision",
+			old:      nil,
+			new:      map[string]string{"example.com/spec-hash": "abc"},
+			keys:     []string{"example.com/spec-hash"},
+			expected: "(revision <none> → abc)",
+		},
+		{
+			name:     "unknown annotation",
+			old:      map[string]string{"example.com/spec-hash": "abc"},
+			new:      map[string]string{"example.com/spec-hash": "def"},
+			keys:     BuiltinRevisionAnnotations,
+			expected: "",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldObj := parseObject(t, "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: foo\n")
+			oldObj.SetAnnotations(tc.old)
+
+			newObj := oldObj.DeepCopy()
+			newObj.SetAnnotations(tc.new)
+
+			if change := revisionChange(oldObj, newObj, tc.keys); change != tc.expected {
+				t.Errorf("Expected %q, but got %q.", tc.expected, change)
+			}
+		})
+	}
+}
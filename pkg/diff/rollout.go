@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// rolloutFields are the fields that describe the progress of a rolling
+// update for each workload kind.
+var rolloutFields = map[string][]wideColumn{
+	"Deployment": {
+		{name: "replicas", path: "{.spec.replicas}"},
+		{name: "updatedReplicas", path: "{.status.updatedReplicas}"},
+		{name: "readyReplicas", path: "{.status.readyReplicas}"},
+		{name: "availableReplicas", path: "{.status.availableReplicas}"},
+	},
+	"StatefulSet": {
+		{name: "replicas", path: "{.spec.replicas}"},
+		{name: "updatedReplicas", path: "{.status.updatedReplicas}"},
+		{name: "readyReplicas", path: "{.status.readyReplicas}"},
+		{name: "currentRevision", path: "{.status.currentRevision}"},
+		{name: "updateRevision", path: "{.status.updateRevision}"},
+	},
+	"DaemonSet": {
+		{name: "desiredNumberScheduled", path: "{.status.desiredNumberScheduled}"},
+		{name: "updatedNumberScheduled", path: "{.status.updatedNumberScheduled}"},
+		{name: "numberReady", path: "{.status.numberReady}"},
+		{name: "numberAvailable", path: "{.status.numberAvailable}"},
+	},
+}
+
+// hasRolloutFields returns true if the kind of the object is a workload
+// with known rollout fields.
+func hasRolloutFields(obj *unstructured.Unstructured) bool {
+	_, ok := rolloutFields[obj.GetKind()]
+	return ok
+}
+
+// PrintRolloutLine renders a single line like "15:04:05 MODIFIED
+// StatefulSet default/web updatedReplicas=1→2 readyReplicas=3" with the
+// rollout fields of the object, marking the values that changed since the
+// previous state. Updates that did not change any rollout field are not
+// shown at all.
+func (d *Differ) PrintRolloutLine(out io.Writer, eventType watch.EventType, oldObj, newObj *unstructured.Unstructured, info TitleInfo) error {
+	obj := newObj
+	if obj == nil {
+		obj = oldObj
+	}
+
+	var oldValues, newValues []string
+	if eventType == watch.Modified && oldObj != nil {
+		oldValues = rolloutValues(oldObj)
+	}
+	newValues = rolloutValues(obj)
+
+	if oldValues != nil && strings.Join(oldValues, "\x00") == strings.Join(newValues, "\x00") {
+		return nil
+	}
+
+	parts := []string{
+		d.eventTime(obj, d.now()).Format("15:04:05"),
+		string(eventType),
+		obj.GroupVersionKind().Kind,
+		info.key(obj),
+	}
+
+	for i, field := range rolloutFields[obj.GetKind()] {
+		value := newValues[i]
+		if oldValues != nil && oldValues[i] != value {
+			value = fmt.Sprintf("%s→%s", valueOrNone(oldValues[i]), valueOrNone(value))
+		} else if value == "" {
+			continue
+		}
+
+		parts = append(parts, fmt.Sprintf("%s=%s", field.name, value))
+	}
+
+	parts = append(parts, info.annotations()...)
+
+	_, err := fmt.Fprintln(out, strings.Join(parts, " "))
+
+	return err
+}
+
+// rolloutValues returns the value of each rollout field of the object, in
+// the order of rolloutFields. Missing values are empty strings.
+func rolloutValues(obj *unstructured.Unstructured) []string {
+	fields := rolloutFields[obj.GetKind()]
+	values := make([]string, len(fields))
+
+	for i, field := range fields {
+		path := jsonpath.New(field.name).AllowMissingKeys(true)
+		if err := path.Parse(field.path); err != nil {
+			continue
+		}
+
+		if value, err := jsonPathValue(path, obj); err == nil {
+			values[i] = value
+		}
+	}
+
+	return values
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+
+	return value
+}
@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const rolloutOld = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 3
+status:
+  updatedReplicas: 1
+  readyReplicas: 3
+  currentRevision: web-1
+  updateRevision: web-2
+`
+
+const rolloutNew = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 3
+status:
+  updatedReplicas: 2
+  readyReplicas: 2
+  currentRevision: web-1
+  updateRevision: web-2
+  observedGeneration: 5
+`
+
+func TestPrintRolloutLine(t *testing.T) {
+	testcases := []struct {
+		name      string
+		eventType watch.EventType
+		old       string
+		new       string
+		expected  string
+	}{
+		{
+			name:      "creation",
+			eventType: watch.Added,
+			new:       rolloutOld,
+			expected:  "12:00:00 ADDED StatefulSet default/web replicas=3 updatedReplicas=1 readyReplicas=3 currentRevision=web-1 updateRevision=web-2\n",
+		},
+		{
+			name:      "changed fields are marked",
+			eventType: watch.Modified,
+			old:       rolloutOld,
+			new:       rolloutNew,
+			expected:  "12:00:00 MODIFIED StatefulSet default/web replicas=3 updatedReplicas=1→2 readyReplicas=3→2 currentRevision=web-1 updateRevision=web-2\n",
+		},
+		{
+			name:      "unrelated changes are skipped",
+			eventType: watch.Modified,
+			old:       rolloutNew,
+			new:       rolloutNew,
+			expected:  "",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			differ, err := NewDiffer(&Options{Rollout: true}, logrus.New())
+			if err != nil {
+				t.Fatalf("failed to create differ: %v", err)
+			}
+
+			differ.now = func() time.Time {
+				return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+			}
+
+			var buf bytes.Buffer
+			if err := differ.PrintRolloutLine(&buf, testcase.eventType, parseObject(t, testcase.old), parseObject(t, testcase.new), TitleInfo{}); err != nil {
+				t.Fatalf("failed to print line: %v", err)
+			}
+
+			if actual := buf.String(); actual != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/shibukawa/cdiff"
+)
+
+// renderSections renders the diff of every top-level field separately,
+// each below a header like "=== spec ===". Fields that did not change are
+// left out. Documents that are not maps (e.g. the result of a JSON path)
+// are rendered as a single diff.
+func (d *Differ) renderSections(oldString, newString string, colorTheme map[cdiff.Tag]color.Style, plain bool) (string, error) {
+	oldSections, oldOK := yamlSections(oldString)
+	newSections, newOK := yamlSections(newString)
+	if !oldOK || !newOK {
+		return d.renderBody(oldString, newString, colorTheme, plain)
+	}
+
+	header := colorTheme[cdiff.OpenSection].Sprint
+	if plain {
+		header = fmt.Sprint
+	}
+
+	var builder strings.Builder
+
+	for _, key := range sectionKeys(oldSections, newSections) {
+		oldSection, newSection := oldSections[key], newSections[key]
+		if oldSection == newSection {
+			continue
+		}
+
+		// difflib would treat the final line break as an additional empty line
+		body, err := d.renderBody(strings.TrimSuffix(oldSection, "\n"), strings.TrimSuffix(newSection, "\n"), colorTheme, plain)
+		if err != nil {
+			return "", err
+		}
+
+		builder.WriteString(header("=== " + key + " ==="))
+		builder.WriteString("\n")
+		builder.WriteString(body)
+	}
+
+	return builder.String(), nil
+}
+
+// yamlSections splits a YAML document into its top-level fields, each
+// including the field's key. An empty document has no sections.
+func yamlSections(doc string) (map[string]string, bool) {
+	sections := map[string]string{}
+	if doc == "" {
+		return sections, true
+	}
+
+	var (
+		key     string
+		builder strings.Builder
+	)
+
+	for _, line := range strings.SplitAfter(doc, "\n") {
+		if line == "" {
+			continue
+		}
+
+		// nested fields, list items below a top-level key and the
+		// continuation of multi-line strings are all indented
+		if line[0] == ' ' || (line[0] == '-' && key != "") {
+			if key == "" {
+				return nil, false
+			}
+
+			builder.WriteString(line)
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			return nil, false
+		}
+
+		if key != "" {
+			sections[key] = builder.String()
+			builder.Reset()
+		}
+
+		key = line[:colon]
+		builder.WriteString(line)
+	}
+
+	if key != "" {
+		sections[key] = builder.String()
+	}
+
+	return sections, true
+}
+
+func sectionKeys(a, b map[string]string) []string {
+	keys := []string{}
+
+	for key := range a {
+		keys = append(keys, key)
+	}
+
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"go.xrstf.de/stalk/pkg/maputil"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// equalizeFields returns a copy of the old object in which all fields at
+// the given paths have the value from the new object, or are removed if
+// the new object does not have them.
+func equalizeFields(oldObj, newObj *unstructured.Unstructured, paths []maputil.Path) *unstructured.Unstructured {
+	equalized := oldObj.DeepCopy()
+
+	for _, path := range paths {
+		equalizeField(equalized.Object, newObj.Object, path)
+	}
+
+	return equalized
+}
+
+func equalizeField(oldMap, newMap map[string]interface{}, path maputil.Path) {
+	head := path.Head()
+	tail := path.Tail()
+
+	newValue, exists := newMap[head]
+
+	if len(tail) == 0 {
+		if exists {
+			oldMap[head] = newValue
+		} else {
+			delete(oldMap, head)
+		}
+
+		return
+	}
+
+	newChild, ok := newValue.(map[string]interface{})
+	if !ok {
+		newChild = map[string]interface{}{}
+	}
+
+	oldChild, ok := oldMap[head].(map[string]interface{})
+	if !ok {
+		// only create the parent if the field exists in the new object,
+		// otherwise there is nothing to show
+		if len(newChild) == 0 {
+			return
+		}
+
+		oldChild = map[string]interface{}{}
+	}
+
+	equalizeField(oldChild, newChild, tail)
+
+	if len(oldChild) > 0 {
+		oldMap[head] = oldChild
+	} else if _, existed := oldMap[head]; existed && !exists {
+		delete(oldMap, head)
+	}
+}
@@ -0,0 +1,86 @@
+package diff
+
+import (
+	"testing"
+
+	"go.xrstf.de/stalk/pkg/maputil"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestEqualizeFields(t *testing.T) {
+	testcases := []struct {
+		name     string
+		old      string
+		new      string
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "changed field",
+			old:      "metadata: {name: a, resourceVersion: '1'}",
+			new:      "metadata: {name: a, resourceVersion: '2'}",
+			paths:    []string{"metadata.resourceVersion"},
+			expected: "metadata: {name: a, resourceVersion: '2'}",
+		},
+		{
+			name:     "added field",
+			old:      "metadata: {name: a}",
+			new:      "metadata: {name: a, annotations: {checked: now}}",
+			paths:    []string{"metadata.annotations.checked"},
+			expected: "metadata: {name: a, annotations: {checked: now}}",
+		},
+		{
+			name:     "removed field",
+			old:      "metadata: {name: a, annotations: {checked: before}}",
+			new:      "metadata: {name: a}",
+			paths:    []string{"metadata.annotations.checked"},
+			expected: "metadata: {name: a}",
+		},
+		{
+			name:     "other fields are kept",
+			old:      "spec: {replicas: 1}\nstatus: {observedGeneration: 1}",
+			new:      "spec: {replicas: 2}\nstatus: {observedGeneration: 2}",
+			paths:    []string{"spec.missing"},
+			expected: "spec: {replicas: 1}\nstatus: {observedGeneration: 1}",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			paths := []maputil.Path{}
+			for _, path := range testcase.paths {
+				parsed, err := maputil.ParsePath(path)
+				if err != nil {
+					t.Fatalf("failed to parse path: %v", err)
+				}
+
+				paths = append(paths, parsed)
+			}
+
+			header := "apiVersion: v1\nkind: Example\n"
+
+			oldObj := parseObject(t, header+testcase.old)
+			equalized := equalizeFields(oldObj, parseObject(t, header+testcase.new), paths)
+
+			if actual, expected := encodeYAML(t, equalized), encodeYAML(t, parseObject(t, header+testcase.expected)); actual != expected {
+				t.Errorf("Expected %q, but got %q.", expected, actual)
+			}
+
+			// the original object must not be modified
+			if actual, expected := encodeYAML(t, oldObj), encodeYAML(t, parseObject(t, header+testcase.old)); actual != expected {
+				t.Errorf("Expected the old object to stay %q, but got %q.", expected, actual)
+			}
+		})
+	}
+}
+
+func encodeYAML(t *testing.T, obj *unstructured.Unstructured) string {
+	encoded, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		t.Fatalf("failed to encode object: %v", err)
+	}
+
+	return string(encoded)
+}
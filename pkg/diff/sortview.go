@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// clearScreen moves the cursor to the top left corner and clears the
+// terminal.
+const clearScreen = "\033[H\033[2J"
+
+// sortedView holds the last line that was printed for every object, to
+// show all objects sorted by a field.
+type sortedView struct {
+	rows map[string]sortedRow
+}
+
+type sortedRow struct {
+	key   string
+	line  string
+	value string
+}
+
+func newSortedView() *sortedView {
+	return &sortedView{
+		rows: map[string]sortedRow{},
+	}
+}
+
+func (v *sortedView) set(key string, line string, value string) {
+	v.rows[key] = sortedRow{
+		key:   key,
+		line:  line,
+		value: value,
+	}
+}
+
+func (v *sortedView) remove(key string) {
+	delete(v.rows, key)
+}
+
+// sorted returns all rows ordered by their value. Values that are numbers
+// or quantities (like "500m") come first, in numeric order, followed by all
+// other values in lexical order and then by objects that have no value.
+// Rows with the same value are ordered by their key.
+func (v *sortedView) sorted() []sortedRow {
+	rows := make([]sortedRow, 0, len(v.rows))
+	for _, row := range v.rows {
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return lessRow(rows[i], rows[j])
+	})
+
+	return rows
+}
+
+func lessRow(a, b sortedRow) bool {
+	aQuantity, aRank := sortRank(a.value)
+	bQuantity, bRank := sortRank(b.value)
+
+	if aRank != bRank {
+		return aRank < bRank
+	}
+
+	switch aRank {
+	case 0:
+		if cmp := aQuantity.Cmp(bQuantity); cmp != 0 {
+			return cmp < 0
+		}
+
+	case 1:
+		if a.value != b.value {
+			return a.value < b.value
+		}
+	}
+
+	return a.key < b.key
+}
+
+// sortRank returns 0 for numeric values, 1 for all other values and 2 for
+// missing values.
+func sortRank(value string) (resource.Quantity, int) {
+	if value == "" {
+		return resource.Quantity{}, 2
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}, 1
+	}
+
+	return quantity, 0
+}
+
+// sortValue returns the value of the SortBy expression for the object, or
+// an empty string if the object has no such value.
+func (d *Differ) sortValue(obj *unstructured.Unstructured) string {
+	value, err := jsonPathValue(d.opt.compiledSortBy, obj)
+	if err != nil {
+		d.log.Warnf("Failed to apply sort JSON path: %v", err)
+	}
+
+	return value
+}
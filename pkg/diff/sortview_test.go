@@ -0,0 +1,32 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortedView(t *testing.T) {
+	view := newSortedView()
+	view.set("Pod default/e", "e", "")
+	view.set("Pod default/d", "d", "beta")
+	view.set("Pod default/c", "c", "1Gi")
+	view.set("Pod default/b", "b", "500m")
+	view.set("Pod default/a", "a", "alpha")
+	view.set("Pod default/f", "f", "2")
+	view.set("Pod default/g", "g", "2")
+	view.set("Pod default/h", "h", "gone")
+	view.remove("Pod default/h")
+
+	// updating an object replaces its previous line
+	view.set("Pod default/b", "b2", "500m")
+
+	lines := []string{}
+	for _, row := range view.sorted() {
+		lines = append(lines, row.line)
+	}
+
+	expected := []string{"b2", "f", "g", "c", "a", "d", "e"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %v, but got %v.", expected, lines)
+	}
+}
@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// tickerState is the single line that is shown in ticker mode (see
+// Options.Ticker). It remembers the value of every object, so that changes
+// can be told apart from updates to other fields.
+type tickerState struct {
+	entries map[string]*tickerEntry
+
+	// current is the entry of the object of the latest event.
+	current *tickerEntry
+
+	shown bool
+	ended bool
+}
+
+type tickerEntry struct {
+	title     string
+	value     string
+	changedAt time.Time
+	changed   bool
+}
+
+func newTickerState() *tickerState {
+	return &tickerState{
+		entries: map[string]*tickerEntry{},
+	}
+}
+
+// tickerValue returns the value of the JSONPath expression for the object.
+func (d *Differ) tickerValue(event watcher.Event) string {
+	if event.Type == watch.Deleted {
+		return "<deleted>"
+	}
+
+	value, err := jsonPathValue(d.opt.compiledJSONPath, event.New)
+	if err != nil {
+		d.log.Warnf("Failed to apply JSON path: %v", err)
+	}
+
+	if value == "" {
+		return "<none>"
+	}
+
+	return value
+}
+
+// update records the event's value and makes its object the one that is
+// shown.
+func (t *tickerState) update(key string, title string, value string, now time.Time) {
+	entry, exists := t.entries[key]
+	switch {
+	case !exists:
+		entry = &tickerEntry{
+			title:     title,
+			value:     value,
+			changedAt: now,
+		}
+		t.entries[key] = entry
+
+	case entry.value != value:
+		entry.value = value
+		entry.changedAt = now
+		entry.changed = true
+	}
+
+	t.current = entry
+}
+
+// render replaces the line on the terminal with the current state, e.g.
+// "Deployment default/web readyReplicas: 3 (changed 12s ago)".
+func (t *tickerState) render(out io.Writer, label string, now time.Time) error {
+	if t.current == nil || t.ended {
+		return nil
+	}
+
+	since := "since"
+	if t.current.changed {
+		since = "changed"
+	}
+
+	_, err := fmt.Fprintf(out, "\r\033[K%s %s: %s (%s %s)", t.current.title, label, t.current.value, since, relativeTime(t.current.changedAt, now))
+	t.shown = true
+
+	return err
+}
+
+// end moves the cursor below the line, so that it is not overwritten by
+// later output.
+func (t *tickerState) end(out io.Writer) error {
+	t.ended = true
+
+	if !t.shown {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(out)
+
+	return err
+}
@@ -0,0 +1,59 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestTicker(t *testing.T) {
+	differ, err := NewDiffer(&Options{Ticker: true, JSONPath: "{.spec.replicas}"}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	differ.now = func() time.Time {
+		return now
+	}
+
+	var buf bytes.Buffer
+	printer := NewPrinter(differ, &buf, &PrinterOptions{}, logrus.New())
+
+	oldObj := parseObject(t, oldDeployment)
+	newObj := parseObject(t, newDeployment)
+
+	printer.PrintEvent(watcher.Event{Type: watch.Added, New: oldObj})
+
+	now = now.Add(30 * time.Second)
+	printer.RefreshTicker()
+
+	// an update that does not change the value must keep the time
+	now = now.Add(30 * time.Second)
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: oldObj, New: oldObj})
+
+	printer.PrintEvent(watcher.Event{Type: watch.Modified, Old: oldObj, New: newObj})
+
+	now = now.Add(12 * time.Second)
+	printer.RefreshTicker()
+	printer.EndTicker()
+
+	// no more updates after the ticker has ended
+	printer.RefreshTicker()
+
+	expected := "\r\033[KDeployment default/nginx replicas: 1 (since just now)" +
+		"\r\033[KDeployment default/nginx replicas: 1 (since 30s ago)" +
+		"\r\033[KDeployment default/nginx replicas: 1 (since 60s ago)" +
+		"\r\033[KDeployment default/nginx replicas: 3 (changed just now)" +
+		"\r\033[KDeployment default/nginx replicas: 3 (changed 12s ago)" +
+		"\n"
+
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Expected %q, but got %q.", expected, actual)
+	}
+}
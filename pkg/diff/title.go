@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/objectkey"
+
+	"github.com/gookit/color"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TitleInfo contains additional information about an event that is shown
+// as part of its title.
+type TitleInfo struct {
+	// KeyPrefix is shown in front of the object key, e.g. the name of the
+	// cluster the object belongs to.
+	KeyPrefix string
+
+	// KeyPrefixStyle, if set, is used to color the KeyPrefix, unless the
+	// output is plain.
+	KeyPrefixStyle color.Style
+
+	// Annotations are appended to the title of the most recent object.
+	Annotations []string
+
+	// deferred, if set, returns further annotations. It is only called once
+	// the event is actually shown, for annotations that must not be
+	// determined for hidden events.
+	deferred func() []string
+
+	// plain disables colors for the key prefix; surrounding is the style of
+	// the text around the key, which must be restored after the prefix.
+	plain       bool
+	surrounding color.Style
+}
+
+func (t TitleInfo) key(obj *unstructured.Unstructured) string {
+	key := objectkey.Of(obj)
+	if t.KeyPrefix == "" {
+		return key
+	}
+
+	prefix := t.KeyPrefix
+	if len(t.KeyPrefixStyle) > 0 && !t.plain {
+		prefix = styledPrefix(prefix, t.KeyPrefixStyle, t.surrounding)
+	}
+
+	return fmt.Sprintf("%s:%s", prefix, key)
+}
+
+// annotations returns all annotations, including the deferred ones.
+func (t TitleInfo) annotations() []string {
+	if t.deferred == nil {
+		return t.Annotations
+	}
+
+	return append(append([]string{}, t.Annotations...), t.deferred()...)
+}
+
+func (t TitleInfo) suffix() string {
+	annotations := t.annotations()
+	if len(annotations) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(annotations, " ")
+}
+
+func diffTitle(obj *unstructured.Unstructured, lastSeen time.Time, info TitleInfo) string {
+	if obj == nil {
+		return "(none)"
+	}
+
+	timestamp := lastSeen.Format(time.RFC3339)
+	kind := obj.GroupVersionKind().Kind
+
+	return fmt.Sprintf("%s %s v%s (%s) (gen. %d)", kind, info.key(obj), obj.GetResourceVersion(), timestamp, obj.GetGeneration())
+}
+
+// eventTime returns the time to show for the object, which is the time it
+// was received, unless EventTimeObject is configured and the object records
+// a time itself.
+func (d *Differ) eventTime(obj *unstructured.Unstructured, received time.Time) time.Time {
+	if d.opt.EventTime != EventTimeObject || obj == nil {
+		return received
+	}
+
+	if recorded := objectTime(obj); !recorded.IsZero() {
+		return recorded.Local()
+	}
+
+	return received
+}
+
+// objectTime returns the latest time recorded in the object, i.e. the time
+// of the last change by any field manager, or its creation or deletion.
+func objectTime(obj *unstructured.Unstructured) time.Time {
+	latest := obj.GetCreationTimestamp().Time
+
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(latest) {
+			latest = entry.Time.Time
+		}
+	}
+
+	if deleted := obj.GetDeletionTimestamp(); deleted != nil && deleted.After(latest) {
+		latest = deleted.Time
+	}
+
+	return latest
+}
+
+// compactTitle returns a short tag like "[UPD ns/name]".
+func compactTitle(oldObj, newObj *unstructured.Unstructured, info TitleInfo) string {
+	switch {
+	case oldObj == nil:
+		return fmt.Sprintf("[CRE %s]", info.key(newObj))
+	case newObj == nil:
+		return fmt.Sprintf("[DEL %s]", info.key(oldObj))
+	default:
+		return fmt.Sprintf("[UPD %s]", info.key(newObj))
+	}
+}
@@ -0,0 +1,88 @@
+package diff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestEventTime(t *testing.T) {
+	received := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name      string
+		eventTime string
+		object    string
+		expected  time.Time
+	}{
+		{
+			name:      "received time",
+			eventTime: EventTimeReceived,
+			object: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  creationTimestamp: "2022-08-01T10:00:00Z"
+`,
+			expected: received,
+		},
+		{
+			name:      "latest managed fields entry",
+			eventTime: EventTimeObject,
+			object: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  creationTimestamp: "2022-08-01T10:00:00Z"
+  managedFields:
+    - manager: kubectl
+      operation: Update
+      time: "2022-08-02T10:00:00Z"
+    - manager: controller
+      operation: Update
+      time: "2022-08-03T10:00:00Z"
+`,
+			expected: time.Date(2022, 8, 3, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "deletion timestamp",
+			eventTime: EventTimeObject,
+			object: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  creationTimestamp: "2022-08-01T10:00:00Z"
+  deletionTimestamp: "2022-08-04T10:00:00Z"
+`,
+			expected: time.Date(2022, 8, 4, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "no recorded time",
+			eventTime: EventTimeObject,
+			object: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+`,
+			expected: received,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			differ, err := NewDiffer(&Options{EventTime: testcase.eventTime}, logrus.New())
+			if err != nil {
+				t.Fatalf("Failed to create differ: %v", err)
+			}
+
+			if actual := differ.eventTime(parseObject(t, testcase.object), received); !actual.Equal(testcase.expected) {
+				t.Errorf("Expected %v, but got %v.", testcase.expected, actual)
+			}
+		})
+	}
+}
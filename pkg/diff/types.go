@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"go.xrstf.de/stalk/pkg/maputil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// typeChange is a field whose value changed its type, e.g. from a string
+// to a number. Such changes are easy to miss in a diff, as the rendered
+// values often look alike.
+type typeChange struct {
+	path    maputil.Path
+	oldType string
+	newType string
+}
+
+func (c typeChange) String() string {
+	return fmt.Sprintf("%s (type changed: %s → %s)", c.path, c.oldType, c.newType)
+}
+
+// typeChanges compares the two rendered objects and returns all fields
+// that exist in both, but with different types.
+func typeChanges(oldString, newString string) ([]typeChange, error) {
+	var oldValue, newValue interface{}
+
+	if err := yaml.Unmarshal([]byte(oldString), &oldValue); err != nil {
+		return nil, fmt.Errorf("failed to decode previous object: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(newString), &newValue); err != nil {
+		return nil, fmt.Errorf("failed to decode current object: %w", err)
+	}
+
+	changes := []typeChange{}
+
+	for _, change := range maputil.Compare(oldValue, newValue) {
+		if change.OldValue == nil || change.NewValue == nil {
+			continue
+		}
+
+		oldType, newType := valueType(change.OldValue), valueType(change.NewValue)
+		if oldType != newType {
+			changes = append(changes, typeChange{
+				path:    change.Path,
+				oldType: oldType,
+				newType: newType,
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// valueType returns the JSON type of a decoded value.
+func valueType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// typeChangeNotes renders one line per type change.
+func typeChangeNotes(changes []typeChange) string {
+	var builder strings.Builder
+
+	for _, change := range changes {
+		builder.WriteString("! ")
+		builder.WriteString(change.String())
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
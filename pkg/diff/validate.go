@@ -0,0 +1,165 @@
+package diff
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ExpressionJSONPath = "jsonpath"
+	ExpressionShow     = "show"
+	ExpressionHide     = "hide"
+)
+
+// eventFilterPrefixes are prepended to the expression types of event
+// specific filters, e.g. "update-hide".
+var eventFilterPrefixes = map[watch.EventType]string{
+	watch.Added:    "create-",
+	watch.Modified: "update-",
+	watch.Deleted:  "delete-",
+}
+
+// ExpressionResult is the outcome of validating a single expression.
+type ExpressionResult struct {
+	// Type is one of the Expression* constants, for event specific filters
+	// prefixed with the type of event (e.g. "update-hide").
+	Type       string
+	Expression string
+
+	// Err is set if the expression is invalid.
+	Err error
+
+	// Matches is only set if the expression is valid and a sample object
+	// was given.
+	Matches *bool
+}
+
+// ValidateExpressions checks each JSON path, include and exclude expression
+// (including the event specific ones) in the options on its own. If a sample object is given, valid expressions
+// are applied to it to determine whether they match anything.
+func ValidateExpressions(opt *Options, sample *unstructured.Unstructured, log logrus.FieldLogger) []ExpressionResult {
+	results := []ExpressionResult{}
+
+	if opt.JSONPath != "" {
+		results = append(results, validateExpression(ExpressionJSONPath, opt.JSONPath, &Options{JSONPath: opt.JSONPath}, sample, log))
+	}
+
+	for _, path := range opt.IncludePaths {
+		results = append(results, validateExpression(ExpressionShow, path, &Options{IncludePaths: []string{path}}, sample, log))
+	}
+
+	for _, path := range opt.ExcludePaths {
+		results = append(results, validateExpression(ExpressionHide, path, &Options{ExcludePaths: []string{path}}, sample, log))
+	}
+
+	for _, eventType := range []watch.EventType{watch.Added, watch.Modified, watch.Deleted} {
+		filter, ok := opt.EventFilters[eventType]
+		if !ok {
+			continue
+		}
+
+		prefix := eventFilterPrefixes[eventType]
+
+		for _, path := range filter.IncludePaths {
+			results = append(results, validateExpression(prefix+ExpressionShow, path, &Options{IncludePaths: []string{path}}, sample, log))
+		}
+
+		for _, path := range filter.ExcludePaths {
+			results = append(results, validateExpression(prefix+ExpressionHide, path, &Options{ExcludePaths: []string{path}}, sample, log))
+		}
+	}
+
+	return results
+}
+
+func validateExpression(expressionType string, expression string, opt *Options, sample *unstructured.Unstructured, log logrus.FieldLogger) ExpressionResult {
+	result := ExpressionResult{
+		Type:       expressionType,
+		Expression: expression,
+	}
+
+	if err := opt.Validate(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	if sample == nil {
+		return result
+	}
+
+	var matches bool
+
+	if opt.compiledJSONPath != nil {
+		values, err := jsonPathResults(opt.compiledJSONPath, sample.Object)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		matches = len(values) > 0
+	} else {
+		unfiltered, err := (&Differ{opt: &Options{}, log: log, now: time.Now}).preprocess(sample, watch.Modified)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		filtered, err := (&Differ{opt: opt, log: log, now: time.Now}).preprocess(sample, watch.Modified)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		if strings.HasSuffix(expressionType, ExpressionShow) {
+			// pruning leaves the parents of missing paths behind
+			var pruned interface{}
+			if err := yaml.Unmarshal([]byte(filtered), &pruned); err != nil {
+				result.Err = err
+				return result
+			}
+
+			matches = hasValues(pruned)
+		} else {
+			matches = filtered != unfiltered
+		}
+	}
+
+	result.Matches = &matches
+
+	return result
+}
+
+// hasValues returns true if the value contains anything but empty maps and
+// lists.
+func hasValues(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+
+	case map[string]interface{}:
+		for _, item := range v {
+			if hasValues(item) {
+				return true
+			}
+		}
+
+		return false
+
+	case []interface{}:
+		for _, item := range v {
+			if hasValues(item) {
+				return true
+			}
+		}
+
+		return false
+
+	default:
+		return true
+	}
+}
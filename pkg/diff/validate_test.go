@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidateExpressions(t *testing.T) {
+	sample := parseObject(t, oldDeployment)
+
+	opt := &Options{
+		JSONPath:     "{.spec",
+		IncludePaths: []string{"spec.replicas", "spec.paused"},
+		ExcludePaths: []string{"status"},
+	}
+
+	results := ValidateExpressions(opt, sample, logrus.New())
+
+	expected := []struct {
+		expression string
+		valid      bool
+		matches    bool
+	}{
+		{expression: "{.spec", valid: false},
+		{expression: "spec.replicas", valid: true, matches: true},
+		{expression: "spec.paused", valid: true, matches: false},
+		{expression: "status", valid: true, matches: true},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, but got %d.", len(expected), len(results))
+	}
+
+	for i, result := range results {
+		if result.Expression != expected[i].expression {
+			t.Errorf("Expected result %d to be for %q, but got %q.", i, expected[i].expression, result.Expression)
+			continue
+		}
+
+		if valid := result.Err == nil; valid != expected[i].valid {
+			t.Errorf("Expected %q to be valid=%v, but got error %v.", result.Expression, expected[i].valid, result.Err)
+			continue
+		}
+
+		if !expected[i].valid {
+			continue
+		}
+
+		if result.Matches == nil || *result.Matches != expected[i].matches {
+			t.Errorf("Expected %q to have matches=%v, but got %v.", result.Expression, expected[i].matches, result.Matches)
+		}
+	}
+}
+
+func TestValidateExpressionsWithoutSample(t *testing.T) {
+	results := ValidateExpressions(&Options{ExcludePaths: []string{"status"}}, nil, logrus.New())
+
+	if len(results) != 1 || results[0].Err != nil || results[0].Matches != nil {
+		t.Errorf("Expected a single valid result without match information, but got %+v.", results)
+	}
+}
+
+func TestValidateJSONPathMatches(t *testing.T) {
+	sample := parseObject(t, oldDeployment)
+
+	testcases := []struct {
+		jsonPath string
+		matches  bool
+	}{
+		{jsonPath: "{.spec.replicas}", matches: true},
+		{jsonPath: "{.spec.paused}", matches: false},
+		// the first expression does not match, but the second one does
+		{jsonPath: "{.spec.paused}{.spec.replicas}", matches: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.jsonPath, func(t *testing.T) {
+			results := ValidateExpressions(&Options{JSONPath: testcase.jsonPath}, sample, logrus.New())
+			if len(results) != 1 || results[0].Err != nil {
+				t.Fatalf("Expected a single valid result, but got %+v.", results)
+			}
+
+			if results[0].Matches == nil || *results[0].Matches != testcase.matches {
+				t.Errorf("Expected matches=%v, but got %v.", testcase.matches, results[0].Matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/shibukawa/cdiff"
+)
+
+const (
+	// WhitespaceIgnore removes insignificant whitespace (trailing spaces
+	// and carriage returns) before diffing.
+	WhitespaceIgnore = "ignore"
+
+	// WhitespaceShow diffs the objects as they are.
+	WhitespaceShow = "show"
+
+	// WhitespaceMark is like WhitespaceShow, but makes whitespace in changed
+	// lines visible.
+	WhitespaceMark = "mark"
+)
+
+var WhitespaceModes = []string{WhitespaceIgnore, WhitespaceShow, WhitespaceMark}
+
+var whitespaceMarker = strings.NewReplacer(
+	" ", "·",
+	"\t", "→",
+	"\r", "␍",
+)
+
+// normalizeWhitespace removes trailing whitespace from every line.
+func normalizeWhitespace(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// markWhitespaceChanges makes whitespace visible in all changed lines.
+func markWhitespaceChanges(result cdiff.Result) cdiff.Result {
+	for i, line := range result.Lines {
+		if line.Ope == cdiff.Keep {
+			continue
+		}
+
+		fragments := make([]cdiff.Fragment, len(line.Fragments))
+		for j, f := range line.Fragments {
+			fragments[j] = cdiff.Fragment{
+				Text:    whitespaceMarker.Replace(f.Text),
+				Changed: f.Changed,
+			}
+		}
+
+		result.Lines[i].Fragments = fragments
+	}
+
+	return result
+}
+
+// markWhitespaceLines makes whitespace visible in all changed lines of a
+// plain unified diff.
+func markWhitespaceLines(body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			lines[i] = line[:1] + whitespaceMarker.Replace(line[1:])
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
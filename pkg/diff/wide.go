@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+type wideColumn struct {
+	name string
+	path string
+}
+
+// wideColumns are the at-a-glance fields shown for each kind in wide mode,
+// similar to kubectl's "-o wide".
+var wideColumns = map[string][]wideColumn{
+	"Pod": {
+		{name: "node", path: "{.spec.nodeName}"},
+		{name: "phase", path: "{.status.phase}"},
+		{name: "ready", path: "{.status.containerStatuses[*].ready}"},
+	},
+	"Deployment": {
+		{name: "replicas", path: "{.spec.replicas}"},
+		{name: "ready", path: "{.status.readyReplicas}"},
+		{name: "updated", path: "{.status.updatedReplicas}"},
+	},
+	"StatefulSet": {
+		{name: "replicas", path: "{.spec.replicas}"},
+		{name: "ready", path: "{.status.readyReplicas}"},
+	},
+	"ReplicaSet": {
+		{name: "replicas", path: "{.spec.replicas}"},
+		{name: "ready", path: "{.status.readyReplicas}"},
+	},
+	"DaemonSet": {
+		{name: "desired", path: "{.status.desiredNumberScheduled}"},
+		{name: "ready", path: "{.status.numberReady}"},
+	},
+	"Job": {
+		{name: "active", path: "{.status.active}"},
+		{name: "succeeded", path: "{.status.succeeded}"},
+		{name: "failed", path: "{.status.failed}"},
+	},
+	"Node": {
+		{name: "ready", path: `{.status.conditions[?(@.type=="Ready")].status}`},
+		{name: "version", path: "{.status.nodeInfo.kubeletVersion}"},
+	},
+	"Service": {
+		{name: "type", path: "{.spec.type}"},
+		{name: "clusterIP", path: "{.spec.clusterIP}"},
+	},
+	"PersistentVolumeClaim": {
+		{name: "phase", path: "{.status.phase}"},
+		{name: "volume", path: "{.spec.volumeName}"},
+	},
+	"Namespace": {
+		{name: "phase", path: "{.status.phase}"},
+	},
+}
+
+// wideAnnotation returns something like "(node=worker-1 phase=Running)" for
+// kinds with known wide columns. Empty values are omitted; for unknown kinds
+// or if no values are set, an empty string is returned.
+func wideAnnotation(obj *unstructured.Unstructured) string {
+	columns, ok := wideColumns[obj.GetKind()]
+	if !ok {
+		return ""
+	}
+
+	values := []string{}
+	for _, column := range columns {
+		path := jsonpath.New(column.name).AllowMissingKeys(true)
+		if err := path.Parse(column.path); err != nil {
+			continue
+		}
+
+		value, err := jsonPathValue(path, obj)
+		if err != nil || value == "" {
+			continue
+		}
+
+		values = append(values, fmt.Sprintf("%s=%s", column.name, value))
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(values, " "))
+}
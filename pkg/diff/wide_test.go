@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestWideAnnotation(t *testing.T) {
+	testcases := []struct {
+		name     string
+		object   string
+		expected string
+	}{
+		{
+			name: "pod with all columns",
+			object: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+spec:
+  nodeName: worker-1
+status:
+  phase: Running
+  containerStatuses:
+    - ready: true
+    - ready: false
+`,
+			expected: "(node=worker-1 phase=Running ready=true,false)",
+		},
+		{
+			name: "missing values are skipped",
+			object: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Pending
+`,
+			expected: "(phase=Pending)",
+		},
+		{
+			name: "node readiness condition",
+			object: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: foo
+status:
+  conditions:
+    - type: MemoryPressure
+      status: "False"
+    - type: Ready
+      status: "True"
+`,
+			expected: "(ready=True)",
+		},
+		{
+			name: "unknown kind",
+			object: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+`,
+			expected: "",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			result := wideAnnotation(parseObject(t, testcase.object))
+			if result != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// yamlEventNames describe the events in the comments of YAML output.
+var yamlEventNames = map[watch.EventType]string{
+	watch.Added:    "created",
+	watch.Modified: "updated",
+	watch.Deleted:  "deleted",
+}
+
+// printYAML renders the current state of the object as a YAML document,
+// preceded by a comment describing the event. Deleted objects are only
+// mentioned in a comment, so that applying the output to a cluster does
+// not recreate them.
+func (d *Differ) printYAML(out io.Writer, eventType watch.EventType, newObj, oldObj *unstructured.Unstructured, newString string, info TitleInfo) error {
+	obj := newObj
+	if obj == nil {
+		obj = oldObj
+	}
+
+	// YAML output is meant to be processed by other tools
+	info.plain = true
+
+	var builder strings.Builder
+
+	if !d.opt.NoHeaders {
+		builder.WriteString(yamlComment(fmt.Sprintf("%s: %s%s", yamlEventNames[eventType], diffTitle(obj, d.eventTime(obj, d.now()), info), info.suffix())))
+	}
+
+	if newObj != nil {
+		builder.WriteString("---\n")
+		builder.WriteString(newString)
+	}
+
+	_, err := io.WriteString(out, builder.String())
+
+	return err
+}
+
+// yamlComment turns every line of the text into a YAML comment.
+func yamlComment(text string) string {
+	var builder strings.Builder
+
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		builder.WriteString("# ")
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
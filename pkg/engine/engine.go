@@ -0,0 +1,176 @@
+// Package engine watches Kubernetes resources and prints their changes as
+// diffs, like the stalk CLI does, so that it can be embedded in other tools.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/diff"
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+type Options struct {
+	// Namespace limits the lists and watches of namespaced kinds to a
+	// single namespace. If empty, all namespaces are watched.
+	Namespace string
+
+	// LabelSelector and FieldSelector are passed to the API server when
+	// listing and watching.
+	LabelSelector string
+	FieldSelector string
+
+	// OnlyChanges hides the objects that exist when Run is called, so that
+	// only later changes are printed.
+	OnlyChanges bool
+
+	// SortInitial, PollInterval, Last and Transform work like in
+	// WatchOptions and apply to all kinds.
+	SortInitial  string
+	PollInterval time.Duration
+	Last         int
+	Transform    watcher.TransformFunc
+
+	// Watcher, Differ and Printer configure which events are shown and how.
+	// If nil, all events are shown as colored diffs.
+	Watcher *watcher.Options
+	Differ  *diff.Options
+	Printer *diff.PrinterOptions
+
+	// Log receives warnings and debug messages. If nil, the standard logger
+	// is used.
+	Log logrus.FieldLogger
+}
+
+// Engine watches all objects of a set of kinds and writes their changes to
+// an io.Writer.
+type Engine struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	kinds  []schema.GroupVersionKind
+	opt    *Options
+	out    io.Writer
+}
+
+func New(client dynamic.Interface, mapper meta.RESTMapper, kinds []schema.GroupVersionKind, opt *Options, out io.Writer) *Engine {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	return &Engine{
+		client: client,
+		mapper: mapper,
+		kinds:  kinds,
+		opt:    opt,
+		out:    out,
+	}
+}
+
+// Run lists the existing objects, prints them (unless OnlyChanges is set)
+// and then prints all changes until ctx is cancelled. Watches that are
+// closed by the API server are re-established. An error is returned if any
+// of the kinds cannot be watched.
+func (e *Engine) Run(ctx context.Context) error {
+	log := e.opt.Log
+	if log == nil {
+		log = logrus.StandardLogger()
+	}
+
+	differOpts := e.opt.Differ
+	if differOpts == nil {
+		differOpts = &diff.Options{
+			DisableWordDiff:  true,
+			HideEmptyDiffs:   true,
+			CreateColorTheme: diff.CreateColorTheme,
+			UpdateColorTheme: diff.UpdateColorTheme,
+			DeleteColorTheme: diff.DeleteColorTheme,
+		}
+	}
+
+	printerOpts := e.opt.Printer
+	if printerOpts == nil {
+		printerOpts = &diff.PrinterOptions{}
+	}
+
+	watcherOpts := e.opt.Watcher
+	if watcherOpts == nil {
+		watcherOpts = &watcher.Options{}
+	}
+
+	differ, err := diff.NewDiffer(differOpts, log)
+	if err != nil {
+		return fmt.Errorf("failed to create differ: %w", err)
+	}
+
+	printer := diff.NewPrinter(differ, e.out, printerOpts, log)
+	w := watcher.NewWatcher(watcherOpts)
+
+	printed := make(chan struct{})
+	go func() {
+		printer.PrintEvents(w.Events())
+		close(printed)
+	}()
+
+	// stop the watches that have already been started if another one
+	// cannot be started
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+
+	for _, gvk := range e.kinds {
+		if err = e.startWatch(ctx, log.WithField("kind", gvk.Kind), gvk, w, &wg); err != nil {
+			cancel()
+			break
+		}
+	}
+
+	wg.Wait()
+	w.Close()
+	<-printed
+
+	return err
+}
+
+// startWatch resolves the client for the kind and starts watching it.
+func (e *Engine) startWatch(ctx context.Context, log logrus.FieldLogger, gvk schema.GroupVersionKind, w *watcher.Watcher, wg *sync.WaitGroup) error {
+	mapping, err := e.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("unknown resource kind %q: %w", gvk.Kind, err)
+	}
+
+	client := Client{ResourceInterface: e.client.Resource(mapping.Resource)}
+	if e.opt.Namespace != "" && mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = Client{
+			ResourceInterface: e.client.Resource(mapping.Resource).Namespace(e.opt.Namespace),
+			Namespace:         e.opt.Namespace,
+		}
+	}
+
+	return StartWatch(ctx, log, client, gvk, w, &WatchOptions{
+		ListOptions: metav1.ListOptions{
+			LabelSelector: e.opt.LabelSelector,
+			FieldSelector: e.opt.FieldSelector,
+		},
+		Transform:    e.opt.Transform,
+		OnlyChanges:  e.opt.OnlyChanges,
+		SortInitial:  e.opt.SortInitial,
+		PollInterval: e.opt.PollInterval,
+		Last:         e.opt.Last,
+	}, func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	})
+}
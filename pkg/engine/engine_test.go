@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/diff"
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// syncBuffer is a buffer that can be read while the engine writes to it.
+type syncBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	testcases := []struct {
+		name         string
+		pollInterval time.Duration
+	}{
+		{
+			name: "watch",
+		},
+		{
+			// kinds that cannot be watched are polled instead
+			name:         "poll",
+			pollInterval: 10 * time.Millisecond,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			testRun(t, testcase.pollInterval)
+		})
+	}
+}
+
+func testRun(t *testing.T, pollInterval time.Duration) {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace("default")
+	obj.SetName("settings")
+	obj.SetResourceVersion("1")
+	obj.Object["data"] = map[string]interface{}{"color": "blue"}
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr: "ConfigMapList",
+	}, obj)
+
+	log := logrus.New()
+	log.SetOutput(&bytes.Buffer{})
+
+	out := &syncBuffer{}
+	e := New(client, mapper, []schema.GroupVersionKind{gvk}, &Options{
+		Namespace:    "default",
+		PollInterval: pollInterval,
+		Differ:       &diff.Options{HideEmptyDiffs: true},
+		Log:          log,
+	}, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- e.Run(ctx)
+	}()
+
+	// the watch is started before the existing objects are printed
+	waitFor(t, out, "color: blue")
+
+	updated := obj.DeepCopy()
+	updated.SetResourceVersion("2")
+	updated.Object["data"] = map[string]interface{}{"color": "green"}
+
+	if _, err := client.Resource(gvr).Namespace("default").Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update object: %v", err)
+	}
+
+	waitFor(t, out, "color: green")
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error, but got %v.", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled.")
+	}
+}
+
+func TestRunUnknownKind(t *testing.T) {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	log := logrus.New()
+	log.SetOutput(&bytes.Buffer{})
+
+	e := New(client, meta.NewDefaultRESTMapper(nil), []schema.GroupVersionKind{gvk}, &Options{Log: log}, &bytes.Buffer{})
+
+	if err := e.Run(context.Background()); err == nil {
+		t.Error("Expected an error for an unknown kind, but got none.")
+	}
+}
+
+func waitFor(t *testing.T, out *syncBuffer, text string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), text) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected output to contain %q, but got:\n%s", text, out.String())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestContextWatch(t *testing.T) {
+	t.Run("watch ends", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		watchCtx, cancelWatch := context.WithCancel(ctx)
+		inner := watch.NewFake()
+		wi := newContextWatch(watchCtx, cancelWatch, inner)
+
+		go inner.Add(&unstructured.Unstructured{})
+
+		if _, ok := <-wi.ResultChan(); !ok {
+			t.Fatal("Expected an event, but the watch was closed.")
+		}
+
+		inner.Stop()
+
+		if _, ok := <-wi.ResultChan(); ok {
+			t.Fatal("Expected the watch to be closed.")
+		}
+
+		select {
+		case <-watchCtx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the context of the watch to be cancelled after it ended.")
+		}
+	})
+
+	t.Run("context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		watchCtx, cancelWatch := context.WithCancel(ctx)
+		inner := watch.NewFake()
+		wi := newContextWatch(watchCtx, cancelWatch, inner)
+
+		cancel()
+
+		if _, ok := <-wi.ResultChan(); ok {
+			t.Fatal("Expected the watch to be closed.")
+		}
+
+		if !inner.IsStopped() {
+			t.Error("Expected the inner watch to be stopped.")
+		}
+	})
+}
+
+func TestWatchAndReconnectTransformed(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	newObject := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		obj.SetResourceVersion("1")
+
+		return obj
+	}
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr: "DeploymentList",
+	}, newObject("kept"), newObject("deleted"))
+
+	// like with --scale, all objects are replaced by objects of another kind
+	transform := func(ctx context.Context, eventType watch.EventType, obj *unstructured.Unstructured) *unstructured.Unstructured {
+		return watcher.ScaleFromObject(obj)
+	}
+
+	log := logrus.New()
+	log.SetOutput(&bytes.Buffer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := watcher.NewWatcher(&watcher.Options{})
+
+	events := make(chan string, 10)
+	go func() {
+		for event := range w.Events() {
+			events <- fmt.Sprintf("%s %s", event.Type, event.Key)
+		}
+	}()
+
+	resources := client.Resource(gvr).Namespace("default")
+
+	initial, err := resources.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list objects: %v", err)
+	}
+
+	watcher.TransformList(ctx, initial, transform)
+	w.Prime(initial)
+
+	wi := watch.NewFake()
+	go WatchAndReconnect(ctx, log, Client{ResourceInterface: resources, Namespace: "default"}, metav1.ListOptions{}, gvk, initial, watcher.NewTransformWatch(ctx, wi, transform), w, transform, nil)
+
+	// the object is deleted while the watch is down
+	if err := resources.Delete(ctx, "deleted", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete object: %v", err)
+	}
+
+	wi.Stop()
+
+	select {
+	case event := <-events:
+		if expected := "DELETED default/deleted"; event != expected {
+			t.Errorf("Expected %q, but got %q.", expected, event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the deletion to be caught up on after reconnecting.")
+	}
+}
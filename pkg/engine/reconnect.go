@@ -0,0 +1,312 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+	"go.xrstf.de/stalk/pkg/objectkey"
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// minReconnectDelay and maxReconnectDelay limit how quickly stalk
+	// retries to re-establish a watch that could not be re-established.
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Client is a client for a single kind, optionally limited to a single
+// namespace.
+type Client struct {
+	dynamic.ResourceInterface
+	Namespace string
+}
+
+// List lists resources, waiting and retrying if the API server is
+// throttling requests.
+func List(ctx context.Context, log logrus.FieldLogger, client dynamic.ResourceInterface, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	var list *unstructured.UnstructuredList
+
+	err := kubeutil.RetryOnThrottling(ctx, log, func() (err error) {
+		list, err = client.List(ctx, opts)
+		return err
+	})
+
+	// which fields are selectable depends on the kind, so only the API
+	// server can tell whether a field selector is valid
+	if opts.FieldSelector != "" && apierrors.IsBadRequest(err) {
+		return nil, fmt.Errorf("field selector %q was rejected, only fields that are registered as selectable for this kind can be used: %w", opts.FieldSelector, err)
+	}
+
+	return list, err
+}
+
+// Watch starts a watch, waiting and retrying if the API server is
+// throttling requests. The watch is stopped once ctx is cancelled.
+func Watch(ctx context.Context, log logrus.FieldLogger, client dynamic.ResourceInterface, opts metav1.ListOptions) (watch.Interface, error) {
+	// every watch gets its own context, which is released as soon as the
+	// watch ends, so that no goroutine waits for ctx after a reconnect
+	watchCtx, cancel := context.WithCancel(ctx)
+
+	var wi watch.Interface
+
+	err := kubeutil.RetryOnThrottling(watchCtx, log, func() (err error) {
+		wi, err = client.Watch(watchCtx, opts)
+		return err
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return newContextWatch(watchCtx, cancel, wi), nil
+}
+
+// contextWatch passes on the events of a watch until its context is
+// cancelled or the watch ends, whichever comes first. Not every
+// implementation ends the watch together with its context, so it is stopped
+// explicitly.
+type contextWatch struct {
+	inner  watch.Interface
+	cancel context.CancelFunc
+	result chan watch.Event
+}
+
+func newContextWatch(ctx context.Context, cancel context.CancelFunc, inner watch.Interface) *contextWatch {
+	c := &contextWatch{
+		inner:  inner,
+		cancel: cancel,
+		result: make(chan watch.Event),
+	}
+
+	go c.run(ctx)
+
+	return c
+}
+
+func (c *contextWatch) ResultChan() <-chan watch.Event {
+	return c.result
+}
+
+func (c *contextWatch) Stop() {
+	c.cancel()
+}
+
+func (c *contextWatch) run(ctx context.Context) {
+	defer close(c.result)
+	defer c.cancel()
+	defer c.inner.Stop()
+
+	for {
+		select {
+		case event, ok := <-c.inner.ResultChan():
+			if !ok {
+				return
+			}
+
+			select {
+			case c.result <- event:
+			case <-ctx.Done():
+				return
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WatchAndReconnect processes all events from the watch and re-establishes
+// it whenever the API server closes it, which happens regularly. The
+// changes missed in between are caught up on by listing all objects again.
+// initial are the objects the watch started after, as they were passed to
+// the watcher. onReconnect, if set, is called for every re-established
+// watch. This runs until ctx is cancelled.
+func WatchAndReconnect(ctx context.Context, log logrus.FieldLogger, client Client, listOpts metav1.ListOptions, gvk schema.GroupVersionKind, initial *unstructured.UnstructuredList, wi watch.Interface, w *watcher.Watcher, transform watcher.TransformFunc, onReconnect func()) {
+	// the objects of other watches can have the same kind (or, once
+	// transformed, all have the same kind), so every watch remembers which
+	// objects it has seen to tell which ones were deleted in between
+	keys := newKeySet(initial)
+
+	for {
+		w.Watch(ctx, newRecordingWatch(ctx, wi, keys))
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Debugf("Watch for %q resources was closed, reconnecting...", gvk.Kind)
+
+		var list *unstructured.UnstructuredList
+		list, wi = reconnect(ctx, log, client, listOpts, gvk)
+		if list == nil {
+			return
+		}
+
+		if transform != nil {
+			watcher.TransformList(ctx, list, transform)
+			wi = watcher.NewTransformWatch(ctx, wi, transform)
+		}
+
+		reconnects := w.Reconnected()
+		if onReconnect != nil {
+			onReconnect()
+		}
+
+		log.Debugf("Re-established watch for %q resources (reconnect #%d).", gvk.Kind, reconnects)
+
+		w.Resync(list, keys.keys())
+		keys = newKeySet(list)
+	}
+}
+
+// keySet is the set of qualified keys of the objects that exist according
+// to a watch.
+type keySet struct {
+	set  map[string]struct{}
+	lock sync.Mutex
+}
+
+func newKeySet(list *unstructured.UnstructuredList) *keySet {
+	k := &keySet{set: map[string]struct{}{}}
+
+	if list != nil {
+		for i := range list.Items {
+			k.set[objectkey.Qualified(&list.Items[i])] = struct{}{}
+		}
+	}
+
+	return k
+}
+
+func (k *keySet) record(event watch.Event) {
+	obj, ok := event.Object.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		k.set[objectkey.Qualified(obj)] = struct{}{}
+	case watch.Deleted:
+		delete(k.set, objectkey.Qualified(obj))
+	}
+}
+
+func (k *keySet) keys() map[string]struct{} {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	keys := make(map[string]struct{}, len(k.set))
+	for key := range k.set {
+		keys[key] = struct{}{}
+	}
+
+	return keys
+}
+
+// recordingWatch passes on the events of a watch and records the objects
+// they refer to in a keySet.
+type recordingWatch struct {
+	inner  watch.Interface
+	keys   *keySet
+	result chan watch.Event
+}
+
+func newRecordingWatch(ctx context.Context, inner watch.Interface, keys *keySet) *recordingWatch {
+	r := &recordingWatch{
+		inner:  inner,
+		keys:   keys,
+		result: make(chan watch.Event),
+	}
+
+	go r.run(ctx)
+
+	return r
+}
+
+func (r *recordingWatch) ResultChan() <-chan watch.Event {
+	return r.result
+}
+
+func (r *recordingWatch) Stop() {
+	r.inner.Stop()
+}
+
+func (r *recordingWatch) run(ctx context.Context) {
+	defer close(r.result)
+
+	for event := range r.inner.ResultChan() {
+		r.keys.record(event)
+
+		select {
+		case r.result <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconnect lists all objects again and starts a new watch after the list,
+// retrying with an increasing delay until it succeeds. If ctx is cancelled
+// in the meantime, nil is returned.
+func reconnect(ctx context.Context, log logrus.FieldLogger, client Client, listOpts metav1.ListOptions, gvk schema.GroupVersionKind) (*unstructured.UnstructuredList, watch.Interface) {
+	delay := minReconnectDelay
+
+	for {
+		list, wi, err := relist(ctx, log, client, listOpts)
+		if err == nil {
+			return list, wi
+		}
+
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+
+		log.Warnf("Failed to re-establish watch for %q resources, retrying in %v: %v", gvk.Kind, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func relist(ctx context.Context, log logrus.FieldLogger, client Client, listOpts metav1.ListOptions) (*unstructured.UnstructuredList, watch.Interface, error) {
+	// always start from the most recent state
+	listOpts.ResourceVersion = ""
+
+	list, err := List(ctx, log, client, listOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+
+	listOpts.ResourceVersion = list.GetResourceVersion()
+
+	wi, err := Watch(ctx, log, client, listOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create watch: %w", err)
+	}
+
+	return list, wi, nil
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// replayTimeout is how long to wait for the first replayed event before
+// assuming that the API server cannot replay the requested changes.
+const replayTimeout = 10 * time.Second
+
+// startReplay tries to watch from a resource version shortly before the
+// last n changes in the list, so that they are shown again. This only works
+// as long as the API server's watch cache still contains these changes.
+// If it does not, a warning is logged and nil is returned, in which case
+// the caller should start watching live. Otherwise the objects that were
+// not changed since then are returned as well.
+func startReplay(ctx context.Context, log logrus.FieldLogger, client dynamic.ResourceInterface, opts metav1.ListOptions, list *unstructured.UnstructuredList, n int) (watch.Interface, *unstructured.UnstructuredList) {
+	start, unchanged, ok := watcher.ReplayStart(list, n)
+	if !ok {
+		log.Warn("Cannot determine where to replay the last changes from, starting live.")
+		return nil, nil
+	}
+
+	opts.ResourceVersion = start
+
+	var wi watch.Interface
+	err := kubeutil.RetryOnThrottling(ctx, log, func() (err error) {
+		wi, err = client.Watch(ctx, opts)
+		return err
+	})
+	if err != nil {
+		log.Warnf("Cannot replay the last changes, starting live: %v", err)
+		return nil, nil
+	}
+
+	// too old resource versions are only reported once the watch has been
+	// established, so the first event has to be checked
+	var first watch.Event
+
+	select {
+	case first, ok = <-wi.ResultChan():
+		if !ok {
+			log.Warn("Cannot replay the last changes, starting live: watch was closed.")
+			return nil, nil
+		}
+
+	case <-time.After(replayTimeout):
+		wi.Stop()
+		log.Warn("Cannot replay the last changes, starting live: no changes were received.")
+		return nil, nil
+
+	case <-ctx.Done():
+		wi.Stop()
+		return nil, nil
+	}
+
+	if first.Type == watch.Error {
+		wi.Stop()
+		log.Warnf("Cannot replay the last changes, starting live: %v", apierrors.FromObject(first.Object))
+		return nil, nil
+	}
+
+	return prependEvent(first, wi), unchanged
+}
+
+// prependEvent returns a watch that emits the event first and then all
+// events from the inner watch.
+func prependEvent(event watch.Event, inner watch.Interface) watch.Interface {
+	result := make(chan watch.Event)
+	proxy := watch.NewProxyWatcher(result)
+
+	go func() {
+		defer close(result)
+		defer inner.Stop()
+
+		next := event
+
+		for {
+			select {
+			case result <- next:
+			case <-proxy.StopChan():
+				return
+			}
+
+			var ok bool
+
+			select {
+			case next, ok = <-inner.ResultChan():
+				if !ok {
+					return
+				}
+			case <-proxy.StopChan():
+				return
+			}
+		}
+	}()
+
+	return proxy
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WatchOptions configure how the objects of a single kind are listed and
+// watched.
+type WatchOptions struct {
+	// ListOptions are passed to the API server when listing and watching.
+	ListOptions metav1.ListOptions
+
+	// Transform, if set, is applied to all objects before they are
+	// processed.
+	Transform watcher.TransformFunc
+
+	// Snapshot only processes the existing objects, without watching for
+	// changes.
+	Snapshot bool
+
+	// OnlyChanges hides the existing objects, so that only later changes
+	// are processed.
+	OnlyChanges bool
+
+	// SortInitial is the order in which the existing objects are
+	// processed, see watcher.SortObjects.
+	SortInitial string
+
+	// PollInterval, if set, lists the objects in this interval instead of
+	// watching them, for kinds that cannot be watched.
+	PollInterval time.Duration
+
+	// Last, if set, replays roughly the last n changes instead of the
+	// existing objects, as far as the API server's watch cache allows.
+	Last int
+
+	// OnSnapshot, if set, is called with the number of existing objects
+	// before they are processed.
+	OnSnapshot func(count int)
+
+	// OnReconnect, if set, is called for every re-established watch.
+	OnReconnect func()
+}
+
+// StartWatch lists the objects of a kind and starts to watch them for
+// changes, feeding all events into w. The events are processed in a
+// function that is handed to run, which is expected to call it in its own
+// goroutine. In snapshot mode, the objects are processed before StartWatch
+// returns and run is not used.
+func StartWatch(ctx context.Context, log logrus.FieldLogger, client Client, gvk schema.GroupVersionKind, w *watcher.Watcher, opt *WatchOptions, run func(func())) error {
+	listOpts := opt.ListOptions
+	transform := opt.Transform
+
+	if opt.Snapshot {
+		list, err := List(ctx, log, client, listOpts)
+		if err != nil {
+			return fmt.Errorf("failed to list %q resources: %w", gvk.Kind, err)
+		}
+
+		watcher.SortObjects(list.Items, opt.SortInitial)
+		if transform != nil {
+			watcher.TransformList(ctx, list, transform)
+		}
+
+		if opt.OnSnapshot != nil {
+			opt.OnSnapshot(len(list.Items))
+		}
+
+		w.Found(list)
+		w.Snapshot(list)
+
+		return nil
+	}
+
+	if opt.PollInterval > 0 {
+		if opt.OnlyChanges {
+			list, err := List(ctx, log, client, listOpts)
+			if err != nil {
+				return fmt.Errorf("failed to list %q resources: %w", gvk.Kind, err)
+			}
+
+			w.Found(list)
+
+			// the poller compares the untransformed objects
+			var wi watch.Interface = watcher.NewSeededPoller(ctx, client, listOpts, opt.PollInterval, list.DeepCopy(), log)
+			if transform != nil {
+				watcher.TransformList(ctx, list, transform)
+				wi = watcher.NewTransformWatch(ctx, wi, transform)
+			}
+
+			run(func() {
+				w.Prime(list)
+				w.Watch(ctx, wi)
+			})
+
+			return nil
+		}
+
+		var wi watch.Interface = watcher.NewPoller(ctx, client, listOpts, opt.PollInterval, log)
+		if transform != nil {
+			wi = watcher.NewTransformWatch(ctx, wi, transform)
+		}
+
+		run(func() {
+			w.Watch(ctx, wi)
+		})
+
+		return nil
+	}
+
+	// list all existing objects first and then watch for changes after the
+	// list's resource version; this clearly separates the initial state from
+	// the following changes
+	list, err := List(ctx, log, client, listOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list %q resources: %w", gvk.Kind, err)
+	}
+
+	watcher.SortObjects(list.Items, opt.SortInitial)
+	w.Found(list)
+
+	listOpts.ResourceVersion = list.GetResourceVersion()
+
+	if opt.Last > 0 {
+		if replay, unchanged := startReplay(ctx, log, client, listOpts, list, opt.Last); replay != nil {
+			var wi watch.Interface = replay
+			if transform != nil {
+				watcher.TransformList(ctx, unchanged, transform)
+				wi = watcher.NewTransformWatch(ctx, wi, transform)
+			}
+
+			run(func() {
+				w.Prime(unchanged)
+				WatchAndReconnect(ctx, log, client, listOpts, gvk, unchanged, wi, w, transform, opt.OnReconnect)
+			})
+
+			return nil
+		}
+	}
+
+	wi, err := Watch(ctx, log, client, listOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create watch for %q resources: %w", gvk.Kind, err)
+	}
+
+	if transform != nil {
+		watcher.TransformList(ctx, list, transform)
+		wi = watcher.NewTransformWatch(ctx, wi, transform)
+	}
+
+	if opt.OnlyChanges {
+		run(func() {
+			w.Prime(list)
+			WatchAndReconnect(ctx, log, client, listOpts, gvk, list, wi, w, transform, opt.OnReconnect)
+		})
+
+		return nil
+	}
+
+	if opt.OnSnapshot != nil {
+		opt.OnSnapshot(len(list.Items))
+	}
+
+	run(func() {
+		w.Snapshot(list)
+		WatchAndReconnect(ctx, log, client, listOpts, gvk, list, wi, w, transform, opt.OnReconnect)
+	})
+
+	return nil
+}
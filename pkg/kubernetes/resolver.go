@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	memory "k8s.io/client-go/discovery/cached"
@@ -96,6 +98,99 @@ func (r *Resolver) ResourceInterfaceFor(gvk schema.GroupVersionKind) (dynamic.Re
 	return r.dynamicClient.Resource(mapping.Resource), nil
 }
 
+// NamespacedResourceInterfaceFor returns a resource interface scoped to the
+// given namespace, if the kind is namespaced. For cluster-scoped kinds, the
+// namespace is ignored. An empty namespace falls back to "default".
+func (r *Resolver) NamespacedResourceInterfaceFor(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine mapping: %w", err)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return r.dynamicClient.Resource(mapping.Resource), nil
+	}
+
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	return r.dynamicClient.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// IsNamespaced returns whether the given kind is namespaced.
+func (r *Resolver) IsNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, fmt.Errorf("failed to determine mapping: %w", err)
+	}
+
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// SupportsWatch returns whether the API server allows to watch the given
+// kind. Most aggregated APIs (like metrics.k8s.io) only support listing.
+func (r *Resolver) SupportsWatch(gvk schema.GroupVersionKind) (bool, error) {
+	resource, err := r.apiResourceFor(gvk, "")
+	if err != nil {
+		return false, err
+	}
+
+	for _, verb := range resource.Verbs {
+		if verb == "watch" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SupportsScale returns whether the given kind has a scale subresource,
+// like Deployments or StatefulSets.
+func (r *Resolver) SupportsScale(gvk schema.GroupVersionKind) (bool, error) {
+	_, err := r.apiResourceFor(gvk, "scale")
+	if err != nil {
+		if errors.Is(err, errResourceNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+var errResourceNotFound = errors.New("resource not found in discovery")
+
+// apiResourceFor returns the discovery information for the given kind or,
+// if subresource is not empty, one of its subresources.
+func (r *Resolver) apiResourceFor(gvk schema.GroupVersionKind, subresource string) (*metav1.APIResource, error) {
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine mapping: %w", err)
+	}
+
+	gvr := mapping.Resource
+
+	resources, err := r.cache.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+
+	name := gvr.Resource
+	if subresource != "" {
+		name = fmt.Sprintf("%s/%s", name, subresource)
+	}
+
+	for i, resource := range resources.APIResources {
+		if resource.Name == name {
+			return &resources.APIResources[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s in %s: %w", name, gvr.GroupVersion().String(), errResourceNotFound)
+}
+
 func (r *Resolver) InvalidateCache() {
 	r.cache.Invalidate()
 }
@@ -0,0 +1,50 @@
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultThrottlingDelay is used when the API server throttles a request,
+// but does not say when to retry.
+const defaultThrottlingDelay = 5 * time.Second
+
+// RetryOnThrottling calls fn until it does not fail because the API server
+// is throttling requests (HTTP 429 Too Many Requests), waiting as long as the
+// server's Retry-After header demands in between. All other errors are
+// returned as-is.
+func RetryOnThrottling(ctx context.Context, log logrus.FieldLogger, fn func() error) error {
+	for {
+		err := fn()
+
+		delay, throttled := ThrottlingDelay(err)
+		if !throttled {
+			return err
+		}
+
+		log.Warnf("Throttled by the API server, retrying in %v.", delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// ThrottlingDelay returns how long to wait before retrying, if the error
+// is a rate-limit response.
+func ThrottlingDelay(err error) (time.Duration, bool) {
+	if err == nil || !apierrors.IsTooManyRequests(err) {
+		return 0, false
+	}
+
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	return defaultThrottlingDelay, true
+}
@@ -0,0 +1,235 @@
+package managedfields
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.xrstf.de/stalk/pkg/maputil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// serverManagedPaths are always maintained by the API server and never
+// show up in any managedFields entry.
+var serverManagedPaths = []maputil.Path{
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "selfLink"},
+	{"metadata", "uid"},
+}
+
+// Owners returns the names of all field managers that own the value at
+// the given path.
+func Owners(obj *unstructured.Unstructured, path maputil.Path) []string {
+	owners := []string{}
+
+	for _, entry := range obj.GetManagedFields() {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		if owns(fields, obj.Object, path) {
+			owners = append(owners, entry.Manager)
+		}
+	}
+
+	return owners
+}
+
+// UnownedChanges returns all paths that have been set or changed between
+// both objects, but are not owned by any field manager. This usually means
+// they were set by the API server, e.g. by defaulting or by a conversion
+// webhook, instead of a client.
+func UnownedChanges(oldObj, newObj *unstructured.Unstructured) []maputil.Path {
+	// objects without any managed fields would make every change look suspicious
+	if len(newObj.GetManagedFields()) == 0 {
+		return nil
+	}
+
+	unowned := []maputil.Path{}
+
+	for _, change := range maputil.Compare(oldObj.Object, newObj.Object) {
+		// removed fields cannot be owned by anyone anymore
+		if change.NewValue == nil || isServerManaged(change.Path) {
+			continue
+		}
+
+		if len(Owners(newObj, change.Path)) == 0 {
+			unowned = append(unowned, change.Path)
+		}
+	}
+
+	return unowned
+}
+
+// Changers returns the names of the field managers that most likely made
+// the change between both objects. These are the managers whose entries
+// were added or updated. If no entry changed, the owners of the changed
+// fields are returned instead.
+func Changers(oldObj, newObj *unstructured.Unstructured) []string {
+	changers := []string{}
+	seen := map[string]struct{}{}
+
+	add := func(manager string) {
+		if _, ok := seen[manager]; !ok {
+			seen[manager] = struct{}{}
+			changers = append(changers, manager)
+		}
+	}
+
+	previous := map[string]metav1.ManagedFieldsEntry{}
+	for _, entry := range oldObj.GetManagedFields() {
+		previous[entryKey(entry)] = entry
+	}
+
+	for _, entry := range newObj.GetManagedFields() {
+		old, exists := previous[entryKey(entry)]
+		if !exists || !sameEntry(old, entry) {
+			add(entry.Manager)
+		}
+	}
+
+	if len(changers) > 0 {
+		return changers
+	}
+
+	for _, change := range maputil.Compare(oldObj.Object, newObj.Object) {
+		if change.NewValue == nil || isServerManaged(change.Path) {
+			continue
+		}
+
+		for _, owner := range Owners(newObj, change.Path) {
+			add(owner)
+		}
+	}
+
+	return changers
+}
+
+// entryKey identifies a managedFields entry; the same manager can have
+// separate entries for updates and applies and for each subresource.
+func entryKey(entry metav1.ManagedFieldsEntry) string {
+	return fmt.Sprintf("%s/%s/%s", entry.Manager, entry.Operation, entry.Subresource)
+}
+
+func sameEntry(a, b metav1.ManagedFieldsEntry) bool {
+	if !a.Time.Equal(b.Time) {
+		return false
+	}
+
+	if a.FieldsV1 == nil || b.FieldsV1 == nil {
+		return a.FieldsV1 == b.FieldsV1
+	}
+
+	return string(a.FieldsV1.Raw) == string(b.FieldsV1.Raw)
+}
+
+func isServerManaged(path maputil.Path) bool {
+	for _, serverPath := range serverManagedPaths {
+		if path.HasPrefix(serverPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// owns walks along the path through the fieldsV1 structure and the object
+// at the same time. See
+// https://kubernetes.io/docs/reference/using-api/server-side-apply/#field-management
+// for the format.
+func owns(fields map[string]interface{}, value interface{}, path maputil.Path) bool {
+	// an empty set of fields means the entire value (including all of its
+	// children) is owned
+	if len(path) == 0 || len(fields) == 0 {
+		return true
+	}
+
+	head := path.Head()
+
+	if index, isIndex := maputil.ParseIndexSegment(head); isIndex {
+		list, ok := value.([]interface{})
+		if !ok || index >= len(list) {
+			return false
+		}
+
+		element := list[index]
+
+		for key, child := range fields {
+			if !matchesElement(key, index, element) {
+				continue
+			}
+
+			childFields, _ := child.(map[string]interface{})
+
+			return owns(childFields, element, path.Tail())
+		}
+
+		return false
+	}
+
+	child, exists := fields["f:"+head]
+	if !exists {
+		return false
+	}
+
+	childFields, _ := child.(map[string]interface{})
+	valueMap, _ := value.(map[string]interface{})
+
+	return owns(childFields, valueMap[head], path.Tail())
+}
+
+// matchesElement checks whether a fieldsV1 key like `k:{"name":"foo"}`,
+// `v:"foo"` or `i:3` refers to the given list element.
+func matchesElement(key string, index int, element interface{}) bool {
+	switch {
+	case strings.HasPrefix(key, "i:"):
+		i, err := strconv.Atoi(strings.TrimPrefix(key, "i:"))
+		return err == nil && i == index
+
+	case strings.HasPrefix(key, "v:"):
+		var value interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "v:")), &value); err != nil {
+			return false
+		}
+
+		return sameValue(value, element)
+
+	case strings.HasPrefix(key, "k:"):
+		var keyFields map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &keyFields); err != nil {
+			return false
+		}
+
+		elementMap, ok := element.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for k, v := range keyFields {
+			if !sameValue(v, elementMap[k]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// sameValue compares values regardless of their numeric types (JSON numbers
+// decode as float64, but objects contain int64s).
+func sameValue(a, b interface{}) bool {
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
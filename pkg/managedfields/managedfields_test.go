@@ -0,0 +1,131 @@
+package managedfields
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/maputil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const deployment = `{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment",
+	"metadata": {
+		"name": "nginx",
+		"managedFields": [{
+			"manager": "kubectl",
+			"operation": "Update",
+			"fieldsType": "FieldsV1",
+			"fieldsV1": {
+				"f:spec": {
+					"f:replicas": {},
+					"f:template": {
+						"f:spec": {
+							"f:containers": {
+								"k:{\"name\":\"nginx\"}": {
+									".": {},
+									"f:image": {},
+									"f:name": {}
+								}
+							}
+						}
+					}
+				}
+			}
+		}]
+	},
+	"spec": {
+		"replicas": 3,
+		"template": {
+			"spec": {
+				"containers": [{
+					"name": "nginx",
+					"image": "nginx:1.23",
+					"imagePullPolicy": "IfNotPresent"
+				}]
+			}
+		}
+	}
+}`
+
+func TestOwners(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON([]byte(deployment)); err != nil {
+		t.Fatalf("invalid testcase: %v", err)
+	}
+
+	testcases := []struct {
+		path  maputil.Path
+		owned bool
+	}{
+		{
+			path:  maputil.Path{"spec", "replicas"},
+			owned: true,
+		},
+		{
+			path:  maputil.Path{"spec", "template", "spec", "containers", "[0]", "image"},
+			owned: true,
+		},
+		{
+			path:  maputil.Path{"spec", "template", "spec", "containers", "[0]", "imagePullPolicy"},
+			owned: false,
+		},
+		{
+			path:  maputil.Path{"spec", "paused"},
+			owned: false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.path.String(), func(t *testing.T) {
+			owners := Owners(obj, testcase.path)
+
+			if owned := len(owners) > 0; owned != testcase.owned {
+				t.Errorf("Expected owned=%v, but got owners %v.", testcase.owned, owners)
+			}
+		})
+	}
+}
+
+func TestChangers(t *testing.T) {
+	parse := func(data string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON([]byte(data)); err != nil {
+			t.Fatalf("invalid testcase: %v", err)
+		}
+
+		return obj
+	}
+
+	oldObj := parse(deployment)
+
+	// the entry of the manager that made the change is updated
+	newObj := parse(deployment)
+	fields := newObj.GetManagedFields()
+	fields[0].Time = &metav1.Time{Time: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)}
+	newObj.SetManagedFields(append(fields, metav1.ManagedFieldsEntry{
+		Manager:     "kube-controller-manager",
+		Operation:   metav1.ManagedFieldsOperationUpdate,
+		Subresource: "status",
+	}))
+
+	expected := []string{"kubectl", "kube-controller-manager"}
+	if actual := Changers(oldObj, newObj); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, but got %v.", expected, actual)
+	}
+
+	// without updated entries, the owners of the changed fields are used
+	newObj = parse(deployment)
+	if err := unstructured.SetNestedField(newObj.Object, int64(5), "spec", "replicas"); err != nil {
+		t.Fatalf("invalid testcase: %v", err)
+	}
+
+	expected = []string{"kubectl"}
+	if actual := Changers(oldObj, newObj); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, but got %v.", expected, actual)
+	}
+}
@@ -0,0 +1,91 @@
+package maputil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change describes a value that differs between two objects. If an entire
+// subtree was added or removed, only a single change for its root is
+// reported. Missing values are nil.
+type Change struct {
+	Path     Path
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// Compare returns all differences between a and b, sorted by path. Array
+// elements are compared by their index.
+func Compare(a, b interface{}) []Change {
+	return compare(Path{}, a, b)
+}
+
+func compare(path Path, a, b interface{}) []Change {
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+
+	if aIsMap && bIsMap {
+		keys := map[string]struct{}{}
+		for key := range aMap {
+			keys[key] = struct{}{}
+		}
+		for key := range bMap {
+			keys[key] = struct{}{}
+		}
+
+		sortedKeys := []string{}
+		for key := range keys {
+			sortedKeys = append(sortedKeys, key)
+		}
+		sort.Strings(sortedKeys)
+
+		changes := []Change{}
+		for _, key := range sortedKeys {
+			changes = append(changes, compare(path.Append(key), aMap[key], bMap[key])...)
+		}
+
+		return changes
+	}
+
+	aSlice, aIsSlice := a.([]interface{})
+	bSlice, bIsSlice := b.([]interface{})
+
+	if aIsSlice && bIsSlice {
+		length := len(aSlice)
+		if len(bSlice) > length {
+			length = len(bSlice)
+		}
+
+		changes := []Change{}
+		for i := 0; i < length; i++ {
+			var aValue, bValue interface{}
+
+			if i < len(aSlice) {
+				aValue = aSlice[i]
+			}
+			if i < len(bSlice) {
+				bValue = bSlice[i]
+			}
+
+			changes = append(changes, compare(path.Append(IndexSegment(i)), aValue, bValue)...)
+		}
+
+		return changes
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return []Change{}
+	}
+
+	return []Change{{
+		Path:     path,
+		OldValue: a,
+		NewValue: b,
+	}}
+}
+
+// IndexSegment returns the path segment for the i-th array element.
+func IndexSegment(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
@@ -5,42 +5,154 @@ import (
 	"fmt"
 )
 
+// RemovePath returns the object without the value at the given path. The
+// path can contain Wildcard segments to match all keys of a map and array
+// segments to match one or all (ArrayWildcard) items of an array; segments
+// that do not fit the value, like an array segment on a map, match nothing.
+// Maps that become empty are removed, array items are not, to keep the
+// indices of the remaining items intact. obj itself is not modified.
 func RemovePath(obj map[string]interface{}, path Path) (map[string]interface{}, error) {
 	if len(path) == 0 {
 		return obj, errors.New("path cannot be empty")
 	}
 
+	if result, changed := removeFromMap(obj, path); changed {
+		return result, nil
+	}
+
+	return obj, nil
+}
+
+func removePath(value interface{}, path Path) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return removeFromMap(v, path)
+	case []interface{}:
+		return removeFromArray(v, path)
+	default:
+		return value, false
+	}
+}
+
+func removeFromMap(obj map[string]interface{}, path Path) (map[string]interface{}, bool) {
 	head := path.Head()
 	tail := path.Tail()
 
-	if len(tail) == 0 {
-		delete(obj, head)
-		return obj, nil
+	if isArraySegment(head) {
+		return obj, false
 	}
 
-	childValue, exists := obj[head]
-	if !exists {
-		return obj, nil
+	keys := []string{head}
+	if head == Wildcard {
+		keys = make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
 	}
 
-	childObj, ok := childValue.(map[string]interface{})
-	if !ok {
-		return obj, nil
+	var result map[string]interface{}
+
+	for _, key := range keys {
+		value, exists := obj[key]
+		if !exists {
+			continue
+		}
+
+		remove := len(tail) == 0
+
+		if !remove {
+			modified, changed := removePath(value, tail)
+			if !changed {
+				continue
+			}
+
+			value = modified
+
+			// specifically, we do not want `"foo":{}`
+			if modifiedMap, ok := modified.(map[string]interface{}); ok && len(modifiedMap) == 0 {
+				remove = true
+			}
+			if modifiedArray, ok := modified.([]interface{}); ok && len(modifiedArray) == 0 {
+				remove = true
+			}
+		}
+
+		if result == nil {
+			result = copyMap(obj)
+		}
+
+		if remove {
+			delete(result, key)
+		} else {
+			result[key] = value
+		}
 	}
 
-	modifiedChildObj, err := RemovePath(childObj, tail)
-	if err != nil {
-		return obj, errors.New("failed to remove child")
+	if result == nil {
+		return obj, false
 	}
 
-	if len(modifiedChildObj) == 0 {
-		delete(obj, head)
-		return obj, nil
+	return result, true
+}
+
+func removeFromArray(items []interface{}, path Path) ([]interface{}, bool) {
+	head := path.Head()
+	tail := path.Tail()
+
+	index, isIndex := ParseIndexSegment(head)
+	if !isIndex && head != ArrayWildcard {
+		return items, false
 	}
 
-	obj[head] = modifiedChildObj
+	matches := func(i int) bool {
+		return !isIndex || i == index
+	}
 
-	return obj, nil
+	if len(tail) == 0 {
+		result := []interface{}{}
+		for i, item := range items {
+			if !matches(i) {
+				result = append(result, item)
+			}
+		}
+
+		return result, len(result) != len(items)
+	}
+
+	var result []interface{}
+
+	for i, item := range items {
+		if !matches(i) {
+			continue
+		}
+
+		modified, changed := removePath(item, tail)
+		if !changed {
+			continue
+		}
+
+		if result == nil {
+			result = make([]interface{}, len(items))
+			copy(result, items)
+		}
+
+		result[i] = modified
+	}
+
+	if result == nil {
+		return items, false
+	}
+
+	return result, true
+}
+
+func copyMap(obj map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		result[key] = value
+	}
+
+	return result
 }
 
 func PruneObject(obj map[string]interface{}, paths []Path) (map[string]interface{}, error) {
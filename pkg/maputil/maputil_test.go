@@ -48,6 +48,51 @@ func TestRemovePath(t *testing.T) {
 			path:     `foo.bar`,
 			expected: `{"foo":[1,2,3]}`,
 		},
+		{
+			input:    `{"foo":{"a":1,"b":{"c":2,"d":3}}}`,
+			path:     `foo.*`,
+			expected: `{}`,
+		},
+		{
+			input:    `{"foo":{"a":1,"b":{"c":2,"d":3}},"bar":{"c":4}}`,
+			path:     `*.b.c`,
+			expected: `{"bar":{"c":4},"foo":{"a":1,"b":{"d":3}}}`,
+		},
+		{
+			input:    `{"foo":[{"a":1,"b":2},{"a":3},{"b":4}]}`,
+			path:     `foo[*].b`,
+			expected: `{"foo":[{"a":1},{"a":3},{}]}`,
+		},
+		{
+			input:    `{"foo":[{"a":1,"b":2},{"a":3,"b":4}]}`,
+			path:     `foo[1].b`,
+			expected: `{"foo":[{"a":1,"b":2},{"a":3}]}`,
+		},
+		{
+			input:    `{"foo":[1,2,3]}`,
+			path:     `foo[1]`,
+			expected: `{"foo":[1,3]}`,
+		},
+		{
+			input:    `{"foo":[1,2,3]}`,
+			path:     `foo[*]`,
+			expected: `{}`,
+		},
+		{
+			input:    `{"foo":[1,2,3]}`,
+			path:     `foo[7]`,
+			expected: `{"foo":[1,2,3]}`,
+		},
+		{
+			input:    `{"foo":[[{"a":1,"b":2}]]}`,
+			path:     `foo[*][*].a`,
+			expected: `{"foo":[[{"b":2}]]}`,
+		},
+		{
+			input:    `{"foo":{"bar":12}}`,
+			path:     `foo[*].bar`,
+			expected: `{"foo":{"bar":12}}`,
+		},
 	}
 
 	for _, testcase := range testcases {
@@ -76,6 +121,94 @@ func TestRemovePath(t *testing.T) {
 	}
 }
 
+func TestRemovePathDoesNotModifyInput(t *testing.T) {
+	input := `{"metadata":{"annotations":{"a":"b"}},"spec":{"containers":[{"name":"a","resources":{}},{"name":"b"}]}}`
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &obj); err != nil {
+		t.Fatalf("invalid testcase: %v", err)
+	}
+
+	for _, path := range []string{"spec.containers[*].resources", "spec.containers[1]", "metadata.annotations.*"} {
+		p, err := ParsePath(path)
+		if err != nil {
+			t.Fatalf("invalid path: %v", err)
+		}
+
+		if _, err := RemovePath(obj, p); err != nil {
+			t.Fatalf("failed to remove path: %v", err)
+		}
+	}
+
+	if encoded, _ := json.Marshal(obj); string(encoded) != input {
+		t.Errorf("Expected input to remain %q, but got %q.", input, string(encoded))
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	testcases := []struct {
+		path     string
+		expected string
+		literal  bool
+		invalid  bool
+	}{
+		{
+			path:     "metadata.name",
+			expected: "[metadata name]",
+			literal:  true,
+		},
+		{
+			path:     "spec.containers[*].resources",
+			expected: "[spec containers [*] resources]",
+		},
+		{
+			path:     "items[0][*]",
+			expected: "[items [0] [*]]",
+		},
+		{
+			path:     "metadata.annotations.*",
+			expected: "[metadata annotations *]",
+		},
+		{
+			path:    "spec.containers[name]",
+			invalid: true,
+		},
+		{
+			path:    "spec.containers[0",
+			invalid: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.path, func(t *testing.T) {
+			p, err := ParsePath(testcase.path)
+			if testcase.invalid {
+				if err == nil {
+					t.Errorf("Expected an error, but got %v.", p)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse path: %v", err)
+			}
+
+			if actual := fmt.Sprint([]string(p)); actual != testcase.expected {
+				t.Errorf("Expected %s, but got %s.", testcase.expected, actual)
+			}
+
+			if actual := p.String(); actual != testcase.path {
+				t.Errorf("Expected %q, but got %q.", testcase.path, actual)
+			}
+
+			if literal := p.IsLiteral(); literal != testcase.literal {
+				t.Errorf("Expected literal=%v, but got %v.", testcase.literal, literal)
+			}
+		})
+	}
+}
+
 func TestPruneObject(t *testing.T) {
 	testcases := []struct {
 		input    string
@@ -117,6 +250,16 @@ func TestPruneObject(t *testing.T) {
 			paths:    []string{`metadata.name`, `metadata.namespace`},
 			expected: `{"metadata":{"name":"name","namespace":"ns"}}`,
 		},
+		{
+			input:    `{"metadata":{"name":"name","namespace":"ns"}}`,
+			paths:    []string{`metadata.name`, `spec.replicas`},
+			expected: `{"metadata":{"name":"name"}}`,
+		},
+		{
+			input:    `{"metadata":{"name":"name","namespace":"ns"}}`,
+			paths:    []string{`spec.replicas`},
+			expected: `{}`,
+		},
 		{
 			input:    `{"metadata":{"name":"name","namespace":"ns"},"spec":{"labels":["myvalue"],"replicas":1}}`,
 			paths:    []string{`metadata.name`, `spec`},
@@ -154,3 +297,58 @@ func TestPruneObject(t *testing.T) {
 		})
 	}
 }
+
+func TestCompare(t *testing.T) {
+	testcases := []struct {
+		a        string
+		b        string
+		expected []string
+	}{
+		{
+			a:        `{"foo":"bar"}`,
+			b:        `{"foo":"bar"}`,
+			expected: []string{},
+		},
+		{
+			a:        `{"foo":"bar"}`,
+			b:        `{"foo":"baz"}`,
+			expected: []string{"foo"},
+		},
+		{
+			a:        `{"foo":{"bar":1,"baz":2}}`,
+			b:        `{"foo":{"bar":1,"baz":3,"new":4}}`,
+			expected: []string{"foo.baz", "foo.new"},
+		},
+		{
+			a:        `{"foo":[{"name":"a"},{"name":"b"}]}`,
+			b:        `{"foo":[{"name":"a"},{"name":"c"},{"name":"d"}]}`,
+			expected: []string{"foo[1].name", "foo[2]"},
+		},
+		{
+			a:        `{"foo":{"bar":1}}`,
+			b:        `{"foo":"scalar"}`,
+			expected: []string{"foo"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(fmt.Sprintf("%s vs. %s", testcase.a, testcase.b), func(t *testing.T) {
+			var a, b map[string]interface{}
+			if err := json.Unmarshal([]byte(testcase.a), &a); err != nil {
+				t.Fatalf("invalid testcase: %v", err)
+			}
+			if err := json.Unmarshal([]byte(testcase.b), &b); err != nil {
+				t.Fatalf("invalid testcase: %v", err)
+			}
+
+			paths := []string{}
+			for _, change := range Compare(a, b) {
+				paths = append(paths, change.Path.String())
+			}
+
+			if toJSON(paths) != toJSON(testcase.expected) {
+				t.Errorf("Expected %v, but got %v.", testcase.expected, paths)
+			}
+		})
+	}
+}
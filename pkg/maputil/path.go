@@ -2,11 +2,24 @@ package maputil
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
+const (
+	// Wildcard is a path segment that matches all keys of a map.
+	Wildcard = "*"
+
+	// ArrayWildcard is a path segment that matches all items of an array.
+	ArrayWildcard = "[*]"
+)
+
 type Path []string
 
+// ParsePath parses a dotted path like "spec.containers[*].resources" or
+// "metadata.annotations.*". Array segments are split from the key they
+// follow.
 func ParsePath(path string) (Path, error) {
 	if path == "" {
 		return nil, errors.New("path cannot be empty")
@@ -20,7 +33,12 @@ func ParsePath(path string) (Path, error) {
 			continue
 		}
 
-		validParts = append(validParts, part)
+		segments, err := splitArraySegments(part)
+		if err != nil {
+			return nil, err
+		}
+
+		validParts = append(validParts, segments...)
 	}
 
 	if len(validParts) == 0 {
@@ -30,6 +48,38 @@ func ParsePath(path string) (Path, error) {
 	return Path(validParts), nil
 }
 
+// splitArraySegments splits a part like "containers[0][*]" into
+// "containers", "[0]" and "[*]".
+func splitArraySegments(part string) ([]string, error) {
+	start := strings.Index(part, "[")
+	if start < 0 {
+		return []string{part}, nil
+	}
+
+	segments := []string{}
+	if start > 0 {
+		segments = append(segments, part[:start])
+	}
+
+	rest := part[start:]
+	for rest != "" {
+		end := strings.Index(rest, "]")
+		if !strings.HasPrefix(rest, "[") || end < 0 {
+			return nil, fmt.Errorf("invalid array segment in %q", part)
+		}
+
+		segment := rest[:end+1]
+		if _, isIndex := ParseIndexSegment(segment); !isIndex && segment != ArrayWildcard {
+			return nil, fmt.Errorf("invalid array segment %q, must be an index or [*]", segment)
+		}
+
+		segments = append(segments, segment)
+		rest = rest[end+1:]
+	}
+
+	return segments, nil
+}
+
 func (p Path) Head() string {
 	if len(p) == 0 {
 		return ""
@@ -45,3 +95,74 @@ func (p Path) Tail() Path {
 
 	return p[1:]
 }
+
+// Append returns a new path with the segment added at the end; p is not
+// modified.
+func (p Path) Append(segment string) Path {
+	result := make(Path, len(p), len(p)+1)
+	copy(result, p)
+
+	return append(result, segment)
+}
+
+// String returns the path in its dotted notation, e.g. "spec.containers[0].image".
+func (p Path) String() string {
+	var builder strings.Builder
+
+	for i, segment := range p {
+		if !isArraySegment(segment) && i > 0 {
+			builder.WriteString(".")
+		}
+
+		builder.WriteString(segment)
+	}
+
+	return builder.String()
+}
+
+// ParseIndexSegment returns the index of an array segment like "[3]".
+func ParseIndexSegment(segment string) (int, bool) {
+	if !strings.HasPrefix(segment, "[") || !strings.HasSuffix(segment, "]") {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(segment[1 : len(segment)-1])
+	if err != nil {
+		return 0, false
+	}
+
+	return index, true
+}
+
+func isArraySegment(segment string) bool {
+	_, isIndex := ParseIndexSegment(segment)
+
+	return isIndex || segment == ArrayWildcard
+}
+
+// IsLiteral returns true if the path contains neither Wildcard nor array
+// segments, i.e. it points to exactly one field.
+func (p Path) IsLiteral() bool {
+	for _, segment := range p {
+		if segment == Wildcard || isArraySegment(segment) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasPrefix returns true if p starts with all segments of prefix.
+func (p Path) HasPrefix(prefix Path) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
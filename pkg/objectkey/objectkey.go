@@ -0,0 +1,27 @@
+// Package objectkey generates the keys that identify objects throughout
+// stalk. All caches and outputs must use these functions, so that objects
+// with the same name in different namespaces (or of different kinds) can
+// never be confused.
+package objectkey
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Of returns "namespace/name" or, for cluster-scoped objects, just "name".
+// The key is only unique within a single kind.
+func Of(obj *unstructured.Unstructured) string {
+	if ns := obj.GetNamespace(); ns != "" {
+		return fmt.Sprintf("%s/%s", ns, obj.GetName())
+	}
+
+	return obj.GetName()
+}
+
+// Qualified returns a key that includes the object's kind and is therefore
+// unique across all kinds.
+func Qualified(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", obj.GroupVersionKind().String(), Of(obj))
+}
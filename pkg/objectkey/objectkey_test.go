@@ -0,0 +1,65 @@
+package objectkey
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+
+	return obj
+}
+
+func TestOf(t *testing.T) {
+	testcases := []struct {
+		obj      *unstructured.Unstructured
+		expected string
+	}{
+		{
+			obj:      newObject("v1", "ConfigMap", "default", "foo"),
+			expected: "default/foo",
+		},
+		{
+			obj:      newObject("v1", "Namespace", "", "foo"),
+			expected: "foo",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.expected, func(t *testing.T) {
+			if key := Of(testcase.obj); key != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, key)
+			}
+		})
+	}
+}
+
+func TestNoCollisions(t *testing.T) {
+	objects := []*unstructured.Unstructured{
+		newObject("v1", "ConfigMap", "default", "foo"),
+		newObject("v1", "ConfigMap", "kube-system", "foo"),
+		newObject("v1", "ConfigMap", "default", "bar"),
+		newObject("v1", "Secret", "default", "foo"),
+		newObject("apps/v1", "Deployment", "default", "foo"),
+		newObject("v1", "Namespace", "", "foo"),
+		newObject("v1", "Namespace", "", "default"),
+	}
+
+	seen := map[string]*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		key := Qualified(obj)
+
+		if other, exists := seen[key]; exists {
+			t.Errorf("Key %q for %s %s collides with %s %s.", key, obj.GetKind(), Of(obj), other.GetKind(), Of(other))
+		}
+
+		seen[key] = obj
+	}
+}
@@ -0,0 +1,264 @@
+// Package otlp exports events as OpenTelemetry log records, using the
+// JSON encoding of OTLP over HTTP. It only relies on the standard library,
+// so that stalk does not have to pull in the OpenTelemetry SDK.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// logsPath is appended to endpoints that do not specify a path.
+	logsPath = "/v1/logs"
+
+	// queueSize is the number of records that can be waiting for export
+	// before Export blocks.
+	queueSize = 1000
+
+	// batchSize and batchDelay limit how many records are sent at once and
+	// how long a record waits for others before it is sent.
+	batchSize  = 100
+	batchDelay = 1 * time.Second
+
+	// requestTimeout limits how long a single export may take.
+	requestTimeout = 10 * time.Second
+
+	scopeName = "go.xrstf.de/stalk"
+
+	// severityInfo is the OTLP severity number for INFO.
+	severityInfo = 9
+)
+
+// Exporter sends a log record for every event to an OTLP/HTTP endpoint,
+// e.g. an OpenTelemetry Collector. Records are batched and exported in the
+// background; batches that cannot be delivered are dropped.
+type Exporter struct {
+	endpoint string
+	resource resource
+	client   *http.Client
+	log      logrus.FieldLogger
+	queue    chan logRecord
+	done     chan struct{}
+}
+
+// New returns an exporter for the given endpoint, e.g.
+// "http://localhost:4318". If the endpoint has no path, "/v1/logs" is used.
+func New(endpoint string, log logrus.FieldLogger) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q, must be http or https", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, errors.New("no host given")
+	}
+
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = logsPath
+	}
+
+	e := &Exporter{
+		endpoint: u.String(),
+		resource: instanceResource(),
+		client:   &http.Client{Timeout: requestTimeout},
+		log:      log,
+		queue:    make(chan logRecord, queueSize),
+		done:     make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e, nil
+}
+
+// instanceResource identifies this stalk process.
+func instanceResource() resource {
+	hostname, _ := os.Hostname()
+
+	return resource{
+		Attributes: []attribute{
+			stringAttribute("service.name", "stalk"),
+			stringAttribute("service.instance.id", fmt.Sprintf("%s/%d", hostname, os.Getpid())),
+			stringAttribute("host.name", hostname),
+			intAttribute("process.pid", os.Getpid()),
+		},
+	}
+}
+
+// Export queues a record for the event. The prefix identifies the cluster
+// the object belongs to, if multiple clusters are watched. If the queue is
+// full, Export blocks until there is space again.
+func (e *Exporter) Export(event watcher.Event, prefix string) {
+	e.queue <- newLogRecord(event, prefix, time.Now())
+}
+
+// Close waits up to the given timeout for all queued records to be
+// exported.
+func (e *Exporter) Close(timeout time.Duration) error {
+	close(e.queue)
+
+	select {
+	case <-e.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("gave up exporting %d record(s)", len(e.queue))
+	}
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+
+	batch := []logRecord{}
+	timer := time.NewTimer(batchDelay)
+	timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := e.send(batch); err != nil {
+			e.log.Warnf("Failed to export %d event(s): %v", len(batch), err)
+		}
+
+		batch = []logRecord{}
+	}
+
+	for {
+		select {
+		case record, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+
+			if len(batch) == 0 {
+				timer.Reset(batchDelay)
+			}
+
+			batch = append(batch, record)
+			if len(batch) >= batchSize {
+				timer.Stop()
+				flush()
+			}
+
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (e *Exporter) send(records []logRecord) error {
+	payload, err := json.Marshal(e.request(records))
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the body must be consumed for the connection to be reused
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func (e *Exporter) request(records []logRecord) exportLogsRequest {
+	return exportLogsRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []scopeLogs{{
+				Scope:      scope{Name: scopeName},
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+func newLogRecord(event watcher.Event, prefix string, observed time.Time) logRecord {
+	obj := event.Object()
+
+	attributes := []attribute{
+		stringAttribute("k8s.event.type", eventTypeName(event.Type)),
+		stringAttribute("k8s.object.api_version", event.GVK.GroupVersion().String()),
+		stringAttribute("k8s.object.kind", event.GVK.Kind),
+		stringAttribute("k8s.object.name", obj.GetName()),
+	}
+
+	if namespace := obj.GetNamespace(); namespace != "" {
+		attributes = append(attributes, stringAttribute("k8s.namespace.name", namespace))
+	}
+
+	if uid := obj.GetUID(); uid != "" {
+		attributes = append(attributes, stringAttribute("k8s.object.uid", string(uid)))
+	}
+
+	if prefix != "" {
+		attributes = append(attributes, stringAttribute("k8s.cluster.name", prefix))
+	}
+
+	if event.Changes > 0 {
+		attributes = append(attributes, intAttribute("k8s.object.changes", event.Changes))
+	}
+
+	body := fmt.Sprintf("%s %s %s", event.Type, event.GVK.Kind, event.Key)
+	if prefix != "" {
+		body = fmt.Sprintf("%s %s %s:%s", event.Type, event.GVK.Kind, prefix, event.Key)
+	}
+
+	return logRecord{
+		TimeUnixNano:         unixNano(event.Timestamp),
+		ObservedTimeUnixNano: unixNano(observed),
+		SeverityNumber:       severityInfo,
+		SeverityText:         "INFO",
+		Body:                 value{StringValue: &body},
+		Attributes:           attributes,
+	}
+}
+
+func eventTypeName(eventType watch.EventType) string {
+	return strings.ToLower(string(eventType))
+}
+
+// unixNano returns the timestamp as a string, as 64 bit integers are
+// encoded as strings in OTLP/JSON.
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
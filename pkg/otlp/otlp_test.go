@@ -0,0 +1,130 @@
+package otlp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestExport(t *testing.T) {
+	requests := make(chan exportLogsRequest, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		var req exportLogsRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		requests <- req
+	}))
+	defer server.Close()
+
+	exporter, err := New(server.URL, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	obj.SetNamespace("default")
+	obj.SetName("nginx")
+
+	exporter.Export(watcher.Event{
+		Type:      watch.Modified,
+		New:       obj,
+		Key:       "default/nginx",
+		GVK:       schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Timestamp: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC),
+		Changes:   2,
+	}, "prod")
+
+	if err := exporter.Close(5 * time.Second); err != nil {
+		t.Fatalf("failed to close exporter: %v", err)
+	}
+
+	var req exportLogsRequest
+	select {
+	case req = <-requests:
+	default:
+		t.Fatal("No records were exported.")
+	}
+
+	if len(req.ResourceLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs[0].LogRecords) != 1 {
+		t.Fatalf("Expected a single record, but got %+v.", req)
+	}
+
+	if service := attributeValue(req.ResourceLogs[0].Resource.Attributes, "service.name"); service != "stalk" {
+		t.Errorf("Expected service name %q, but got %q.", "stalk", service)
+	}
+
+	record := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+
+	if record.TimeUnixNano != "1662033600000000000" {
+		t.Errorf("Expected the event's timestamp, but got %q.", record.TimeUnixNano)
+	}
+
+	if body := *record.Body.StringValue; body != "MODIFIED Deployment prod:default/nginx" {
+		t.Errorf("Expected body %q, but got %q.", "MODIFIED Deployment prod:default/nginx", body)
+	}
+
+	expected := map[string]string{
+		"k8s.event.type":         "modified",
+		"k8s.object.api_version": "apps/v1",
+		"k8s.object.kind":        "Deployment",
+		"k8s.object.name":        "nginx",
+		"k8s.namespace.name":     "default",
+		"k8s.cluster.name":       "prod",
+		"k8s.object.changes":     "2",
+	}
+
+	for key, value := range expected {
+		if actual := attributeValue(record.Attributes, key); actual != value {
+			t.Errorf("Expected attribute %s to be %q, but got %q.", key, value, actual)
+		}
+	}
+}
+
+func TestNewInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "grpc://localhost:4317", "http://"} {
+		if _, err := New(endpoint, logrus.New()); err == nil {
+			t.Errorf("Expected an error for %q, but got none.", endpoint)
+		}
+	}
+}
+
+func attributeValue(attributes []attribute, key string) string {
+	for _, attr := range attributes {
+		if attr.Key != key {
+			continue
+		}
+
+		if attr.Value.StringValue != nil {
+			return *attr.Value.StringValue
+		}
+
+		if attr.Value.IntValue != nil {
+			return *attr.Value.IntValue
+		}
+	}
+
+	return ""
+}
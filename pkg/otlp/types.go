@@ -0,0 +1,65 @@
+package otlp
+
+import "strconv"
+
+// These types are the subset of the OTLP/JSON logs schema that is used by
+// the exporter.
+
+type exportLogsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string      `json:"timeUnixNano"`
+	ObservedTimeUnixNano string      `json:"observedTimeUnixNano"`
+	SeverityNumber       int         `json:"severityNumber"`
+	SeverityText         string      `json:"severityText"`
+	Body                 value       `json:"body"`
+	Attributes           []attribute `json:"attributes"`
+}
+
+type attribute struct {
+	Key   string `json:"key"`
+	Value value  `json:"value"`
+}
+
+type value struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	// IntValue is a string, as 64 bit integers are encoded as strings in
+	// OTLP/JSON.
+	IntValue *string `json:"intValue,omitempty"`
+}
+
+func stringAttribute(key string, s string) attribute {
+	return attribute{
+		Key:   key,
+		Value: value{StringValue: &s},
+	}
+}
+
+func intAttribute(key string, i int) attribute {
+	s := strconv.Itoa(i)
+
+	return attribute{
+		Key:   key,
+		Value: value{IntValue: &s},
+	}
+}
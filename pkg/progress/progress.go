@@ -0,0 +1,73 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Indicator renders a single, constantly updated line like "loading initial
+// state: 340/1200" while the initially existing objects are processed. It
+// must only be used when the output is a terminal.
+type Indicator struct {
+	lock  sync.Mutex
+	out   io.Writer
+	done  int
+	total int
+	shown bool
+}
+
+func New(out io.Writer) *Indicator {
+	return &Indicator{
+		out: out,
+	}
+}
+
+// Add announces that total more objects are about to be processed.
+func (i *Indicator) Add(total int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.total += total
+	i.render()
+}
+
+// Step marks one object as processed. Once all objects are done, the line
+// is removed.
+func (i *Indicator) Step() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.done++
+	i.render()
+}
+
+// Pause removes the line, so that other output can be written to the
+// terminal, and blocks all updates until Resume is called.
+func (i *Indicator) Pause() {
+	i.lock.Lock()
+	i.clear()
+}
+
+// Resume draws the line again after Pause.
+func (i *Indicator) Resume() {
+	i.render()
+	i.lock.Unlock()
+}
+
+func (i *Indicator) render() {
+	if i.done >= i.total {
+		i.clear()
+		return
+	}
+
+	fmt.Fprintf(i.out, "\r\033[Kloading initial state: %d/%d", i.done, i.total)
+	i.shown = true
+}
+
+func (i *Indicator) clear() {
+	if i.shown {
+		fmt.Fprint(i.out, "\r\033[K")
+		i.shown = false
+	}
+}
@@ -0,0 +1,35 @@
+package progress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIndicator(t *testing.T) {
+	var buf bytes.Buffer
+
+	indicator := New(&buf)
+	indicator.Add(2)
+	indicator.Step()
+
+	indicator.Pause()
+	buf.WriteString("diff\n")
+	indicator.Resume()
+
+	indicator.Step()
+
+	// further output must not be touched anymore
+	indicator.Pause()
+	indicator.Resume()
+
+	expected := "\r\033[Kloading initial state: 0/2" +
+		"\r\033[Kloading initial state: 1/2" +
+		"\r\033[K" +
+		"diff\n" +
+		"\r\033[Kloading initial state: 1/2" +
+		"\r\033[K"
+
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Expected %q, but got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,513 @@
+//go:build kafka
+
+package publish
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	brokers["kafka"] = newKafkaBroker
+}
+
+const (
+	kafkaDefaultPort  = "9092"
+	kafkaDefaultTopic = "stalk.events"
+
+	// kafkaTimeout limits how long connecting and producing may take.
+	kafkaTimeout = 10 * time.Second
+
+	kafkaClientID = "stalk"
+
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+
+	// these versions are supported by all brokers since Kafka 1.0, and
+	// still by current ones
+	kafkaProduceVersion  = 3
+	kafkaMetadataVersion = 4
+)
+
+// kafkaBroker publishes messages using the Kafka wire protocol. All
+// messages are written to a single partition, so that they keep their
+// order, and are only considered delivered once all in-sync replicas have
+// acknowledged them.
+type kafkaBroker struct {
+	bootstrap string
+	topic     string
+	partition int32
+
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+}
+
+func newKafkaBroker(u *url.URL) (broker, error) {
+	address := u.Host
+	if address == "" {
+		return nil, errors.New("no Kafka broker given")
+	}
+
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), kafkaDefaultPort)
+	}
+
+	topic := strings.Trim(u.Path, "/")
+	if topic == "" {
+		topic = kafkaDefaultTopic
+	}
+
+	if len(topic) > 249 || strings.Trim(topic, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-") != "" {
+		return nil, fmt.Errorf("invalid Kafka topic %q", topic)
+	}
+
+	b := &kafkaBroker{
+		bootstrap: address,
+		topic:     topic,
+	}
+
+	if partition := u.Query().Get("partition"); partition != "" {
+		parsed, err := strconv.ParseInt(partition, 10, 32)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid Kafka partition %q", partition)
+		}
+
+		b.partition = int32(parsed)
+	}
+
+	return b, nil
+}
+
+func (b *kafkaBroker) Send(ctx context.Context, payload []byte) error {
+	if b.conn == nil {
+		if err := b.connect(ctx); err != nil {
+			return fmt.Errorf("failed to connect to the leader of %s/%d: %w", b.topic, b.partition, err)
+		}
+	}
+
+	if err := b.produce(payload); err != nil {
+		// the leader might have moved, so start over next time
+		b.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (b *kafkaBroker) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+
+	err := b.conn.Close()
+	b.conn = nil
+	b.reader = nil
+
+	return err
+}
+
+// connect asks the bootstrap broker for the leader of the partition and
+// connects to it.
+func (b *kafkaBroker) connect(ctx context.Context) error {
+	if err := b.dial(ctx, b.bootstrap); err != nil {
+		return err
+	}
+
+	leader, err := b.findLeader()
+	if err != nil {
+		b.Close()
+		return err
+	}
+
+	if leader == b.bootstrap {
+		return nil
+	}
+
+	b.Close()
+
+	return b.dial(ctx, leader)
+}
+
+func (b *kafkaBroker) dial(ctx context.Context, address string) error {
+	dialer := net.Dialer{Timeout: kafkaTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	b.conn = conn
+	b.reader = bufio.NewReader(conn)
+
+	return nil
+}
+
+func (b *kafkaBroker) findLeader() (string, error) {
+	request := &kafkaWriter{}
+	request.array(1)
+	request.string(b.topic)
+	request.bool(false) // allow_auto_topic_creation
+
+	response, err := b.roundTrip(kafkaAPIMetadata, kafkaMetadataVersion, request.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to request metadata: %w", err)
+	}
+
+	r := &kafkaReader{data: response}
+	r.int32() // throttle_time_ms
+
+	addresses := map[int32]string{}
+	for i := r.array(); i > 0 && r.err == nil; i-- {
+		nodeID := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // rack
+
+		addresses[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+
+	r.string() // cluster_id
+	r.int32()  // controller_id
+
+	leader := int32(-1)
+	for i := r.array(); i > 0 && r.err == nil; i-- {
+		if code := r.int16(); code != 0 && r.err == nil {
+			return "", fmt.Errorf("topic %q: %w", b.topic, kafkaError(code))
+		}
+
+		r.string() // name
+		r.bool()   // is_internal
+
+		for j := r.array(); j > 0 && r.err == nil; j-- {
+			code := r.int16()
+			index := r.int32()
+			leaderID := r.int32()
+			r.int32Array() // replica_nodes
+			r.int32Array() // isr_nodes
+
+			if index != b.partition {
+				continue
+			}
+
+			if code != 0 {
+				return "", fmt.Errorf("partition %d: %w", index, kafkaError(code))
+			}
+
+			leader = leaderID
+		}
+	}
+
+	if r.err != nil {
+		return "", fmt.Errorf("invalid metadata: %w", r.err)
+	}
+
+	address, ok := addresses[leader]
+	if !ok {
+		return "", fmt.Errorf("partition %d of topic %q has no leader", b.partition, b.topic)
+	}
+
+	return address, nil
+}
+
+func (b *kafkaBroker) produce(payload []byte) error {
+	batch := kafkaRecordBatch(payload, time.Now())
+
+	request := &kafkaWriter{}
+	request.nullableString(nil) // transactional_id
+	request.int16(-1)           // acks from all in-sync replicas
+	request.int32(int32(kafkaTimeout / time.Millisecond))
+	request.array(1)
+	request.string(b.topic)
+	request.array(1)
+	request.int32(b.partition)
+	request.bytes(batch)
+
+	response, err := b.roundTrip(kafkaAPIProduce, kafkaProduceVersion, request.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	r := &kafkaReader{data: response}
+	for i := r.array(); i > 0 && r.err == nil; i-- {
+		r.string() // name
+
+		for j := r.array(); j > 0 && r.err == nil; j-- {
+			r.int32() // index
+			code := r.int16()
+			r.int64() // base_offset
+			r.int64() // log_append_time_ms
+
+			if code != 0 && r.err == nil {
+				return fmt.Errorf("broker error: %w", kafkaError(code))
+			}
+		}
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("invalid produce response: %w", r.err)
+	}
+
+	return nil
+}
+
+// roundTrip sends a request and returns the body of the response.
+func (b *kafkaBroker) roundTrip(apiKey int16, version int16, body []byte) ([]byte, error) {
+	if err := b.conn.SetDeadline(time.Now().Add(kafkaTimeout)); err != nil {
+		return nil, err
+	}
+
+	b.correlationID++
+
+	header := &kafkaWriter{}
+	header.int16(apiKey)
+	header.int16(version)
+	header.int32(b.correlationID)
+	header.string(kafkaClientID)
+
+	message := &kafkaWriter{}
+	message.int32(int32(header.Len() + len(body)))
+	message.Write(header.Bytes())
+	message.Write(body)
+
+	if _, err := b.conn.Write(message.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var size int32
+	if err := binary.Read(b.reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+
+	if size < 4 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+
+	response := make([]byte, size)
+	if _, err := io.ReadFull(b.reader, response); err != nil {
+		return nil, err
+	}
+
+	if id := int32(binary.BigEndian.Uint32(response)); id != b.correlationID {
+		return nil, fmt.Errorf("expected response %d, but got %d", b.correlationID, id)
+	}
+
+	return response[4:], nil
+}
+
+// kafkaRecordBatch returns a batch (message format v2) containing a single
+// record without key and headers.
+func kafkaRecordBatch(payload []byte, timestamp time.Time) []byte {
+	record := &kafkaWriter{}
+	record.int8(0)    // attributes
+	record.varint(0)  // timestamp_delta
+	record.varint(0)  // offset_delta
+	record.varint(-1) // key
+	record.varint(int64(len(payload)))
+	record.Write(payload)
+	record.varint(0) // headers
+
+	millis := timestamp.UnixMilli()
+
+	// the part of the batch that is covered by the checksum
+	body := &kafkaWriter{}
+	body.int16(0) // attributes
+	body.int32(0) // last_offset_delta
+	body.int64(millis)
+	body.int64(millis)
+	body.int64(-1) // producer_id
+	body.int16(-1) // producer_epoch
+	body.int32(-1) // base_sequence
+	body.int32(1)  // records
+	body.varint(int64(record.Len()))
+	body.Write(record.Bytes())
+
+	batch := &kafkaWriter{}
+	batch.int64(0) // base_offset
+	batch.int32(int32(4 + 1 + 4 + body.Len()))
+	batch.int32(-1) // partition_leader_epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(body.Bytes(), crc32.MakeTable(crc32.Castagnoli))))
+	batch.Write(body.Bytes())
+
+	return batch.Bytes()
+}
+
+// kafkaError describes the most common error codes of the Kafka protocol.
+func kafkaError(code int16) error {
+	switch code {
+	case 3:
+		return errors.New("unknown topic or partition")
+	case 5:
+		return errors.New("leader not available")
+	case 6:
+		return errors.New("not leader for partition")
+	case 7:
+		return errors.New("request timed out")
+	case 10:
+		return errors.New("message too large")
+	case 19, 20:
+		return errors.New("not enough in-sync replicas")
+	case 29:
+		return errors.New("topic authorization failed")
+	default:
+		return fmt.Errorf("error code %d", code)
+	}
+}
+
+// kafkaWriter encodes the primitive types of the Kafka protocol.
+type kafkaWriter struct {
+	bytes.Buffer
+}
+
+func (w *kafkaWriter) int8(v int8) {
+	w.WriteByte(byte(v))
+}
+
+func (w *kafkaWriter) int16(v int16) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *kafkaWriter) int32(v int32) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *kafkaWriter) int64(v int64) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *kafkaWriter) bool(v bool) {
+	if v {
+		w.int8(1)
+	} else {
+		w.int8(0)
+	}
+}
+
+func (w *kafkaWriter) varint(v int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutVarint(buf, v)])
+}
+
+func (w *kafkaWriter) array(length int) {
+	w.int32(int32(length))
+}
+
+func (w *kafkaWriter) string(s string) {
+	w.int16(int16(len(s)))
+	w.WriteString(s)
+}
+
+func (w *kafkaWriter) nullableString(s *string) {
+	if s == nil {
+		w.int16(-1)
+		return
+	}
+
+	w.string(*s)
+}
+
+func (w *kafkaWriter) bytes(b []byte) {
+	w.int32(int32(len(b)))
+	w.Write(b)
+}
+
+// kafkaReader decodes the primitive types of the Kafka protocol. After the
+// first error, all further reads return zero values.
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); b != nil {
+		return int8(b[0])
+	}
+
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+
+	return 0
+}
+
+func (r *kafkaReader) bool() bool {
+	return r.int8() != 0
+}
+
+// array returns the number of elements of an array; null arrays are
+// treated like empty ones.
+func (r *kafkaReader) array() int {
+	n := r.int32()
+	if n < 0 {
+		return 0
+	}
+
+	return int(n)
+}
+
+func (r *kafkaReader) int32Array() []int32 {
+	result := []int32{}
+	for i := r.array(); i > 0 && r.err == nil; i-- {
+		result = append(result, r.int32())
+	}
+
+	return result
+}
+
+// string reads a (nullable) string; null is returned as "".
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+
+	return string(r.next(int(n)))
+}
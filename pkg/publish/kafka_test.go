@@ -0,0 +1,269 @@
+//go:build kafka
+
+package publish
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// fakeKafkaBroker accepts a single client, is the leader for all partitions
+// and sends the topic and value of every produced record to the returned
+// channel.
+func fakeKafkaBroker(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, portString, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portString)
+
+	messages := make(chan string, 10)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		for {
+			var size int32
+			if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+				return
+			}
+
+			request := make([]byte, size)
+			if _, err := io.ReadFull(reader, request); err != nil {
+				return
+			}
+
+			r := &kafkaReader{data: request}
+			apiKey := r.int16()
+			r.int16() // version
+			correlationID := r.int32()
+			r.string() // client_id
+
+			response := &kafkaWriter{}
+			response.int32(correlationID)
+
+			switch apiKey {
+			case kafkaAPIMetadata:
+				topic := ""
+				if r.array() > 0 {
+					topic = r.string()
+				}
+
+				response.int32(0) // throttle_time_ms
+				response.array(1)
+				response.int32(1)
+				response.string(host)
+				response.int32(int32(port))
+				response.nullableString(nil) // rack
+				response.nullableString(nil) // cluster_id
+				response.int32(1)            // controller_id
+				response.array(1)
+				response.int16(0)
+				response.string(topic)
+				response.bool(false)
+				response.array(1)
+				response.int16(0)
+				response.int32(0)
+				response.int32(1) // leader
+				response.array(1)
+				response.int32(1)
+				response.array(1)
+				response.int32(1)
+
+			case kafkaAPIProduce:
+				r.string() // transactional_id
+				r.int16()  // acks
+				r.int32()  // timeout_ms
+				r.array()
+				topic := r.string()
+				r.array()
+				r.int32() // partition
+
+				value, err := decodeRecordBatch(r.next(int(r.int32())))
+				if err != nil {
+					t.Errorf("Received invalid record batch: %v", err)
+					return
+				}
+
+				messages <- topic + " " + value
+
+				response.array(1)
+				response.string(topic)
+				response.array(1)
+				response.int32(0)
+				response.int16(0)
+				response.int64(0)
+				response.int64(-1)
+				response.int32(0) // throttle_time_ms
+
+			default:
+				t.Errorf("Received unexpected request %d.", apiKey)
+				return
+			}
+
+			framed := &kafkaWriter{}
+			framed.bytes(response.Bytes())
+
+			if _, err := conn.Write(framed.Bytes()); err != nil {
+				return
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+// decodeRecordBatch verifies the checksum of a batch with a single record
+// and returns the record's value.
+func decodeRecordBatch(batch []byte) (string, error) {
+	r := &kafkaReader{data: batch}
+	r.int64() // base_offset
+	r.int32() // batch_length
+	r.int32() // partition_leader_epoch
+
+	if magic := r.int8(); magic != 2 {
+		return "", fmt.Errorf("unexpected magic %d", magic)
+	}
+
+	checksum := uint32(r.int32())
+	if actual := crc32.Checksum(r.data, crc32.MakeTable(crc32.Castagnoli)); actual != checksum {
+		return "", fmt.Errorf("checksum %x does not match %x", checksum, actual)
+	}
+
+	// attributes, last_offset_delta, timestamps, producer and sequence
+	r.next(2 + 4 + 8 + 8 + 8 + 2 + 4)
+
+	if records := r.int32(); records != 1 {
+		return "", fmt.Errorf("expected 1 record, but got %d", records)
+	}
+
+	varint := func() int64 {
+		v, n := binary.Varint(r.data)
+		r.next(n)
+		return v
+	}
+
+	varint() // length
+	r.int8() // attributes
+	varint() // timestamp_delta
+	varint() // offset_delta
+	varint() // key
+	value := r.next(int(varint()))
+
+	return string(value), r.err
+}
+
+func TestPublishKafka(t *testing.T) {
+	address, messages := fakeKafkaBroker(t)
+
+	publisher, err := New(fmt.Sprintf("kafka://%s/test.events", address), logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("foo")
+
+	publisher.Publish(watcher.Event{
+		Type:      watch.Added,
+		New:       obj,
+		Key:       "default/foo",
+		GVK:       schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Timestamp: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC),
+	}, "")
+
+	if err := publisher.Close(5 * time.Second); err != nil {
+		t.Fatalf("failed to close publisher: %v", err)
+	}
+
+	var message string
+	select {
+	case message = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message was published.")
+	}
+
+	topic, payload, _ := strings.Cut(message, " ")
+	if topic != "test.events" {
+		t.Errorf("Expected topic %q, but got %q.", "test.events", topic)
+	}
+
+	var published record
+	if err := json.Unmarshal([]byte(payload), &published); err != nil {
+		t.Fatalf("Published message is not valid JSON: %v", err)
+	}
+
+	if published.Type != watch.Added || published.Key != "default/foo" || published.Kind != "ConfigMap" {
+		t.Errorf("Published unexpected event %s.", payload)
+	}
+}
+
+func TestNewKafkaBroker(t *testing.T) {
+	testcases := []struct {
+		url     string
+		valid   bool
+		address string
+		topic   string
+	}{
+		{url: "kafka://localhost", valid: true, address: "localhost:9092", topic: "stalk.events"},
+		{url: "kafka://localhost:9093/changes?partition=2", valid: true, address: "localhost:9093", topic: "changes"},
+		{url: "kafka:///changes"},
+		{url: "kafka://localhost/invalid%20topic"},
+		{url: "kafka://localhost/changes?partition=-1"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatalf("failed to parse URL: %v", err)
+			}
+
+			b, err := newKafkaBroker(u)
+			if !tc.valid {
+				if err == nil {
+					t.Error("Expected an error, but got none.")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v.", err)
+			}
+
+			kb := b.(*kafkaBroker)
+			if kb.bootstrap != tc.address || kb.topic != tc.topic {
+				t.Errorf("Expected %s/%s, but got %s/%s.", tc.address, tc.topic, kb.bootstrap, kb.topic)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	natsDefaultPort    = "4222"
+	natsDefaultSubject = "stalk.events"
+
+	// natsTimeout limits how long connecting and publishing may take.
+	natsTimeout = 10 * time.Second
+)
+
+// natsBroker publishes messages using the NATS client protocol. Every
+// message is followed by a PING, and it is only considered delivered once
+// the server answered with a PONG, i.e. processed the message.
+type natsBroker struct {
+	address  string
+	subject  string
+	user     string
+	password string
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newNATSBroker(u *url.URL) (broker, error) {
+	address := u.Host
+	if address == "" {
+		return nil, errors.New("no NATS server given")
+	}
+
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), natsDefaultPort)
+	}
+
+	subject := strings.Trim(u.Path, "/")
+	if subject == "" {
+		subject = natsDefaultSubject
+	}
+
+	if strings.ContainsAny(subject, " \t\r\n/") {
+		return nil, fmt.Errorf("invalid NATS subject %q", subject)
+	}
+
+	b := &natsBroker{
+		address: address,
+		subject: subject,
+	}
+
+	if u.User != nil {
+		b.user = u.User.Username()
+		b.password, _ = u.User.Password()
+	}
+
+	return b, nil
+}
+
+func (b *natsBroker) Send(ctx context.Context, payload []byte) error {
+	if b.conn == nil {
+		if err := b.connect(ctx); err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", b.address, err)
+		}
+	}
+
+	if err := b.publish(payload); err != nil {
+		// the connection is in an unknown state, so start over next time
+		b.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (b *natsBroker) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+
+	err := b.conn.Close()
+	b.conn = nil
+	b.reader = nil
+
+	return err
+}
+
+func (b *natsBroker) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: natsTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", b.address)
+	if err != nil {
+		return err
+	}
+
+	b.conn = conn
+	b.reader = bufio.NewReader(conn)
+
+	if err := b.handshake(); err != nil {
+		b.Close()
+		return err
+	}
+
+	return nil
+}
+
+// handshake reads the server's INFO and identifies the client.
+func (b *natsBroker) handshake() error {
+	if err := b.conn.SetDeadline(time.Now().Add(natsTimeout)); err != nil {
+		return err
+	}
+
+	line, err := b.readLine()
+	if err != nil {
+		return fmt.Errorf("failed to read server info: %w", err)
+	}
+
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return fmt.Errorf("invalid server info: %w", err)
+	}
+
+	if info.TLSRequired {
+		return errors.New("server requires TLS, which is not supported")
+	}
+
+	connect, err := json.Marshal(map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "stalk",
+		"lang":     "go",
+		"user":     b.user,
+		"pass":     b.password,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(b.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+
+	return b.awaitPong()
+}
+
+func (b *natsBroker) publish(payload []byte) error {
+	if err := b.conn.SetDeadline(time.Now().Add(natsTimeout)); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", b.subject, len(payload), payload)
+	if _, err := b.conn.Write([]byte(message)); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return b.awaitPong()
+}
+
+// awaitPong reads from the server until it answers a PING, which means that
+// everything sent before has been processed.
+func (b *natsBroker) awaitPong() error {
+	for {
+		line, err := b.readLine()
+		if err != nil {
+			return fmt.Errorf("failed to read from server: %w", err)
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+
+		case line == "PING":
+			if _, err := b.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+
+		default:
+			// +OK and updated INFO messages are not relevant
+		}
+	}
+}
+
+func (b *natsBroker) readLine() (string, error) {
+	line, err := b.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
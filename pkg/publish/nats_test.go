@@ -0,0 +1,141 @@
+package publish
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// fakeNATSServer accepts a single client and sends every published
+// message to the returned channel.
+func fakeNATSServer(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimRight(line, "\r\n")
+
+			switch {
+			case line == "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+
+			case strings.HasPrefix(line, "PUB "):
+				var (
+					subject string
+					size    int
+				)
+
+				if _, err := fmt.Sscanf(line, "PUB %s %d", &subject, &size); err != nil {
+					return
+				}
+
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(reader, payload); err != nil {
+					return
+				}
+
+				messages <- subject + " " + string(payload[:size])
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func TestPublishNATS(t *testing.T) {
+	address, messages := fakeNATSServer(t)
+
+	publisher, err := New(fmt.Sprintf("nats://%s/test.events", address), logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("foo")
+
+	publisher.Publish(watcher.Event{
+		Type:      watch.Added,
+		New:       obj,
+		Key:       "default/foo",
+		GVK:       schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Timestamp: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC),
+	}, "prod")
+
+	if err := publisher.Close(5 * time.Second); err != nil {
+		t.Fatalf("failed to close publisher: %v", err)
+	}
+
+	var message string
+	select {
+	case message = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message was published.")
+	}
+
+	subject, payload, _ := strings.Cut(message, " ")
+	if subject != "test.events" {
+		t.Errorf("Expected subject %q, but got %q.", "test.events", subject)
+	}
+
+	var published record
+	if err := json.Unmarshal([]byte(payload), &published); err != nil {
+		t.Fatalf("Published message is not valid JSON: %v", err)
+	}
+
+	if published.Type != watch.Added || published.Cluster != "prod" || published.Key != "default/foo" || published.Kind != "ConfigMap" {
+		t.Errorf("Published unexpected event %s.", payload)
+	}
+
+	if published.New == nil || published.New.GetName() != "foo" {
+		t.Errorf("Expected the object to be published, but got %s.", payload)
+	}
+}
+
+func TestNewUnsupportedBroker(t *testing.T) {
+	if _, err := New("amqp://localhost:5672/events", logrus.New()); err == nil {
+		t.Error("Expected an error for an unsupported broker, but got none.")
+	}
+
+	// optional brokers explain how to enable them
+	if _, included := brokers["kafka"]; !included {
+		_, err := New("kafka://localhost:9092/events", logrus.New())
+		if err == nil || !strings.Contains(err.Error(), "-tags kafka") {
+			t.Errorf("Expected an error pointing to the build tag, but got %v.", err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// queueSize is the number of events that can be waiting for delivery
+	// before Publish blocks.
+	queueSize = 1000
+
+	minRetryDelay = 1 * time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// broker delivers a single message. Send must only return nil once the
+// broker has accepted the message.
+type broker interface {
+	Send(ctx context.Context, payload []byte) error
+	Close() error
+}
+
+// brokers are the supported URL schemes. Optional brokers register
+// themselves here when stalk is built with their build tag.
+var brokers = map[string]func(u *url.URL) (broker, error){
+	"nats": newNATSBroker,
+}
+
+// optionalBrokers are the build tags that enable optional brokers.
+var optionalBrokers = map[string]string{
+	"kafka": "kafka",
+}
+
+// Publisher sends events as JSON to a message broker. Events are queued and
+// delivered in the background; failed deliveries are retried until they
+// succeed (at-least-once delivery) or the publisher is closed.
+type Publisher struct {
+	broker broker
+	log    logrus.FieldLogger
+	queue  chan []byte
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New returns a publisher for the given broker URL, e.g.
+// "nats://localhost:4222/stalk.events" or, if built with the kafka tag,
+// "kafka://localhost:9092/stalk.events".
+func New(brokerURL string, log logrus.FieldLogger) (*Publisher, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	factory, ok := brokers[u.Scheme]
+	if !ok {
+		if tag, optional := optionalBrokers[u.Scheme]; optional {
+			return nil, fmt.Errorf("broker %q is not included in this build of stalk, rebuild it with \"-tags %s\"", u.Scheme, tag)
+		}
+
+		return nil, fmt.Errorf("unsupported broker %q, must be one of %v", u.Scheme, schemes())
+	}
+
+	b, err := factory(u)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Publisher{
+		broker: b,
+		log:    log,
+		queue:  make(chan []byte, queueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+// record is the JSON representation of a published event.
+type record struct {
+	Type       watch.EventType            `json:"type"`
+	Cluster    string                     `json:"cluster,omitempty"`
+	APIVersion string                     `json:"apiVersion"`
+	Kind       string                     `json:"kind"`
+	Key        string                     `json:"key"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Changes    int                        `json:"changes,omitempty"`
+	Old        *unstructured.Unstructured `json:"old,omitempty"`
+	New        *unstructured.Unstructured `json:"new,omitempty"`
+}
+
+// Publish queues the event for delivery. The prefix identifies the cluster
+// the object belongs to, if multiple clusters are watched. If the queue is
+// full, Publish blocks until there is space again.
+func (p *Publisher) Publish(event watcher.Event, prefix string) {
+	payload, err := json.Marshal(record{
+		Type:       event.Type,
+		Cluster:    prefix,
+		APIVersion: event.GVK.GroupVersion().String(),
+		Kind:       event.GVK.Kind,
+		Key:        event.Key,
+		Timestamp:  event.Timestamp,
+		Changes:    event.Changes,
+		Old:        event.Old,
+		New:        event.New,
+	})
+	if err != nil {
+		p.log.Errorf("Failed to encode event: %v", err)
+		return
+	}
+
+	p.queue <- payload
+}
+
+// Close waits up to the given timeout for all queued events to be
+// delivered and then closes the connection to the broker.
+func (p *Publisher) Close(timeout time.Duration) error {
+	close(p.queue)
+
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		p.cancel()
+		<-p.done
+
+		// the event that was being delivered is not in the queue anymore
+		p.log.Warnf("Gave up publishing %d event(s).", len(p.queue)+1)
+	}
+
+	p.cancel()
+
+	return p.broker.Close()
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+
+	for payload := range p.queue {
+		if !p.deliver(payload) {
+			return
+		}
+	}
+}
+
+// deliver sends the payload, retrying with an increasing delay. It returns
+// false if the publisher was closed in the meantime.
+func (p *Publisher) deliver(payload []byte) bool {
+	delay := minRetryDelay
+
+	for {
+		err := p.broker.Send(p.ctx, payload)
+		if err == nil {
+			return true
+		}
+
+		if p.ctx.Err() != nil {
+			return false
+		}
+
+		p.log.Warnf("Failed to publish event, retrying in %v: %v", delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return false
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func schemes() []string {
+	result := []string{}
+	for scheme := range brokers {
+		result = append(result, scheme)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,175 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Report collects statistics about all events during a session, so that a
+// summary can be written when stalk exits.
+type Report struct {
+	lock       sync.Mutex
+	started    time.Time
+	events     map[string]map[watch.EventType]int
+	seen       map[string]struct{}
+	changed    map[string]struct{}
+	reconnects int
+}
+
+func New() *Report {
+	return &Report{
+		started: time.Now(),
+		events:  map[string]map[watch.EventType]int{},
+		seen:    map[string]struct{}{},
+		changed: map[string]struct{}{},
+	}
+}
+
+// Record counts the event. The prefix is put in front of the object key to
+// distinguish objects from multiple clusters.
+func (r *Report) Record(event watcher.Event, prefix string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	kind := event.GVK.Kind
+	if _, ok := r.events[kind]; !ok {
+		r.events[kind] = map[watch.EventType]int{}
+	}
+	r.events[kind][event.Type]++
+
+	key := fmt.Sprintf("%s %s", kind, event.Key)
+	if prefix != "" {
+		key = fmt.Sprintf("%s %s:%s", kind, prefix, event.Key)
+	}
+
+	r.seen[key] = struct{}{}
+
+	if event.Type == watch.Modified || event.Type == watch.Deleted {
+		r.changed[key] = struct{}{}
+	}
+}
+
+// RecordReconnect counts a watch that had to be re-established.
+func (r *Report) RecordReconnect() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reconnects++
+}
+
+type summary struct {
+	Started         time.Time                          `json:"started"`
+	Finished        time.Time                          `json:"finished"`
+	DurationSeconds float64                            `json:"durationSeconds"`
+	Events          map[string]map[watch.EventType]int `json:"events"`
+	ObjectsSeen     int                                `json:"objectsSeen"`
+	Reconnects      int                                `json:"reconnects"`
+	ChangedObjects  []string                           `json:"changedObjects"`
+}
+
+// WriteFile writes the report as JSON. The file is written atomically, so
+// that it is never left truncated.
+func (r *Report) WriteFile(filename string) error {
+	r.lock.Lock()
+	finished := time.Now()
+
+	s := summary{
+		Started:         r.started,
+		Finished:        finished,
+		DurationSeconds: finished.Sub(r.started).Seconds(),
+		Events:          r.events,
+		ObjectsSeen:     len(r.seen),
+		Reconnects:      r.reconnects,
+		ChangedObjects:  sortedKeys(r.changed),
+	}
+
+	encoded, err := json.MarshalIndent(s, "", "  ")
+	r.lock.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filename), ".stalk-report-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(append(encoded, '\n')); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to sync report: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close report: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), filename); err != nil {
+		return fmt.Errorf("failed to move report into place: %w", err)
+	}
+
+	return nil
+}
+
+// WriteSummary writes the number of events per kind in a human readable
+// form, e.g. "Deployment: 2 created, 5 updated, 0 deleted".
+func (r *Report) WriteSummary(out io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	duration := time.Since(r.started).Round(time.Second)
+
+	if len(r.events) == 0 {
+		_, err := fmt.Fprintf(out, "No events in %v.\n", duration)
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "Events in %v:\n", duration); err != nil {
+		return err
+	}
+
+	kinds := []string{}
+	for kind := range r.events {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		counts := r.events[kind]
+
+		_, err := fmt.Fprintf(out, "  %s: %d created, %d updated, %d deleted\n", kind, counts[watch.Added], counts[watch.Modified], counts[watch.Deleted])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestWriteSummary(t *testing.T) {
+	r := New()
+
+	record := func(kind string, eventType watch.EventType) {
+		r.Record(watcher.Event{
+			Type: eventType,
+			GVK:  schema.GroupVersionKind{Version: "v1", Kind: kind},
+			Key:  "default/test",
+		}, "")
+	}
+
+	record("Pod", watch.Added)
+	record("Pod", watch.Modified)
+	record("Pod", watch.Modified)
+	record("ConfigMap", watch.Deleted)
+
+	out := &bytes.Buffer{}
+	if err := r.WriteSummary(out); err != nil {
+		t.Fatalf("failed to write summary: %v", err)
+	}
+
+	// the first line contains the duration
+	lines := strings.SplitN(out.String(), "\n", 2)
+
+	expected := "  ConfigMap: 0 created, 0 updated, 1 deleted\n  Pod: 1 created, 2 updated, 0 deleted\n"
+	if lines[1] != expected {
+		t.Errorf("Expected %q, but got %q.", expected, lines[1])
+	}
+}
+
+func TestWriteSummaryWithoutEvents(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := New().WriteSummary(out); err != nil {
+		t.Fatalf("failed to write summary: %v", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "No events in ") {
+		t.Errorf("Expected no events to be reported, but got %q.", out.String())
+	}
+}
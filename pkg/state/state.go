@@ -0,0 +1,123 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.xrstf.de/stalk/pkg/watcher"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Collector remembers all watchers of a session, so that the last known
+// state of their objects can be written when stalk exits.
+type Collector struct {
+	lock    sync.Mutex
+	sources []source
+}
+
+type source struct {
+	watcher *watcher.Watcher
+	prefix  string
+}
+
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Add registers a watcher. The prefix is put in front of the filenames to
+// distinguish objects from multiple clusters.
+func (c *Collector) Add(w *watcher.Watcher, prefix string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sources = append(c.sources, source{
+		watcher: w,
+		prefix:  prefix,
+	})
+}
+
+// WriteDir writes every known object as a YAML file into the directory,
+// which is created if necessary, and returns the number of written files.
+// Each file is written atomically, so that it is never left truncated.
+func (c *Collector) WriteDir(dir string) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	written := 0
+
+	for _, src := range c.sources {
+		for _, obj := range src.watcher.Objects() {
+			if err := writeObject(filepath.Join(dir, Filename(obj, src.prefix)), obj); err != nil {
+				return written, err
+			}
+
+			written++
+		}
+	}
+
+	return written, nil
+}
+
+// Filename returns the name of the file for the object, in the form
+// "[prefix_]kind[.group]_[namespace_]name.yaml".
+func Filename(obj *unstructured.Unstructured, prefix string) string {
+	gvk := obj.GroupVersionKind()
+
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind = fmt.Sprintf("%s.%s", kind, gvk.Group)
+	}
+
+	parts := []string{}
+	if prefix != "" {
+		parts = append(parts, prefix)
+	}
+
+	parts = append(parts, kind)
+
+	if ns := obj.GetNamespace(); ns != "" {
+		parts = append(parts, ns)
+	}
+
+	parts = append(parts, obj.GetName())
+
+	// kubeconfig context names can contain slashes (e.g. EKS ARNs)
+	return strings.ReplaceAll(strings.Join(parts, "_"), "/", "_") + ".yaml"
+}
+
+func writeObject(filename string, obj *unstructured.Unstructured) error {
+	encoded, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(filename), err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filename), ".stalk-state-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(encoded); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(filename), err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", filepath.Base(filename), err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), filename); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", filepath.Base(filename), err)
+	}
+
+	return nil
+}
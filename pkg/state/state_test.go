@@ -0,0 +1,66 @@
+package state
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestFilename(t *testing.T) {
+	testcases := []struct {
+		name       string
+		apiVersion string
+		kind       string
+		namespace  string
+		objName    string
+		prefix     string
+		expected   string
+	}{
+		{
+			name:       "core namespaced object",
+			apiVersion: "v1",
+			kind:       "ConfigMap",
+			namespace:  "default",
+			objName:    "settings",
+			expected:   "configmap_default_settings.yaml",
+		},
+		{
+			name:       "grouped object",
+			apiVersion: "apps/v1",
+			kind:       "Deployment",
+			namespace:  "kube-system",
+			objName:    "coredns",
+			expected:   "deployment.apps_kube-system_coredns.yaml",
+		},
+		{
+			name:       "cluster-scoped object",
+			apiVersion: "rbac.authorization.k8s.io/v1",
+			kind:       "ClusterRole",
+			objName:    "system:controller",
+			expected:   "clusterrole.rbac.authorization.k8s.io_system:controller.yaml",
+		},
+		{
+			name:       "prefix with slashes",
+			apiVersion: "v1",
+			kind:       "Pod",
+			namespace:  "default",
+			objName:    "web",
+			prefix:     "arn:aws:eks:eu-west-1:123:cluster/prod",
+			expected:   "arn:aws:eks:eu-west-1:123:cluster_prod_pod_default_web.yaml",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(tc.apiVersion)
+			obj.SetKind(tc.kind)
+			obj.SetNamespace(tc.namespace)
+			obj.SetName(tc.objName)
+
+			if filename := Filename(obj, tc.prefix); filename != tc.expected {
+				t.Errorf("Expected %q, but got %q.", tc.expected, filename)
+			}
+		})
+	}
+}
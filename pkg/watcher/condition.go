@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Condition is a JSONPath expression and the value it must evaluate to.
+type Condition struct {
+	expr  string
+	path  *jsonpath.JSONPath
+	value string
+}
+
+// ParseCondition parses "status.phase=Running" or "{.status.phase}=Running".
+// The value is everything after the last equal sign.
+func ParseCondition(s string) (*Condition, error) {
+	idx := strings.LastIndex(s, "=")
+	if idx <= 0 {
+		return nil, fmt.Errorf("condition %q must be in the form PATH=VALUE", s)
+	}
+
+	expr := jsonPathExpression(s[:idx])
+
+	path := jsonpath.New("condition").AllowMissingKeys(true)
+	if err := path.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid JSON path in condition %q: %w", s, err)
+	}
+
+	return &Condition{
+		expr:  expr,
+		path:  path,
+		value: strings.TrimSpace(s[idx+1:]),
+	}, nil
+}
+
+func (c *Condition) String() string {
+	return fmt.Sprintf("%s=%s", c.expr, c.value)
+}
+
+// Matches returns true if any value the path yields for the object is
+// equal to the expected value.
+func (c *Condition) Matches(obj *unstructured.Unstructured) bool {
+	results, err := c.path.FindResults(obj.Object)
+	if err != nil {
+		return false
+	}
+
+	for _, result := range results {
+		for _, value := range result {
+			if fmt.Sprintf("%v", value.Interface()) == c.value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// jsonPathExpression turns a dotted path like "status.phase" into the
+// JSON path "{.status.phase}". Expressions in braces are kept as they are.
+func jsonPathExpression(s string) string {
+	expr := strings.TrimSpace(s)
+	if !strings.HasPrefix(expr, "{") {
+		expr = fmt.Sprintf("{.%s}", strings.TrimPrefix(expr, "."))
+	}
+
+	return expr
+}
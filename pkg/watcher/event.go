@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Event is a single change to a watched object.
+type Event struct {
+	// Type is one of watch.Added, watch.Modified or watch.Deleted.
+	Type watch.EventType
+
+	// Old is the previously known state of the object. It is nil for newly
+	// created objects and for objects that were never seen before. For
+	// deletions, this is the final state of the object.
+	Old *unstructured.Unstructured
+
+	// New is the current state of the object, nil for deletions.
+	New *unstructured.Unstructured
+
+	// Key identifies the object within its kind, i.e. "namespace/name" or
+	// just "name" for cluster-scoped objects.
+	Key string
+
+	// GVK is the kind of the object.
+	GVK schema.GroupVersionKind
+
+	// Timestamp is when the event was received.
+	Timestamp time.Time
+
+	// LastSeen is when Old was received. It is zero if Old is nil.
+	LastSeen time.Time
+
+	// Changes counts the updates to the object during this session,
+	// including this event.
+	Changes int
+
+	// Reconnect is set to the number of the reconnect for the first event
+	// after a watch had to be re-established, zero otherwise.
+	Reconnect int
+
+	// Alert describes how the health of the object changed, e.g.
+	// "recovered", if health checks are enabled (see Options.HealthChecks).
+	Alert string
+}
+
+// Object returns the most recent state of the object, i.e. New or, for
+// deletions, Old.
+func (e Event) Object() *unstructured.Unstructured {
+	if e.New != nil {
+		return e.New
+	}
+
+	return e.Old
+}
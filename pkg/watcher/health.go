@@ -0,0 +1,132 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// anyKind is the kind of the health checks that apply to all kinds without
+// their own checks.
+const anyKind = "*"
+
+// BuiltinHealthChecks are the conditions that mark common kinds as
+// unhealthy, in the form "KIND:PATH=VALUE". Other kinds are unhealthy if
+// their Ready condition is False.
+var BuiltinHealthChecks = []string{
+	`Pod:status.phase=Failed`,
+	`Pod:{.status.containerStatuses[*].state.waiting.reason}=CrashLoopBackOff`,
+	`Pod:{.status.containerStatuses[*].state.waiting.reason}=ImagePullBackOff`,
+	`Pod:{.status.containerStatuses[*].state.waiting.reason}=ErrImagePull`,
+	`Deployment:{.status.conditions[?(@.type=="Available")].status}=False`,
+	`Deployment:{.status.conditions[?(@.type=="Progressing")].status}=False`,
+	`Job:{.status.conditions[?(@.type=="Failed")].status}=True`,
+	`Node:{.status.conditions[?(@.type=="Ready")].status}=False`,
+	`Node:{.status.conditions[?(@.type=="Ready")].status}=Unknown`,
+	`PersistentVolumeClaim:status.phase=Lost`,
+	`PersistentVolume:status.phase=Failed`,
+	`*:{.status.conditions[?(@.type=="Ready")].status}=False`,
+}
+
+// HealthChecks are the conditions that mark objects as unhealthy, by kind.
+type HealthChecks map[string][]*Condition
+
+// NewHealthChecks returns the BuiltinHealthChecks, replacing those of every
+// kind for which custom checks in the form "KIND:PATH=VALUE" are given.
+func NewHealthChecks(custom []string) (HealthChecks, error) {
+	checks := HealthChecks{}
+	for _, check := range BuiltinHealthChecks {
+		if err := checks.add(check); err != nil {
+			return nil, fmt.Errorf("invalid built-in health check %q: %w", check, err)
+		}
+	}
+
+	replaced := map[string]bool{}
+	for _, check := range custom {
+		kind, _, _ := strings.Cut(check, ":")
+		kind = strings.TrimSpace(kind)
+
+		if !replaced[kind] {
+			delete(checks, kind)
+			replaced[kind] = true
+		}
+
+		if err := checks.add(check); err != nil {
+			return nil, err
+		}
+	}
+
+	return checks, nil
+}
+
+func (h HealthChecks) add(check string) error {
+	kind, condition, found := strings.Cut(check, ":")
+	kind = strings.TrimSpace(kind)
+	if !found || kind == "" {
+		return fmt.Errorf("health check %q must be in the form KIND:PATH=VALUE", check)
+	}
+
+	parsed, err := ParseCondition(condition)
+	if err != nil {
+		return err
+	}
+
+	h[kind] = append(h[kind], parsed)
+
+	return nil
+}
+
+// Unhealthy returns the first condition that marks the object as
+// unhealthy, or nil if the object is healthy.
+func (h HealthChecks) Unhealthy(obj *unstructured.Unstructured) *Condition {
+	conditions, ok := h[obj.GetKind()]
+	if !ok {
+		conditions = h[anyKind]
+	}
+
+	for _, condition := range conditions {
+		if condition.Matches(obj) {
+			return condition
+		}
+	}
+
+	return nil
+}
+
+// healthChanged returns true if the object became unhealthy or recovered
+// with this event and describes the transition in the event's Alert. The
+// previous health is determined from the last known state of the object.
+func (w *Watcher) healthChanged(event *Event) bool {
+	if w.opt.HealthChecks == nil {
+		return true
+	}
+
+	var wasUnhealthy, isUnhealthy *Condition
+	if event.Old != nil {
+		wasUnhealthy = w.opt.HealthChecks.Unhealthy(event.Old)
+	}
+	if event.New != nil {
+		isUnhealthy = w.opt.HealthChecks.Unhealthy(event.New)
+	}
+
+	switch {
+	case event.Type == watch.Deleted && wasUnhealthy != nil:
+		event.Alert = "deleted while unhealthy"
+
+	case event.Type == watch.Deleted:
+		return false
+
+	case wasUnhealthy == nil && isUnhealthy != nil:
+		event.Alert = fmt.Sprintf("unhealthy: %s", isUnhealthy)
+
+	case wasUnhealthy != nil && isUnhealthy == nil:
+		event.Alert = "recovered"
+
+	default:
+		return false
+	}
+
+	return true
+}
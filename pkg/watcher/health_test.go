@@ -0,0 +1,133 @@
+package watcher
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func healthTestObject(kind string, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": "test"},
+			"status":     status,
+		},
+	}
+}
+
+func TestHealthChanged(t *testing.T) {
+	running := map[string]interface{}{"phase": "Running"}
+	failed := map[string]interface{}{"phase": "Failed"}
+	notReady := map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False"},
+		},
+	}
+
+	testcases := []struct {
+		name      string
+		eventType watch.EventType
+		old       *unstructured.Unstructured
+		new       *unstructured.Unstructured
+		custom    []string
+		expected  string
+		shown     bool
+	}{
+		{
+			name:      "created healthy",
+			eventType: watch.Added,
+			new:       healthTestObject("Pod", running),
+			shown:     false,
+		},
+		{
+			name:      "created unhealthy",
+			eventType: watch.Added,
+			new:       healthTestObject("Pod", failed),
+			expected:  "unhealthy: {.status.phase}=Failed",
+			shown:     true,
+		},
+		{
+			name:      "became unhealthy",
+			eventType: watch.Modified,
+			old:       healthTestObject("Pod", running),
+			new:       healthTestObject("Pod", failed),
+			expected:  "unhealthy: {.status.phase}=Failed",
+			shown:     true,
+		},
+		{
+			name:      "stays unhealthy",
+			eventType: watch.Modified,
+			old:       healthTestObject("Pod", failed),
+			new:       healthTestObject("Pod", failed),
+			shown:     false,
+		},
+		{
+			name:      "recovered",
+			eventType: watch.Modified,
+			old:       healthTestObject("Pod", failed),
+			new:       healthTestObject("Pod", running),
+			expected:  "recovered",
+			shown:     true,
+		},
+		{
+			name:      "deleted while unhealthy",
+			eventType: watch.Deleted,
+			old:       healthTestObject("Pod", failed),
+			expected:  "deleted while unhealthy",
+			shown:     true,
+		},
+		{
+			name:      "deleted while healthy",
+			eventType: watch.Deleted,
+			old:       healthTestObject("Pod", running),
+			shown:     false,
+		},
+		{
+			name:      "other kinds use the Ready condition",
+			eventType: watch.Modified,
+			old:       healthTestObject("Certificate", map[string]interface{}{}),
+			new:       healthTestObject("Certificate", notReady),
+			expected:  `unhealthy: {.status.conditions[?(@.type=="Ready")].status}=False`,
+			shown:     true,
+		},
+		{
+			name:      "custom checks replace the built-in ones",
+			eventType: watch.Modified,
+			old:       healthTestObject("Pod", running),
+			new:       healthTestObject("Pod", failed),
+			custom:    []string{"Pod:status.phase=Pending"},
+			shown:     false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			checks, err := NewHealthChecks(testcase.custom)
+			if err != nil {
+				t.Fatalf("failed to create health checks: %v", err)
+			}
+
+			w := NewWatcher(&Options{HealthChecks: checks})
+			event := Event{Type: testcase.eventType, Old: testcase.old, New: testcase.new}
+
+			if shown := w.healthChanged(&event); shown != testcase.shown {
+				t.Fatalf("Expected shown=%v, but got %v.", testcase.shown, shown)
+			}
+
+			if event.Alert != testcase.expected {
+				t.Errorf("Expected %q, but got %q.", testcase.expected, event.Alert)
+			}
+		})
+	}
+}
+
+func TestNewHealthChecksInvalid(t *testing.T) {
+	for _, check := range []string{"status.phase=Failed", ":status.phase=Failed", "Pod:status.phase"} {
+		if _, err := NewHealthChecks([]string{check}); err == nil {
+			t.Errorf("Expected an error for %q, but got none.", check)
+		}
+	}
+}
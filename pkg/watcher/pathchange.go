@@ -0,0 +1,64 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// PathChange is a JSONPath expression whose value must differ between the
+// old and new state of an object for an update to be published.
+type PathChange struct {
+	expr string
+	path *jsonpath.JSONPath
+}
+
+// ParsePathChange parses "spec.replicas" or "{.spec.replicas}".
+func ParsePathChange(s string) (*PathChange, error) {
+	expr := jsonPathExpression(s)
+
+	path := jsonpath.New("pathchange").AllowMissingKeys(true)
+	if err := path.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid JSON path %q: %w", s, err)
+	}
+
+	return &PathChange{
+		expr: expr,
+		path: path,
+	}, nil
+}
+
+func (p *PathChange) String() string {
+	return p.expr
+}
+
+// Changed returns true if the path yields different values for both
+// objects. A value that appears or disappears is a change, too.
+func (p *PathChange) Changed(oldObj, newObj *unstructured.Unstructured) bool {
+	return !reflect.DeepEqual(p.values(oldObj), p.values(newObj))
+}
+
+// values returns the JSON encoding of all values the path yields.
+func (p *PathChange) values(obj *unstructured.Unstructured) []string {
+	results, err := p.path.FindResults(obj.Object)
+	if err != nil {
+		return nil
+	}
+
+	values := []string{}
+	for _, result := range results {
+		for _, value := range result {
+			encoded, err := json.Marshal(value.Interface())
+			if err != nil {
+				encoded = []byte(fmt.Sprintf("%v", value.Interface()))
+			}
+
+			values = append(values, string(encoded))
+		}
+	}
+
+	return values
+}
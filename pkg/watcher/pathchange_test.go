@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestPathChangesMatch(t *testing.T) {
+	deployment := func(replicas int64, image string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("apps/v1")
+		obj.SetKind("Deployment")
+		obj.SetName("nginx")
+		_ = unstructured.SetNestedField(obj.Object, replicas, "spec", "replicas")
+		_ = unstructured.SetNestedField(obj.Object, image, "spec", "image")
+
+		return obj
+	}
+
+	paused := deployment(1, "nginx:1.22")
+	_ = unstructured.SetNestedField(paused.Object, true, "spec", "paused")
+
+	testcases := []struct {
+		name     string
+		paths    []string
+		all      bool
+		old      *unstructured.Unstructured
+		new      *unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name:     "changed path",
+			paths:    []string{"spec.replicas"},
+			old:      deployment(1, "nginx:1.22"),
+			new:      deployment(3, "nginx:1.22"),
+			expected: true,
+		},
+		{
+			name:     "unchanged path",
+			paths:    []string{"{.spec.replicas}"},
+			old:      deployment(1, "nginx:1.22"),
+			new:      deployment(1, "nginx:1.23"),
+			expected: false,
+		},
+		{
+			name:     "any of multiple paths",
+			paths:    []string{"spec.replicas", "spec.image"},
+			old:      deployment(1, "nginx:1.22"),
+			new:      deployment(1, "nginx:1.23"),
+			expected: true,
+		},
+		{
+			name:     "all of multiple paths",
+			paths:    []string{"spec.replicas", "spec.image"},
+			all:      true,
+			old:      deployment(1, "nginx:1.22"),
+			new:      deployment(1, "nginx:1.23"),
+			expected: false,
+		},
+		{
+			name:     "appearing value",
+			paths:    []string{"spec.paused"},
+			old:      deployment(1, "nginx:1.22"),
+			new:      paused,
+			expected: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pathChanges := []*PathChange{}
+			for _, path := range tc.paths {
+				pathChange, err := ParsePathChange(path)
+				if err != nil {
+					t.Fatalf("failed to parse %q: %v", path, err)
+				}
+
+				pathChanges = append(pathChanges, pathChange)
+			}
+
+			w := NewWatcher(&Options{PathChanges: pathChanges, PathChangesAll: tc.all})
+
+			if matches := w.pathChangesMatch(Event{Type: watch.Modified, Old: tc.old, New: tc.new}); matches != tc.expected {
+				t.Errorf("Expected %v, but got %v.", tc.expected, matches)
+			}
+		})
+	}
+}
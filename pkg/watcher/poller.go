@@ -0,0 +1,187 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+	"go.xrstf.de/stalk/pkg/objectkey"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// Poller emulates a watch by periodically listing all resources and
+// comparing them to the previous result. This is useful for resources
+// that do not support watching, like most aggregated APIs (e.g.
+// metrics.k8s.io).
+type Poller struct {
+	client   dynamic.ResourceInterface
+	opts     metav1.ListOptions
+	interval time.Duration
+	log      logrus.FieldLogger
+	result   chan watch.Event
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ watch.Interface = &Poller{}
+
+func NewPoller(ctx context.Context, client dynamic.ResourceInterface, opts metav1.ListOptions, interval time.Duration, log logrus.FieldLogger) *Poller {
+	return NewSeededPoller(ctx, client, opts, interval, nil, log)
+}
+
+// NewSeededPoller returns a Poller that already knows the objects in the
+// list, so that only changes to them are emitted.
+func NewSeededPoller(ctx context.Context, client dynamic.ResourceInterface, opts metav1.ListOptions, interval time.Duration, seed *unstructured.UnstructuredList, log logrus.FieldLogger) *Poller {
+	p := &Poller{
+		client:   client,
+		opts:     opts,
+		interval: interval,
+		log:      log,
+		result:   make(chan watch.Event),
+		stop:     make(chan struct{}),
+	}
+
+	go p.run(ctx, seed)
+
+	return p
+}
+
+func (p *Poller) ResultChan() <-chan watch.Event {
+	return p.result
+}
+
+func (p *Poller) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+func (p *Poller) run(ctx context.Context, seed *unstructured.UnstructuredList) {
+	defer close(p.result)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	known := map[string]*unstructured.Unstructured{}
+	if seed != nil {
+		for i := range seed.Items {
+			known[objectkey.Of(&seed.Items[i])] = &seed.Items[i]
+		}
+	}
+
+	for {
+		if !p.poll(ctx, known) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll lists all resources and emits events for every difference to the
+// known state. It returns false if the poller was stopped in the meantime.
+func (p *Poller) poll(ctx context.Context, known map[string]*unstructured.Unstructured) bool {
+	var list *unstructured.UnstructuredList
+
+	err := kubeutil.RetryOnThrottling(ctx, p.log, func() (err error) {
+		list, err = p.client.List(ctx, p.opts)
+		return err
+	})
+	if err != nil {
+		// report the error like a regular watch would and try again later
+		return p.sendError(ctx, err)
+	}
+
+	seen := map[string]struct{}{}
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+		key := objectkey.Of(obj)
+		seen[key] = struct{}{}
+
+		previous, exists := known[key]
+		known[key] = obj
+
+		switch {
+		case !exists:
+			if !p.send(ctx, watch.Added, obj) {
+				return false
+			}
+
+		case !objectsEqual(previous, obj):
+			if !p.send(ctx, watch.Modified, obj) {
+				return false
+			}
+		}
+	}
+
+	for key, obj := range known {
+		if _, exists := seen[key]; exists {
+			continue
+		}
+
+		delete(known, key)
+
+		if !p.send(ctx, watch.Deleted, obj) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Poller) send(ctx context.Context, eventType watch.EventType, obj *unstructured.Unstructured) bool {
+	select {
+	case p.result <- watch.Event{Type: eventType, Object: obj}:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-p.stop:
+		return false
+	}
+}
+
+func (p *Poller) sendError(ctx context.Context, err error) bool {
+	status := metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: fmt.Sprintf("failed to list resources: %v", err),
+	}
+
+	if apiStatus, ok := err.(apierrors.APIStatus); ok {
+		status = apiStatus.Status()
+	}
+
+	select {
+	case p.result <- watch.Event{Type: watch.Error, Object: &status}:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-p.stop:
+		return false
+	}
+}
+
+// objectsEqual compares resource versions if possible; many aggregated APIs
+// do not provide them, so a full comparison is used as the fallback.
+func objectsEqual(a, b *unstructured.Unstructured) bool {
+	if a.GetResourceVersion() != "" && b.GetResourceVersion() != "" {
+		return a.GetResourceVersion() == b.GetResourceVersion()
+	}
+
+	return equality.Semantic.DeepEqual(a.Object, b.Object)
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestSeededPoller(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	obj.SetNamespace("default")
+	obj.SetName("settings")
+	obj.Object["data"] = map[string]interface{}{"color": "blue"}
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr: "ConfigMapList",
+	}, obj)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resource := client.Resource(gvr).Namespace("default")
+
+	seed, err := resource.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list objects: %v", err)
+	}
+
+	p := NewSeededPoller(ctx, resource, metav1.ListOptions{}, 10*time.Millisecond, seed, logrus.New())
+	defer p.Stop()
+
+	updated := obj.DeepCopy()
+	updated.Object["data"] = map[string]interface{}{"color": "green"}
+
+	if _, err := resource.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update object: %v", err)
+	}
+
+	// the seeded object must not be reported as created
+	select {
+	case event := <-p.ResultChan():
+		if event.Type != watch.Modified {
+			t.Errorf("Expected %q event, but got %q.", watch.Modified, event.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event for the updated object, but got none.")
+	}
+}
@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ReplayStart determines the resource version from which a watch replays
+// the last changes to the n most recently changed objects in the list. All
+// objects that were not changed since then are returned as well, as they
+// are the known state before the replay.
+//
+// Resource versions are opaque, but in practice they are increasing
+// numbers. If they cannot be parsed, or if the list is empty, false is
+// returned.
+func ReplayStart(list *unstructured.UnstructuredList, n int) (string, *unstructured.UnstructuredList, bool) {
+	if n <= 0 || len(list.Items) == 0 {
+		return "", nil, false
+	}
+
+	versions := make([]uint64, len(list.Items))
+	for i, item := range list.Items {
+		version, err := strconv.ParseUint(item.GetResourceVersion(), 10, 64)
+		if err != nil {
+			return "", nil, false
+		}
+
+		versions[i] = version
+	}
+
+	sorted := append([]uint64{}, versions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+
+	if sorted[n-1] == 0 {
+		return "", nil, false
+	}
+
+	// start right before the n-th most recent change
+	start := sorted[n-1] - 1
+
+	unchanged := &unstructured.UnstructuredList{}
+	for i, item := range list.Items {
+		if versions[i] <= start {
+			unchanged.Items = append(unchanged.Items, item)
+		}
+	}
+
+	return strconv.FormatUint(start, 10), unchanged, true
+}
@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func replayList(versions ...string) *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+
+	for i, version := range versions {
+		obj := unstructured.Unstructured{}
+		obj.SetName(string(rune('a' + i)))
+		obj.SetResourceVersion(version)
+
+		list.Items = append(list.Items, obj)
+	}
+
+	return list
+}
+
+func TestReplayStart(t *testing.T) {
+	testcases := []struct {
+		name      string
+		list      *unstructured.UnstructuredList
+		n         int
+		ok        bool
+		start     string
+		unchanged []string
+	}{
+		{
+			name:      "last two changes",
+			list:      replayList("10", "40", "20", "30"),
+			n:         2,
+			ok:        true,
+			start:     "29",
+			unchanged: []string{"a", "c"},
+		},
+		{
+			name:      "more changes than objects",
+			list:      replayList("10", "20"),
+			n:         5,
+			ok:        true,
+			start:     "9",
+			unchanged: nil,
+		},
+		{
+			name: "empty list",
+			list: replayList(),
+			n:    1,
+			ok:   false,
+		},
+		{
+			name: "opaque resource versions",
+			list: replayList("10", "abc"),
+			n:    1,
+			ok:   false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			start, unchanged, ok := ReplayStart(testcase.list, testcase.n)
+			if ok != testcase.ok {
+				t.Fatalf("Expected ok=%v, but got %v.", testcase.ok, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if start != testcase.start {
+				t.Errorf("Expected start %q, but got %q.", testcase.start, start)
+			}
+
+			names := []string{}
+			for _, item := range unchanged.Items {
+				names = append(names, item.GetName())
+			}
+
+			if len(names) != len(testcase.unchanged) {
+				t.Fatalf("Expected unchanged objects %v, but got %v.", testcase.unchanged, names)
+			}
+
+			for i := range names {
+				if names[i] != testcase.unchanged[i] {
+					t.Errorf("Expected unchanged objects %v, but got %v.", testcase.unchanged, names)
+					break
+				}
+			}
+		})
+	}
+}
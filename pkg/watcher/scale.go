@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ScaleFunc returns the scale subresource for the given object.
+type ScaleFunc func(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+
+// ScaleTransform replaces every scalable object (like a Deployment) with its
+// scale subresource. This reduces the diffs to just the replica counts and
+// selectors. Since the scale subresource cannot be watched itself, it is
+// fetched whenever the parent object changes.
+func ScaleTransform(getScale ScaleFunc, log logrus.FieldLogger) TransformFunc {
+	return func(ctx context.Context, eventType watch.EventType, obj *unstructured.Unstructured) *unstructured.Unstructured {
+		// deleted objects have no scale subresource anymore
+		if eventType == watch.Deleted {
+			return ScaleFromObject(obj)
+		}
+
+		scale, err := getScale(ctx, obj)
+		if err != nil {
+			log.WithField("name", obj.GetName()).Debugf("Failed to fetch scale subresource: %v", err)
+			return ScaleFromObject(obj)
+		}
+
+		return scale
+	}
+}
+
+// ScaleFromObject constructs an autoscaling/v1 Scale from the common replica
+// fields of a scalable object.
+func ScaleFromObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	scale := &unstructured.Unstructured{}
+	scale.SetAPIVersion("autoscaling/v1")
+	scale.SetKind("Scale")
+	scale.SetName(obj.GetName())
+	scale.SetNamespace(obj.GetNamespace())
+	scale.SetUID(obj.GetUID())
+	scale.SetResourceVersion(obj.GetResourceVersion())
+	scale.SetCreationTimestamp(obj.GetCreationTimestamp())
+
+	if replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+		_ = unstructured.SetNestedField(scale.Object, replicas, "spec", "replicas")
+	}
+
+	if replicas, found, _ := unstructured.NestedInt64(obj.Object, "status", "replicas"); found {
+		_ = unstructured.SetNestedField(scale.Object, replicas, "status", "replicas")
+	}
+
+	return scale
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	SortByName     = "name"
+	SortByCreation = "creation"
+)
+
+// SortOrders are all supported values for SortObjects.
+var SortOrders = []string{SortByName, SortByCreation}
+
+func IsValidSortOrder(order string) bool {
+	if order == "" {
+		return true
+	}
+
+	for _, o := range SortOrders {
+		if o == order {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SortObjects sorts the objects in-place by the given order. An empty order
+// keeps the objects as they are.
+func SortObjects(objects []unstructured.Unstructured, order string) {
+	switch order {
+	case SortByName:
+		sort.SliceStable(objects, func(i, j int) bool {
+			return lessByName(&objects[i], &objects[j])
+		})
+
+	case SortByCreation:
+		sort.SliceStable(objects, func(i, j int) bool {
+			a := objects[i].GetCreationTimestamp()
+			b := objects[j].GetCreationTimestamp()
+
+			if a.Equal(&b) {
+				return lessByName(&objects[i], &objects[j])
+			}
+
+			return a.Before(&b)
+		})
+	}
+}
+
+func lessByName(a, b *unstructured.Unstructured) bool {
+	if a.GetNamespace() != b.GetNamespace() {
+		return a.GetNamespace() < b.GetNamespace()
+	}
+
+	return a.GetName() < b.GetName()
+}
@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// TransformFunc replaces or modifies an object before it is processed, e.g.
+// to enrich it with information from related objects.
+type TransformFunc func(ctx context.Context, eventType watch.EventType, obj *unstructured.Unstructured) *unstructured.Unstructured
+
+// ChainTransforms applies all given transformations in order. If none are
+// given, nil is returned.
+func ChainTransforms(transforms ...TransformFunc) TransformFunc {
+	if len(transforms) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, eventType watch.EventType, obj *unstructured.Unstructured) *unstructured.Unstructured {
+		for _, transform := range transforms {
+			obj = transform(ctx, eventType, obj)
+		}
+
+		return obj
+	}
+}
+
+// TransformList applies the transformation to all objects in the list, as
+// if they had just been created.
+func TransformList(ctx context.Context, list *unstructured.UnstructuredList, transform TransformFunc) {
+	for i := range list.Items {
+		list.Items[i] = *transform(ctx, watch.Added, &list.Items[i])
+	}
+}
+
+// TransformWatch wraps a watch and applies a transformation to every
+// object it returns.
+type TransformWatch struct {
+	inner     watch.Interface
+	transform TransformFunc
+	result    chan watch.Event
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+var _ watch.Interface = &TransformWatch{}
+
+func NewTransformWatch(ctx context.Context, inner watch.Interface, transform TransformFunc) *TransformWatch {
+	t := &TransformWatch{
+		inner:     inner,
+		transform: transform,
+		result:    make(chan watch.Event),
+		stop:      make(chan struct{}),
+	}
+
+	go t.run(ctx)
+
+	return t
+}
+
+func (t *TransformWatch) ResultChan() <-chan watch.Event {
+	return t.result
+}
+
+func (t *TransformWatch) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+		t.inner.Stop()
+	})
+}
+
+func (t *TransformWatch) run(ctx context.Context) {
+	defer close(t.result)
+
+	for event := range t.inner.ResultChan() {
+		// errors are reported as Status objects, which must be passed on as-is
+		if obj, ok := event.Object.(*unstructured.Unstructured); ok && event.Type != watch.Error {
+			event.Object = t.transform(ctx, event.Type, obj)
+		}
+
+		select {
+		case t.result <- event:
+		case <-t.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// volumeCacheTTL is how long a looked up PersistentVolume is reused, so
+// that a burst of updates to a claim does not cause a burst of lookups.
+const volumeCacheTTL = 5 * time.Second
+
+// VolumeFunc returns the PersistentVolume with the given name.
+type VolumeFunc func(ctx context.Context, name string) (*unstructured.Unstructured, error)
+
+type cachedVolume struct {
+	volume  *unstructured.Unstructured
+	fetched time.Time
+}
+
+// BoundVolumeTransform adds the most relevant fields of the PersistentVolume
+// that a PersistentVolumeClaim is bound to as a "boundVolume" field to the
+// claim, so that changes to both objects are shown together.
+func BoundVolumeTransform(getVolume VolumeFunc, log logrus.FieldLogger) TransformFunc {
+	lock := sync.Mutex{}
+	cache := map[string]cachedVolume{}
+
+	lookup := func(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if cached, ok := cache[name]; ok && time.Since(cached.fetched) < volumeCacheTTL {
+			return cached.volume, nil
+		}
+
+		volume, err := getVolume(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[name] = cachedVolume{volume: volume, fetched: time.Now()}
+
+		return volume, nil
+	}
+
+	return func(ctx context.Context, eventType watch.EventType, obj *unstructured.Unstructured) *unstructured.Unstructured {
+		if obj.GetKind() != "PersistentVolumeClaim" || eventType == watch.Deleted {
+			return obj
+		}
+
+		volumeName, _, _ := unstructured.NestedString(obj.Object, "spec", "volumeName")
+		if volumeName == "" {
+			return obj
+		}
+
+		volume, err := lookup(ctx, volumeName)
+		if err != nil {
+			log.WithField("volume", volumeName).Debugf("Failed to fetch PersistentVolume: %v", err)
+			return obj
+		}
+
+		summary := map[string]interface{}{
+			"name": volumeName,
+		}
+
+		for key, path := range map[string][]string{
+			"phase":         {"status", "phase"},
+			"capacity":      {"spec", "capacity", "storage"},
+			"reclaimPolicy": {"spec", "persistentVolumeReclaimPolicy"},
+			"storageClass":  {"spec", "storageClassName"},
+		} {
+			if value, found, _ := unstructured.NestedString(volume.Object, path...); found {
+				summary[key] = value
+			}
+		}
+
+		enriched := obj.DeepCopy()
+		enriched.Object["boundVolume"] = summary
+
+		return enriched
+	}
+}
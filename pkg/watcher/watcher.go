@@ -2,49 +2,407 @@ package watcher
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	"go.xrstf.de/stalk/pkg/diff"
+	"go.xrstf.de/stalk/pkg/cache"
+	"go.xrstf.de/stalk/pkg/managedfields"
+	"go.xrstf.de/stalk/pkg/objectkey"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
+type Options struct {
+	// Namespaces and ResourceNames limit the objects to those matching any
+	// of the given names or glob expressions. Empty lists match everything.
+	Namespaces    []string
+	ResourceNames []string
+
+	// IncludeNamespaces and ExcludeNamespaces further limit the objects to
+	// those in any of the included and none of the excluded namespaces.
+	// Both support glob expressions; an empty include list matches
+	// everything.
+	IncludeNamespaces []string
+	ExcludeNamespaces []string
+
+	// EventTypes limits the published events to the given types. If empty,
+	// all events are published.
+	EventTypes []watch.EventType
+
+	// ChangedBy limits updates to those made by a field manager matching
+	// this name or glob expression, based on the objects' managedFields.
+	// Creations and deletions are not affected.
+	ChangedBy string
+
+	// GenerationOnly limits updates to those that changed the generation,
+	// i.e. the spec, of an object. Objects without a generation are not
+	// affected.
+	GenerationOnly bool
+
+	// PathChanges limits updates to those that changed the value of any
+	// (or, if PathChangesAll is set, all) of these paths. Creations and
+	// deletions are not affected.
+	PathChanges    []*PathChange
+	PathChangesAll bool
+
+	// HealthChecks, if set, limits the events to those where an object
+	// became unhealthy or recovered, i.e. the first or last event of an
+	// incident. Such events are described in their Alert.
+	HealthChecks HealthChecks
+
+	// UID limits the objects to the one with exactly this UID. This allows
+	// to follow a single incarnation of an object that is recreated with the
+	// same name.
+	UID types.UID
+
+	// Until are conditions that end the session once an object fulfills
+	// any (or, if UntilAll is set, all) of them. Stop, if set, is then
+	// called after the event has been published.
+	Until    []*Condition
+	UntilAll bool
+	Stop     func()
+
+	// OnError is called for every error reported by a watch. If nil,
+	// errors are ignored.
+	OnError func(err error)
+
+	// SnapshotProgress, if set, is called after each object of a snapshot
+	// has been processed, regardless of whether it produced an event.
+	SnapshotProgress func()
+}
+
 type Watcher struct {
-	printer       *diff.Printer
-	namespaces    []string
-	resourceNames []string
+	opt    *Options
+	cache  *cache.ResourceCache
+	events chan Event
+
+	// foundNames are the ResourceNames that any object matched so far.
+	foundNames map[string]struct{}
+	namesLock  sync.Mutex
+
+	// reconnects counts the re-established watches; pendingReconnect is
+	// the number of the last one until it has been attached to an event.
+	reconnects       int
+	pendingReconnect int
+	reconnectLock    sync.Mutex
 }
 
-func NewWatcher(printer *diff.Printer, namespaces, resourceNames []string) *Watcher {
+func NewWatcher(opt *Options) *Watcher {
 	return &Watcher{
-		printer:       printer,
-		namespaces:    namespaces,
-		resourceNames: resourceNames,
+		opt:        opt,
+		cache:      cache.NewCache(),
+		events:     make(chan Event),
+		foundNames: map[string]struct{}{},
 	}
 }
 
+// Events returns the channel on which all changes to matching objects are
+// published. The channel is unbuffered, so it must be consumed for the
+// watcher to make progress. It is closed by Close().
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close closes the events channel. It must be called exactly once, after
+// all calls to Watch, Snapshot and Process have returned.
+func (w *Watcher) Close() {
+	close(w.events)
+}
+
+// Watch processes all events from the given watch until it is closed.
 func (w *Watcher) Watch(ctx context.Context, wi watch.Interface) {
 	for event := range wi.ResultChan() {
+		switch event.Type {
+		case watch.Error:
+			// the event carries a Status describing why the watch failed
+			if w.opt.OnError != nil {
+				w.opt.OnError(apierrors.FromObject(event.Object))
+			}
+
+			continue
+
+		case watch.Bookmark:
+			// bookmarks only carry a resource version to resume the watch
+			// from, but closed watches are re-established by listing again
+			continue
+		}
+
 		obj, ok := event.Object.(*unstructured.Unstructured)
 		if !ok {
 			continue
 		}
 
-		if w.resourceNameMatches(obj) && w.resourceNamespaceMatches(obj) {
-			w.printer.Print(obj, event.Type)
+		w.Process(event.Type, obj)
+	}
+}
+
+// Snapshot processes all objects in the list as if they had just been
+// created.
+func (w *Watcher) Snapshot(list *unstructured.UnstructuredList) {
+	for i := range list.Items {
+		w.Process(watch.Added, &list.Items[i])
+
+		if w.opt.SnapshotProgress != nil {
+			w.opt.SnapshotProgress()
 		}
 	}
 }
 
+// Prime stores the objects in the list as their known state, without
+// publishing any events. Later changes are then shown as diffs against
+// this state.
+func (w *Watcher) Prime(list *unstructured.UnstructuredList) {
+	for i := range list.Items {
+		w.cache.Set(&list.Items[i])
+	}
+}
+
+// Objects returns copies of the last known state of all objects.
+func (w *Watcher) Objects() []*unstructured.Unstructured {
+	return w.cache.Objects()
+}
+
+// Found records which of the ResourceNames the listed objects match. It
+// is used to tell which names are still missing after the initial lists.
+func (w *Watcher) Found(list *unstructured.UnstructuredList) {
+	w.namesLock.Lock()
+	defer w.namesLock.Unlock()
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+
+		if w.resourceNamespaceMatches(obj) && w.namespaceListsMatch(obj) {
+			w.markFound(obj.GetName())
+		}
+	}
+}
+
+// MissingNames returns the ResourceNames that no object has matched yet.
+func (w *Watcher) MissingNames() []string {
+	w.namesLock.Lock()
+	defer w.namesLock.Unlock()
+
+	missing := []string{}
+	for _, name := range w.opt.ResourceNames {
+		if _, found := w.foundNames[name]; !found {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+func (w *Watcher) markFound(name string) {
+	for _, wantedName := range w.opt.ResourceNames {
+		if nameMatches(name, wantedName) {
+			w.foundNames[wantedName] = struct{}{}
+		}
+	}
+}
+
+// Reconnected records that a watch had to be re-established and returns
+// how many times this has happened so far. The next published event is
+// marked with this number.
+func (w *Watcher) Reconnected() int {
+	w.reconnectLock.Lock()
+	defer w.reconnectLock.Unlock()
+
+	w.reconnects++
+	w.pendingReconnect = w.reconnects
+
+	return w.reconnects
+}
+
+// Resync processes a fresh list of objects after a watch had to be
+// re-established, to catch up on the changes that were missed in between:
+// changed objects are published as updates, unknown ones as creations and
+// known objects that are missing from the list as deletions. Only objects
+// whose qualified keys are in known, i.e. that the closed watch had seen,
+// can be deleted, as the list does not contain the objects of other
+// watches.
+func (w *Watcher) Resync(list *unstructured.UnstructuredList, known map[string]struct{}) {
+	listed := map[string]struct{}{}
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+		listed[objectkey.Qualified(obj)] = struct{}{}
+
+		cached, _ := w.cache.Get(obj)
+		switch {
+		case cached == nil:
+			w.Process(watch.Added, obj)
+		case cached.GetResourceVersion() != obj.GetResourceVersion():
+			w.Process(watch.Modified, obj)
+		}
+	}
+
+	for _, cached := range w.cache.Objects() {
+		key := objectkey.Qualified(cached)
+
+		if _, seen := known[key]; !seen {
+			continue
+		}
+
+		if _, exists := listed[key]; !exists {
+			w.Process(watch.Deleted, cached)
+		}
+	}
+}
+
+// takeReconnect returns the number of the last reconnect, if it has not
+// yet been attached to an event.
+func (w *Watcher) takeReconnect() int {
+	w.reconnectLock.Lock()
+	defer w.reconnectLock.Unlock()
+
+	reconnect := w.pendingReconnect
+	w.pendingReconnect = 0
+
+	return reconnect
+}
+
+// Process publishes an event for the object, if it matches the configured
+// names and namespaces. The previously known state of the object is
+// included in the event.
+func (w *Watcher) Process(eventType watch.EventType, obj *unstructured.Unstructured) {
+	if !w.resourceNameMatches(obj) || !w.resourceNamespaceMatches(obj) || !w.namespaceListsMatch(obj) || !w.uidMatches(obj) {
+		return
+	}
+
+	event := Event{
+		Type:      eventType,
+		Key:       objectkey.Of(obj),
+		GVK:       obj.GroupVersionKind(),
+		Timestamp: time.Now(),
+	}
+
+	switch eventType {
+	case watch.Added:
+		event.New = obj
+		w.cache.Set(obj)
+
+	case watch.Modified:
+		event.Old, event.LastSeen = w.cache.Get(obj)
+		event.New = obj
+		w.cache.Set(obj)
+		event.Changes = w.cache.Changes(obj)
+
+	case watch.Deleted:
+		event.Old = obj
+		event.LastSeen = event.Timestamp
+		w.cache.Delete(obj)
+
+	default:
+		return
+	}
+
+	// the cache must be updated regardless, so that future diffs are correct
+	if !w.eventTypeMatches(eventType) || !w.changedByMatches(event) || !w.generationChanged(event) || !w.pathChangesMatch(event) || !w.healthChanged(&event) {
+		return
+	}
+
+	event.Reconnect = w.takeReconnect()
+
+	w.events <- event
+
+	if w.opt.Stop != nil && event.New != nil && w.conditionsMet(event.New) {
+		w.opt.Stop()
+	}
+}
+
+func (w *Watcher) conditionsMet(obj *unstructured.Unstructured) bool {
+	if len(w.opt.Until) == 0 {
+		return false
+	}
+
+	for _, condition := range w.opt.Until {
+		matches := condition.Matches(obj)
+
+		if matches && !w.opt.UntilAll {
+			return true
+		}
+
+		if !matches && w.opt.UntilAll {
+			return false
+		}
+	}
+
+	return w.opt.UntilAll
+}
+
+func (w *Watcher) eventTypeMatches(eventType watch.EventType) bool {
+	if len(w.opt.EventTypes) == 0 {
+		return true
+	}
+
+	for _, t := range w.opt.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Watcher) changedByMatches(event Event) bool {
+	if w.opt.ChangedBy == "" || event.Type != watch.Modified || event.Old == nil {
+		return true
+	}
+
+	for _, manager := range managedfields.Changers(event.Old, event.New) {
+		if nameMatches(manager, w.opt.ChangedBy) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Watcher) generationChanged(event Event) bool {
+	if !w.opt.GenerationOnly || event.Type != watch.Modified || event.Old == nil {
+		return true
+	}
+
+	return event.New.GetGeneration() == 0 || event.Old.GetGeneration() != event.New.GetGeneration()
+}
+
+func (w *Watcher) pathChangesMatch(event Event) bool {
+	if len(w.opt.PathChanges) == 0 || event.Type != watch.Modified || event.Old == nil {
+		return true
+	}
+
+	for _, pathChange := range w.opt.PathChanges {
+		changed := pathChange.Changed(event.Old, event.New)
+
+		if changed && !w.opt.PathChangesAll {
+			return true
+		}
+
+		if !changed && w.opt.PathChangesAll {
+			return false
+		}
+	}
+
+	return w.opt.PathChangesAll
+}
+
+func (w *Watcher) uidMatches(obj *unstructured.Unstructured) bool {
+	return w.opt.UID == "" || obj.GetUID() == w.opt.UID
+}
+
 func (w *Watcher) resourceNameMatches(obj *unstructured.Unstructured) bool {
 	// no names given, so all resources match
-	if len(w.resourceNames) == 0 {
+	if len(w.opt.ResourceNames) == 0 {
 		return true
 	}
 
-	for _, wantedName := range w.resourceNames {
+	for _, wantedName := range w.opt.ResourceNames {
 		if nameMatches(obj.GetName(), wantedName) {
 			return true
 		}
@@ -55,12 +413,26 @@ func (w *Watcher) resourceNameMatches(obj *unstructured.Unstructured) bool {
 
 func (w *Watcher) resourceNamespaceMatches(obj *unstructured.Unstructured) bool {
 	// no namespaces given, so all resources match
-	if len(w.namespaces) == 0 {
+	if len(w.opt.Namespaces) == 0 {
 		return true
 	}
 
-	for _, wantedNamespace := range w.namespaces {
-		if nameMatches(obj.GetNamespace(), wantedNamespace) {
+	return anyNameMatches(obj.GetNamespace(), w.opt.Namespaces)
+}
+
+func (w *Watcher) namespaceListsMatch(obj *unstructured.Unstructured) bool {
+	namespace := obj.GetNamespace()
+
+	if len(w.opt.IncludeNamespaces) > 0 && !anyNameMatches(namespace, w.opt.IncludeNamespaces) {
+		return false
+	}
+
+	return !anyNameMatches(namespace, w.opt.ExcludeNamespaces)
+}
+
+func anyNameMatches(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if nameMatches(name, pattern) {
 			return true
 		}
 	}
@@ -76,3 +448,23 @@ func nameMatches(name string, pattern string) bool {
 
 	return name == pattern
 }
+
+// ParseEventTypes turns user-friendly names like "created" into event types.
+func ParseEventTypes(names []string) ([]watch.EventType, error) {
+	types := []watch.EventType{}
+
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "created", "added":
+			types = append(types, watch.Added)
+		case "modified", "updated":
+			types = append(types, watch.Modified)
+		case "deleted":
+			types = append(types, watch.Deleted)
+		default:
+			return nil, fmt.Errorf("unknown event type %q, must be one of created, modified, deleted", name)
+		}
+	}
+
+	return types, nil
+}
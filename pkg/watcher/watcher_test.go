@@ -0,0 +1,348 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.xrstf.de/stalk/pkg/objectkey"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestResync(t *testing.T) {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	newObject := func(namespace, name, version string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		obj.SetResourceVersion(version)
+
+		return obj
+	}
+
+	w := NewWatcher(&Options{})
+
+	events := []string{}
+	done := make(chan struct{})
+
+	go func() {
+		for event := range w.Events() {
+			events = append(events, fmt.Sprintf("%s %s reconnect=%d", event.Type, event.Key, event.Reconnect))
+		}
+		close(done)
+	}()
+
+	w.Snapshot(&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{
+			newObject("default", "changed", "1"),
+			newObject("default", "unchanged", "1"),
+			newObject("default", "gone", "1"),
+			newObject("other", "elsewhere", "1"),
+		},
+	})
+
+	w.Reconnected()
+
+	// objects from other namespaces are not part of the list and must not
+	// be considered deleted, as they were not seen by the same watch
+	known := map[string]struct{}{}
+	for _, name := range []string{"changed", "unchanged", "gone"} {
+		obj := newObject("default", name, "1")
+		known[objectkey.Qualified(&obj)] = struct{}{}
+	}
+
+	w.Resync(&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{
+			newObject("default", "changed", "2"),
+			newObject("default", "unchanged", "1"),
+			newObject("default", "new", "3"),
+		},
+	}, known)
+
+	w.Close()
+	<-done
+
+	expected := []string{
+		"ADDED default/changed reconnect=0",
+		"ADDED default/unchanged reconnect=0",
+		"ADDED default/gone reconnect=0",
+		"ADDED other/elsewhere reconnect=0",
+		"MODIFIED default/changed reconnect=1",
+		"ADDED default/new reconnect=0",
+		"DELETED default/gone reconnect=0",
+	}
+
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but got %v.", expected, events)
+	}
+}
+
+func TestNamespaceListsMatch(t *testing.T) {
+	testcases := []struct {
+		name      string
+		include   []string
+		exclude   []string
+		namespace string
+		expected  bool
+	}{
+		{
+			name:      "no lists",
+			namespace: "kube-system",
+			expected:  true,
+		},
+		{
+			name:      "excluded",
+			exclude:   []string{"kube-system", "monitoring"},
+			namespace: "kube-system",
+			expected:  false,
+		},
+		{
+			name:      "excluded by glob",
+			exclude:   []string{"kube-*"},
+			namespace: "kube-public",
+			expected:  false,
+		},
+		{
+			name:      "not excluded",
+			exclude:   []string{"kube-*"},
+			namespace: "default",
+			expected:  true,
+		},
+		{
+			name:      "included",
+			include:   []string{"team-*"},
+			namespace: "team-a",
+			expected:  true,
+		},
+		{
+			name:      "not included",
+			include:   []string{"team-*"},
+			namespace: "default",
+			expected:  false,
+		},
+		{
+			name:      "included but excluded",
+			include:   []string{"team-*"},
+			exclude:   []string{"team-legacy"},
+			namespace: "team-legacy",
+			expected:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWatcher(&Options{
+				IncludeNamespaces: tc.include,
+				ExcludeNamespaces: tc.exclude,
+			})
+
+			obj := &unstructured.Unstructured{}
+			obj.SetNamespace(tc.namespace)
+
+			if result := w.namespaceListsMatch(obj); result != tc.expected {
+				t.Errorf("Expected %v, but got %v.", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestMissingNames(t *testing.T) {
+	newObject := func(namespace, name string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{}
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+
+		return obj
+	}
+
+	w := NewWatcher(&Options{
+		Namespaces:    []string{"default"},
+		ResourceNames: []string{"foo", "bar", "web-*", "elsewhere"},
+	})
+
+	// names can belong to different kinds
+	w.Found(&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{newObject("default", "foo")},
+	})
+	w.Found(&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{
+			newObject("default", "web-1"),
+			newObject("other", "elsewhere"),
+		},
+	})
+
+	expected := []string{"bar", "elsewhere"}
+	if missing := w.MissingNames(); fmt.Sprint(missing) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but got %v.", expected, missing)
+	}
+}
+
+func TestWatchSpecialEvents(t *testing.T) {
+	errors := []string{}
+
+	w := NewWatcher(&Options{
+		OnError: func(err error) {
+			errors = append(errors, err.Error())
+		},
+	})
+
+	events := []string{}
+	done := make(chan struct{})
+
+	go func() {
+		for event := range w.Events() {
+			events = append(events, fmt.Sprintf("%s %s", event.Type, event.Key))
+		}
+		close(done)
+	}()
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("settings")
+
+	bookmark := &unstructured.Unstructured{}
+	bookmark.SetAPIVersion("v1")
+	bookmark.SetKind("ConfigMap")
+	bookmark.SetResourceVersion("42")
+
+	fakeWatch := watch.NewFake()
+	go func() {
+		fakeWatch.Add(obj)
+		fakeWatch.Action(watch.Bookmark, bookmark)
+		fakeWatch.Error(&metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: "too old resource version",
+			Reason:  metav1.StatusReasonExpired,
+			Code:    410,
+		})
+		fakeWatch.Stop()
+	}()
+
+	w.Watch(context.Background(), fakeWatch)
+	w.Close()
+	<-done
+
+	expected := []string{"ADDED default/settings"}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("Expected events %v, but got %v.", expected, events)
+	}
+
+	expected = []string{"too old resource version"}
+	if fmt.Sprint(errors) != fmt.Sprint(expected) {
+		t.Errorf("Expected errors %v, but got %v.", expected, errors)
+	}
+}
+
+func TestGenerationOnly(t *testing.T) {
+	newObject := func(kind string, generation int64) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace("default")
+		obj.SetName("test")
+		obj.SetGeneration(generation)
+
+		return obj
+	}
+
+	testcases := []struct {
+		name     string
+		old      *unstructured.Unstructured
+		new      *unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name:     "generation changed",
+			old:      newObject("Deployment", 1),
+			new:      newObject("Deployment", 2),
+			expected: true,
+		},
+		{
+			name:     "status-only update",
+			old:      newObject("Deployment", 2),
+			new:      newObject("Deployment", 2),
+			expected: false,
+		},
+		{
+			name:     "kind without generation",
+			old:      newObject("ConfigMap", 0),
+			new:      newObject("ConfigMap", 0),
+			expected: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			w := NewWatcher(&Options{GenerationOnly: true})
+
+			event := Event{Type: watch.Modified, Old: testcase.old, New: testcase.new}
+			if actual := w.generationChanged(event); actual != testcase.expected {
+				t.Errorf("Expected %v, but got %v.", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUntil(t *testing.T) {
+	condition, err := ParseCondition("status.phase=Running")
+	if err != nil {
+		t.Fatalf("failed to parse condition: %v", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Pod")
+	obj.SetNamespace("default")
+	obj.SetName("test")
+	obj.Object["status"] = map[string]interface{}{"phase": "Running"}
+
+	stopped := false
+
+	testcases := []struct {
+		name     string
+		stop     func()
+		expected bool
+	}{
+		{
+			name:     "stop is called",
+			stop:     func() { stopped = true },
+			expected: true,
+		},
+		{
+			// library users do not have to stop anything
+			name:     "no stop function",
+			expected: false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			stopped = false
+
+			w := NewWatcher(&Options{
+				Until: []*Condition{condition},
+				Stop:  testcase.stop,
+			})
+
+			go func() {
+				for range w.Events() {
+				}
+			}()
+
+			w.Process(watch.Added, obj)
+			w.Close()
+
+			if stopped != testcase.expected {
+				t.Errorf("Expected stopped to be %v, but got %v.", testcase.expected, stopped)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"go.xrstf.de/stalk/pkg/diff"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// validateExpressions prints whether each --jsonpath, --show and --hide
+// expression (and their event specific variants) is valid and, if a sample manifest is given, whether it matches
+// anything in it. It returns false if any expression is invalid.
+func validateExpressions(log logrus.FieldLogger, differOpts *diff.Options, sampleFile string, out io.Writer) (bool, error) {
+	var sample *unstructured.Unstructured
+
+	if sampleFile != "" {
+		var err error
+
+		sample, err = readManifest(sampleFile)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	results := diff.ValidateExpressions(differOpts, sample, log)
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No --jsonpath, --show or --hide expressions given.")
+		return true, nil
+	}
+
+	valid := true
+
+	for _, result := range results {
+		var status string
+
+		switch {
+		case result.Err != nil:
+			status = fmt.Sprintf("error: %v", result.Err)
+			valid = false
+		case result.Matches == nil:
+			status = "OK"
+		case *result.Matches:
+			status = "OK (matches sample)"
+		default:
+			status = "OK (does not match sample)"
+		}
+
+		fmt.Fprintf(out, "--%s %s: %s\n", result.Type, result.Expression, status)
+	}
+
+	return valid, nil
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/diff"
+	kubeutil "go.xrstf.de/stalk/pkg/kubernetes"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// minRewatchDelay and maxRewatchDelay limit how quickly the watch for
+	// the object is re-established if that fails.
+	minRewatchDelay = 1 * time.Second
+	maxRewatchDelay = 30 * time.Second
+)
+
+// serverManagedFields are removed from the live object before comparing it
+// to the local manifest, as they are never part of a manifest anyway.
+var serverManagedFields = [][]string{
+	{"status"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "managedFields"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+}
+
+// fileTarget is the object in the cluster that a manifest refers to.
+type fileTarget struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+	client    dynamic.ResourceInterface
+}
+
+func (t *fileTarget) String() string {
+	if t.namespace == "" {
+		return fmt.Sprintf("%s %s", t.gvk.Kind, t.name)
+	}
+
+	return fmt.Sprintf("%s %s/%s", t.gvk.Kind, t.namespace, t.name)
+}
+
+func (t *fileTarget) sameObject(other *fileTarget) bool {
+	return t.gvk == other.gvk && t.namespace == other.namespace && t.name == other.name
+}
+
+// liveState is what is known about the object in the cluster.
+type liveState struct {
+	// obj is nil if the object does not exist.
+	obj  *unstructured.Unstructured
+	seen time.Time
+
+	// resourceVersion is the last seen version, from which the watch can be
+	// resumed without receiving the current state again.
+	resourceVersion string
+}
+
+// watchFile observes a local manifest and the matching object in the cluster
+// and prints the diff between both whenever either of them changes.
+func watchFile(ctx context.Context, log logrus.FieldLogger, filename string, appOpts *options, differ *diff.Differ, out io.Writer) {
+	local, err := readManifest(filename)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", filename, err)
+	}
+
+	config, err := kubeconfigLoader(appOpts.kubeconfig, appOpts.kubeContext).ClientConfig()
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	applyRateLimits(config, appOpts)
+
+	// like kubectl, manifests without a namespace refer to the given one or
+	// the namespace of the current context
+	defaultNamespace, _, err := kubeconfigLoader(appOpts.kubeconfig, appOpts.kubeContext).Namespace()
+	if err != nil {
+		log.Fatalf("Failed to determine default namespace: %v", err)
+	}
+
+	if len(appOpts.namespaces) > 0 {
+		defaultNamespace = appOpts.namespaces[0]
+	}
+
+	resolver, err := kubeutil.NewResolver(config, log)
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes REST mapper: %v", err)
+	}
+
+	target, err := resolveTarget(resolver, local, defaultNamespace)
+	if err != nil {
+		log.Fatalf("Invalid manifest %s: %v", filename, err)
+	}
+
+	live := &liveState{}
+	if err := fetchObject(ctx, log, target, live); err != nil {
+		log.Fatalf("Failed to retrieve %s: %v", target, err)
+	}
+
+	if live.obj == nil {
+		log.Warnf("%s does not exist in the cluster.", target)
+	}
+
+	// watch the parent directory, as many editors replace files instead of
+	// writing to them, which would end a watch on the file itself
+	fileWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatalf("Failed to create file watcher: %v", err)
+	}
+	defer fileWatcher.Close()
+
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		log.Fatalf("Failed to determine absolute path for %s: %v", filename, err)
+	}
+
+	if err := fileWatcher.Add(filepath.Dir(absFilename)); err != nil {
+		log.Fatalf("Failed to watch %s: %v", filename, err)
+	}
+
+	clusterWatch, err := watchObject(ctx, log, target, live.resourceVersion)
+	if err != nil {
+		log.Fatalf("Failed to create watch for %s: %v", target, err)
+	}
+
+	printFileDiff(log, differ, out, live.obj, local, live.seen, filename)
+
+	for {
+		select {
+		case <-ctx.Done():
+			clusterWatch.Stop()
+			return
+
+		case event, ok := <-fileWatcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != absFilename {
+				continue
+			}
+
+			updated, err := readManifest(filename)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				// most likely the file is currently being written
+				log.Debugf("Ignoring unreadable %s: %v", filename, err)
+				continue
+			}
+
+			var updatedTarget *fileTarget
+			if updated != nil {
+				updatedTarget, err = resolveTarget(resolver, updated, defaultNamespace)
+				if err != nil {
+					log.Warnf("Ignoring invalid %s: %v", filename, err)
+					continue
+				}
+			}
+
+			// editors often write a file multiple times per save and
+			// chmod events are reported as well
+			if !objectChanged(local, updated) {
+				continue
+			}
+
+			if updated == nil {
+				log.Warnf("%s does not exist anymore.", filename)
+			} else if !updatedTarget.sameObject(target) {
+				log.Infof("%s now refers to %s instead of %s.", filename, updatedTarget, target)
+
+				clusterWatch.Stop()
+				target = updatedTarget
+				live = &liveState{}
+
+				clusterWatch = rewatchObject(ctx, log, target, live)
+				if clusterWatch == nil {
+					return
+				}
+
+				if live.obj == nil {
+					log.Warnf("%s does not exist in the cluster.", target)
+				}
+			}
+
+			local = updated
+			printFileDiff(log, differ, out, live.obj, local, live.seen, filename)
+
+		case err, ok := <-fileWatcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Warnf("File watcher error: %v", err)
+
+		case event, ok := <-clusterWatch.ResultChan():
+			if !ok {
+				log.Debug("Watch was closed, re-establishing...")
+
+				previous := live.obj
+
+				clusterWatch = rewatchObject(ctx, log, target, live)
+				if clusterWatch == nil {
+					return
+				}
+
+				// if the watch could not be resumed, the object had to be
+				// fetched again and might have changed in the meantime
+				if objectChanged(previous, live.obj) {
+					printFileDiff(log, differ, out, live.obj, local, live.seen, filename)
+				}
+
+				continue
+			}
+
+			if event.Type == watch.Error {
+				// the watch cannot be resumed, e.g. because the last seen
+				// version is too old, so start over from the current state
+				log.Warnf("Watch for %s failed, re-establishing it: %v", target, apierrors.FromObject(event.Object))
+
+				clusterWatch.Stop()
+				previous := live.obj
+				live.resourceVersion = ""
+
+				clusterWatch = rewatchObject(ctx, log, target, live)
+				if clusterWatch == nil {
+					return
+				}
+
+				if objectChanged(previous, live.obj) {
+					printFileDiff(log, differ, out, live.obj, local, live.seen, filename)
+				}
+
+				continue
+			}
+
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				live.obj = obj
+			case watch.Deleted:
+				log.Warnf("%s has been deleted from the cluster.", target)
+				live.obj = nil
+			default:
+				continue
+			}
+
+			live.resourceVersion = obj.GetResourceVersion()
+			live.seen = time.Now()
+			printFileDiff(log, differ, out, live.obj, local, live.seen, filename)
+		}
+	}
+}
+
+// resolveTarget determines which object in the cluster the manifest refers
+// to. Manifests of namespaced kinds without a namespace are changed to use
+// the default namespace, so that the namespace does not show up in diffs.
+func resolveTarget(resolver *kubeutil.Resolver, local *unstructured.Unstructured, defaultNamespace string) (*fileTarget, error) {
+	gvk := local.GroupVersionKind()
+	name := local.GetName()
+	if gvk.Kind == "" || name == "" {
+		return nil, errors.New("manifest must specify at least apiVersion, kind and metadata.name")
+	}
+
+	namespaced, err := resolver.IsNamespaced(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespaced && local.GetNamespace() == "" {
+		local.SetNamespace(defaultNamespace)
+	}
+
+	target := &fileTarget{
+		gvk:  gvk,
+		name: name,
+	}
+
+	if namespaced {
+		target.namespace = local.GetNamespace()
+	}
+
+	target.client, err = resolver.NamespacedResourceInterfaceFor(gvk, target.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic interface for %q resources: %w", gvk.Kind, err)
+	}
+
+	return target, nil
+}
+
+// fetchObject retrieves the current state of the object. The object is
+// listed instead of fetched, so that a resource version to watch from is
+// known even if it does not exist.
+func fetchObject(ctx context.Context, log logrus.FieldLogger, target *fileTarget, live *liveState) error {
+	var list *unstructured.UnstructuredList
+
+	err := kubeutil.RetryOnThrottling(ctx, log, func() (err error) {
+		list, err = target.client.List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", target.name).String(),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	live.obj = nil
+	if len(list.Items) > 0 {
+		live.obj = &list.Items[0]
+	}
+
+	live.resourceVersion = list.GetResourceVersion()
+	live.seen = time.Now()
+
+	return nil
+}
+
+// watchObject watches the object for changes after the given resource
+// version.
+func watchObject(ctx context.Context, log logrus.FieldLogger, target *fileTarget, resourceVersion string) (watch.Interface, error) {
+	var wi watch.Interface
+
+	err := kubeutil.RetryOnThrottling(ctx, log, func() (err error) {
+		wi, err = target.client.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", target.name).String(),
+			ResourceVersion: resourceVersion,
+		})
+		return err
+	})
+
+	return wi, err
+}
+
+// rewatchObject re-establishes the watch for the object, retrying with an
+// increasing delay until it succeeds. If ctx is cancelled in the meantime,
+// nil is returned.
+func rewatchObject(ctx context.Context, log logrus.FieldLogger, target *fileTarget, live *liveState) watch.Interface {
+	delay := minRewatchDelay
+
+	for {
+		wi, err := resumeWatch(ctx, log, target, live)
+		if err == nil {
+			return wi
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Warnf("Failed to re-establish watch for %s, retrying in %v: %v", target, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRewatchDelay {
+			delay = maxRewatchDelay
+		}
+	}
+}
+
+// resumeWatch watches the object after the last seen resource version. If
+// there is none or it is too old, the object is fetched again first.
+func resumeWatch(ctx context.Context, log logrus.FieldLogger, target *fileTarget, live *liveState) (watch.Interface, error) {
+	if live.resourceVersion != "" {
+		wi, err := watchObject(ctx, log, target, live.resourceVersion)
+		if !apierrors.IsResourceExpired(err) && !apierrors.IsGone(err) {
+			return wi, err
+		}
+
+		log.Debugf("Cannot resume watch for %s, fetching it again: %v", target, err)
+	}
+
+	if err := fetchObject(ctx, log, target, live); err != nil {
+		return nil, fmt.Errorf("failed to retrieve object: %w", err)
+	}
+
+	return watchObject(ctx, log, target, live.resourceVersion)
+}
+
+// objectChanged returns true if the content of an object changed, including
+// it being created or removed. Either object can be nil.
+func objectChanged(oldObj, newObj *unstructured.Unstructured) bool {
+	if oldObj == nil || newObj == nil {
+		return oldObj != newObj
+	}
+
+	return !equality.Semantic.DeepEqual(oldObj.Object, newObj.Object)
+}
+
+func printFileDiff(log logrus.FieldLogger, differ *diff.Differ, out io.Writer, live, local *unstructured.Unstructured, liveSeen time.Time, filename string) {
+	if live != nil {
+		live = live.DeepCopy()
+		for _, field := range serverManagedFields {
+			unstructured.RemoveNestedField(live.Object, field...)
+		}
+	}
+
+	if err := differ.PrintDiff(out, live, local, liveSeen, diff.TitleInfo{
+		Annotations: []string{fmt.Sprintf("(local file %s)", filename)},
+	}); err != nil {
+		log.Errorf("Failed to show diff: %v", err)
+	}
+}
+
+func readManifest(filename string) (*unstructured.Unstructured, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// go through JSON, so that numbers are decoded as int64 just like
+	// objects coming from the API server
+	encoded, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	if len(bytes.TrimSpace(encoded)) == 0 || string(bytes.TrimSpace(encoded)) == "null" {
+		return nil, errors.New("file is empty")
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(encoded); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	return obj, nil
+}
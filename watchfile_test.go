@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.xrstf.de/stalk/pkg/diff"
+
+	"github.com/gookit/color"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const manifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+spec:
+  replicas: 3
+`
+
+func writeManifest(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	return filename
+}
+
+func TestReadManifest(t *testing.T) {
+	obj, err := readManifest(writeManifest(t, manifest))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+
+	if name := obj.GetName(); name != "nginx" {
+		t.Errorf("Expected %q, but got %q.", "nginx", name)
+	}
+
+	// numbers must have the same type as in objects from the API server
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil || !found {
+		t.Fatalf("Expected spec.replicas to be an int64, but got %v.", obj.Object["spec"])
+	}
+
+	if replicas != 3 {
+		t.Errorf("Expected 3 replicas, but got %d.", replicas)
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	testcases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "only whitespace",
+			content: "\n   \n",
+		},
+		{
+			name:    "invalid YAML",
+			content: "kind: [Deployment",
+		},
+		{
+			name:    "no object",
+			content: "- foo\n- bar\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := readManifest(writeManifest(t, tc.content)); err == nil {
+				t.Error("Expected an error, but got none.")
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readManifest(filepath.Join(t.TempDir(), "missing.yaml"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected a not-exist error, but got %v.", err)
+		}
+	})
+}
+
+func TestObjectChanged(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Deployment"}}
+	changed := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "StatefulSet"}}
+
+	testcases := []struct {
+		name     string
+		old      *unstructured.Unstructured
+		new      *unstructured.Unstructured
+		expected bool
+	}{
+		{name: "unchanged", old: obj, new: obj.DeepCopy(), expected: false},
+		{name: "changed", old: obj, new: changed, expected: true},
+		{name: "created", old: nil, new: obj, expected: true},
+		{name: "removed", old: obj, new: nil, expected: true},
+		{name: "still missing", old: nil, new: nil, expected: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := objectChanged(tc.old, tc.new); result != tc.expected {
+				t.Errorf("Expected %v, but got %v.", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestPrintFileDiff(t *testing.T) {
+	color.Disable()
+
+	differ, err := diff.NewDiffer(&diff.Options{
+		ContextLines:     3,
+		HideEmptyDiffs:   true,
+		CreateColorTheme: diff.CreateColorTheme,
+		UpdateColorTheme: diff.UpdateColorTheme,
+		DeleteColorTheme: diff.DeleteColorTheme,
+	}, logrus.New())
+	if err != nil {
+		t.Fatalf("failed to create differ: %v", err)
+	}
+
+	filename := writeManifest(t, manifest)
+
+	local, err := readManifest(filename)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+
+	// the live object has the same spec, but all the fields managed by the
+	// API server, which must not show up in the diff
+	live := local.DeepCopy()
+	live.SetUID("1234")
+	live.SetResourceVersion("42")
+	live.SetGeneration(2)
+	live.Object["status"] = map[string]interface{}{"readyReplicas": int64(3)}
+
+	var buf bytes.Buffer
+	printFileDiff(logrus.New(), differ, &buf, live, local, time.Now(), filename)
+
+	if buf.Len() > 0 {
+		t.Errorf("Expected no output for a manifest matching the cluster, but got %q.", buf.String())
+	}
+
+	if err := unstructured.SetNestedField(live.Object, int64(1), "spec", "replicas"); err != nil {
+		t.Fatalf("failed to change live object: %v", err)
+	}
+
+	printFileDiff(logrus.New(), differ, &buf, live, local, time.Now(), filename)
+
+	output := buf.String()
+	for _, expected := range []string{"-  replicas: 1", "+  replicas: 3", "(local file " + filename + ")"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, but got %q.", expected, output)
+		}
+	}
+
+	if strings.Contains(output, "readyReplicas") {
+		t.Errorf("Expected status to be hidden, but got %q.", output)
+	}
+}
+
+func TestResumeWatch(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace("default")
+	obj.SetName("nginx")
+	obj.SetResourceVersion("5")
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr: "DeploymentList",
+	}, obj)
+
+	// the API server has already forgotten the last seen version
+	versions := []string{}
+	client.PrependWatchReactor("*", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		versions = append(versions, action.(clienttesting.WatchAction).GetWatchRestrictions().ResourceVersion)
+		if len(versions) == 1 {
+			return true, nil, apierrors.NewResourceExpired("too old resource version")
+		}
+
+		return false, nil, nil
+	})
+
+	target := &fileTarget{
+		gvk:       gvk,
+		namespace: "default",
+		name:      "nginx",
+		client:    client.Resource(gvr).Namespace("default"),
+	}
+
+	live := &liveState{resourceVersion: "3"}
+
+	wi, err := resumeWatch(context.Background(), logrus.New(), target, live)
+	if err != nil {
+		t.Fatalf("failed to resume watch: %v", err)
+	}
+	defer wi.Stop()
+
+	if len(versions) != 2 || versions[0] != "3" {
+		t.Errorf("Expected to first resume from version 3 and then watch again, but got %v.", versions)
+	}
+
+	if live.obj == nil || live.obj.GetResourceVersion() != "5" {
+		t.Errorf("Expected the object to be fetched again, but got %v.", live.obj)
+	}
+}